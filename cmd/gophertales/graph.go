@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"GopherTales/internal/config"
+	"GopherTales/internal/services"
+)
+
+// runGraph exports story graphs to stdout, or one file per gopher with -out
+func runGraph(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+	format := fs.String("format", services.GraphFormatDOT, "Output format: dot or mermaid")
+	gopher := fs.String("gopher", "", "Only export this gopher (default: all)")
+	outDir := fs.String("out", "", "Write <gopher>.dot or <gopher>.mmd files to this directory instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gophertales graph [flags] [story file]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !services.IsGraphFormat(*format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		return 2
+	}
+
+	file := cfg.Story.DataFile
+	if fs.NArg() > 0 {
+		file = fs.Arg(0)
+	}
+
+	storyService := services.NewStoryService(file)
+	if err := storyService.LoadStory(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load story: %v\n", err)
+		return 1
+	}
+
+	gophers := storyService.GetAvailableGophers()
+	sort.Strings(gophers)
+	if *gopher != "" {
+		gophers = []string{*gopher}
+	}
+
+	extension := ".dot"
+	if *format == services.GraphFormatMermaid {
+		extension = ".mmd"
+	}
+
+	for _, g := range gophers {
+		graph, err := storyService.ExportGraph(g, *format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export graph: %v\n", err)
+			return 1
+		}
+
+		if *outDir == "" {
+			fmt.Print(graph)
+			continue
+		}
+
+		path := filepath.Join(*outDir, g+extension)
+		if err := os.WriteFile(path, []byte(graph), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", path, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	}
+
+	return 0
+}
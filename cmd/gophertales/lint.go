@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"GopherTales/internal/config"
+	"GopherTales/internal/models"
+	"GopherTales/internal/services"
+)
+
+// lintReport is the JSON output for a single file
+type lintReport struct {
+	File     string             `json:"file"`
+	Issues   []models.LintIssue `json:"issues"`
+	Errors   int                `json:"errors"`
+	Warnings int                `json:"warnings"`
+}
+
+// runLint lints each story file given, or the configured story file.
+// It exits 1 when errors are found (or warnings, with -strict).
+func runLint(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	format := fs.String("format", "text", "Output format: text or json")
+	strict := fs.Bool("strict", false, "Treat warnings as errors")
+	staticDir := fs.String("static", cfg.Story.StaticDir, "Static directory arc assets are checked against (empty to skip)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gophertales lint [flags] [story files...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		return 2
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{cfg.Story.DataFile}
+	}
+
+	reports := make([]lintReport, 0, len(files))
+	failed := false
+	for _, file := range files {
+		report := lintReport{File: file, Issues: services.LintStoryFile(file, *staticDir)}
+		if report.Issues == nil {
+			report.Issues = []models.LintIssue{}
+		}
+		for _, issue := range report.Issues {
+			if issue.Severity == services.LintError {
+				report.Errors++
+			} else {
+				report.Warnings++
+			}
+		}
+		if report.Errors > 0 || (*strict && report.Warnings > 0) {
+			failed = true
+		}
+		reports = append(reports, report)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding lint report: %v\n", err)
+			return 2
+		}
+	} else {
+		for _, report := range reports {
+			printLintReport(report)
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// printLintReport writes a report in a compiler-like format
+func printLintReport(report lintReport) {
+	for _, issue := range report.Issues {
+		location := report.File
+		if issue.Line > 0 {
+			location = fmt.Sprintf("%s:%d:%d", report.File, issue.Line, issue.Column)
+		}
+
+		subject := issue.Gopher
+		if issue.Arc != "" {
+			if subject != "" {
+				subject += "/"
+			}
+			subject += issue.Arc
+		}
+		if subject != "" {
+			subject += ": "
+		}
+
+		fmt.Printf("%s: %s [%s] %s%s\n", location, issue.Severity, issue.Code, subject, issue.Message)
+	}
+
+	fmt.Printf("%s: %d error(s), %d warning(s)\n", report.File, report.Errors, report.Warnings)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"GopherTales/internal/config"
+)
+
+// command is a gophertales subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(cfg *config.Config, args []string) int
+}
+
+var commands = []command{
+	{name: "lint", summary: "Check story files for mistakes", run: runLint},
+	{name: "graph", summary: "Export story graphs as Graphviz DOT or Mermaid", run: runGraph},
+	{name: "twee-import", summary: "Convert a Twine (Twee 3) story to JSON", run: runTweeImport},
+	{name: "twee-export", summary: "Convert a story file to Twine (Twee 3) source", run: runTweeExport},
+	{name: "set-role", summary: "Change a user's role, such as promoting the first admin", run: runSetRole},
+	{name: "migrate", summary: "Apply pending database migrations", run: runMigrate},
+}
+
+func main() {
+	// Load .env file so defaults match the server
+	_ = config.LoadEnvFile(".env")
+	cfg := config.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(cfg, os.Args[2:]))
+		}
+	}
+
+	if name != "help" && name != "-h" && name != "--help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: gophertales <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'gophertales <command> -h' for command flags.")
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"GopherTales/internal/config"
+	"GopherTales/internal/database"
+)
+
+// runMigrate applies pending database migrations, so deployments can run
+// them as a separate step before starting the server
+func runMigrate(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	status := fs.Bool("status", false, "List migrations and whether they were applied, without applying any")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gophertales migrate [-status]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	switch cfg.Database.Storage {
+	case "mongo":
+		return migrateMongo(cfg, *status)
+	case "sqlite":
+		// Opening a SQLite database applies its migrations
+		sqliteDB, err := database.NewSQLite(cfg.Database.SQLitePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to migrate SQLite database: %v\n", err)
+			return 1
+		}
+		sqliteDB.Close()
+		fmt.Printf("SQLite database %s is up to date\n", cfg.Database.SQLitePath)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "STORAGE=%s has no database to migrate\n", cfg.Database.Storage)
+		return 1
+	}
+}
+
+// migrateMongo applies pending MongoDB migrations, or lists them all
+func migrateMongo(cfg *config.Config, status bool) int {
+	if cfg.Database.MongoURI == "" {
+		fmt.Fprintln(os.Stderr, "MONGO_URI is required")
+		return 1
+	}
+	mongoDB, err := database.NewMongoDB(cfg.Database.MongoURI, cfg.Database.DBName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %v\n", err)
+		return 1
+	}
+	defer mongoDB.Close()
+
+	ctx := context.Background()
+	if status {
+		statuses, err := mongoDB.MigrationStatus(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if !s.AppliedAt.IsZero() {
+				applied = "applied " + s.AppliedAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%4d  %-40s %s\n", s.Version, s.Description, applied)
+		}
+		return 0
+	}
+
+	applied, err := mongoDB.Migrate(ctx)
+	for _, migration := range applied {
+		fmt.Printf("Applied migration %d: %s\n", migration.Version, migration.Description)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(applied) == 0 {
+		fmt.Println("MongoDB is up to date")
+	}
+	return 0
+}
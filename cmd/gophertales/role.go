@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"GopherTales/internal/config"
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+	"GopherTales/internal/services"
+)
+
+// runSetRole changes a user's role in the database, for example to promote
+// the first admin
+func runSetRole(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("set-role", flag.ContinueOnError)
+	email := fs.String("email", "", "Email of the account to change")
+	role := fs.String("role", string(models.RoleAdmin), "New role: reader, author or admin")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gophertales set-role -email <email> [-role admin]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *email == "" {
+		fs.Usage()
+		return 2
+	}
+
+	userService, closeDB, err := openUserService(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeDB()
+
+	user, err := userService.SetRole(context.Background(), *email, models.Role(*role))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			fmt.Fprintf(os.Stderr, "No account with email %s\n", *email)
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to set role: %v\n", err)
+		}
+		return 1
+	}
+
+	fmt.Printf("%s is now %s\n", user.Email, user.Role)
+	return 0
+}
+
+// openUserService opens the account storage the server is configured with
+func openUserService(cfg *config.Config) (*services.UserService, func(), error) {
+	switch cfg.Database.Storage {
+	case "mongo":
+		if cfg.Database.MongoURI == "" {
+			return nil, nil, errors.New("MONGO_URI is required")
+		}
+		mongoDB, err := database.NewMongoDB(cfg.Database.MongoURI, cfg.Database.DBName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+		}
+		mongoDB.SetOperationTimeout(time.Duration(cfg.Database.OperationTimeout) * time.Second)
+		users := services.NewMongoUserRepository(mongoDB)
+		return services.NewUserService(users, users, users), func() { mongoDB.Close() }, nil
+	case "sqlite":
+		sqliteDB, err := database.NewSQLite(cfg.Database.SQLitePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open SQLite database: %w", err)
+		}
+		sqliteDB.SetOperationTimeout(time.Duration(cfg.Database.OperationTimeout) * time.Second)
+		users := services.NewSQLiteUserRepository(sqliteDB)
+		return services.NewUserService(users, users, users), func() { sqliteDB.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("STORAGE=%s keeps no accounts this command can change", cfg.Database.Storage)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"GopherTales/internal/config"
+	"GopherTales/internal/models"
+	"GopherTales/internal/services"
+)
+
+// runTweeImport converts a Twee 3 file into the GopherTales JSON story format
+func runTweeImport(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("twee-import", flag.ContinueOnError)
+	output := fs.String("o", "", "Write JSON to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gophertales twee-import [flags] story.twee")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+
+	stories, err := services.ParseTwee(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+
+	// Keep requirements such as "courage >= 2" readable in the output
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(stories); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode story: %v\n", err)
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(out.Bytes())
+		return 0
+	}
+	if err := os.WriteFile(*output, out.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", *output, err)
+		return 1
+	}
+	return 0
+}
+
+// runTweeExport converts a story file into Twee 3 source for editing in Twine
+func runTweeExport(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("twee-export", flag.ContinueOnError)
+	title := fs.String("title", "GopherTales", "Story title written to StoryTitle")
+	gopher := fs.String("gopher", "", "Only export this gopher (default: all)")
+	output := fs.String("o", "", "Write Twee to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gophertales twee-export [flags] [story file]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	file := cfg.Story.DataFile
+	if fs.NArg() > 0 {
+		file = fs.Arg(0)
+	}
+
+	storyService := services.NewStoryService(file)
+	if err := storyService.LoadStory(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load story: %v\n", err)
+		return 1
+	}
+
+	stories := make(map[string]map[string]models.Arc)
+	for _, g := range storyService.GetAvailableGophers() {
+		if *gopher == "" || g == *gopher {
+			stories[g] = storyService.GetGopherArcs(g)
+		}
+	}
+	if len(stories) == 0 {
+		fmt.Fprintln(os.Stderr, "No gopher stories to export")
+		return 1
+	}
+
+	twee := services.FormatTwee(*title, stories)
+	if *output == "" {
+		os.Stdout.Write(twee)
+		return 0
+	}
+	if err := os.WriteFile(*output, twee, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", *output, err)
+		return 1
+	}
+	return 0
+}
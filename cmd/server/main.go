@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -13,7 +15,9 @@ import (
 	"GopherTales/internal/database"
 	"GopherTales/internal/handlers"
 	"GopherTales/internal/middleware"
+	"GopherTales/internal/models"
 	"GopherTales/internal/services"
+	"GopherTales/internal/version"
 )
 
 func main() {
@@ -25,22 +29,94 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
-	// Validate MongoDB URI
-	if cfg.Database.MongoURI == "" {
-		log.Fatalf("MONGO_URI is required in .env file")
-	}
+	// Initialize storage
+	var storage *services.Storage
+	switch cfg.Database.Storage {
+	case "mongo":
+		if cfg.Database.MongoURI == "" {
+			log.Fatalf("MONGO_URI is required in .env file (or set STORAGE=sqlite or STORAGE=memory)")
+		}
+		mongoDB, err := database.NewMongoDB(cfg.Database.MongoURI, cfg.Database.DBName)
+		if err != nil {
+			log.Fatalf("Failed to connect to MongoDB: %v", err)
+		}
+		defer mongoDB.Close()
+		mongoDB.SetOperationTimeout(time.Duration(cfg.Database.OperationTimeout) * time.Second)
+		log.Printf("✅ Connected to MongoDB: %s", cfg.Database.DBName)
+
+		if err := migrateMongo(mongoDB, cfg.Database.MigrateOnStart); err != nil {
+			log.Fatalf("Failed to migrate MongoDB: %v", err)
+		}
+		storage = services.NewMongoStorage(mongoDB)
+	case "sqlite":
+		sqliteDB, err := database.NewSQLite(cfg.Database.SQLitePath)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		defer sqliteDB.Close()
+		sqliteDB.SetOperationTimeout(time.Duration(cfg.Database.OperationTimeout) * time.Second)
+		log.Printf("✅ Opened SQLite database: %s", cfg.Database.SQLitePath)
 
-	// Initialize databases
-	mongoDB, err := database.NewMongoDB(cfg.Database.MongoURI, cfg.Database.DBName)
-	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		storage = services.NewSQLiteStorage(sqliteDB)
+	case "memory":
+		log.Printf("Warning: STORAGE=memory, accounts, sessions and progress will be lost on restart")
+		storage = services.NewMemoryStorage()
+	default:
+		log.Fatalf("Unknown STORAGE %q, use mongo, sqlite or memory", cfg.Database.Storage)
 	}
-	defer mongoDB.Close()
-	log.Printf("✅ Connected to MongoDB: %s", cfg.Database.DBName)
 
 	// Initialize services
 	storyService := services.NewStoryService(cfg.Story.DataFile)
-	userService := services.NewUserService(mongoDB)
+	storyService.SetStaticDir(cfg.Story.StaticDir)
+	userService := services.NewUserService(storage.Users, storage.Progress, storage.Bookmarks)
+	userService.SetRequireVerifiedEmail(cfg.Account.RequireVerifiedEmail)
+	if len(cfg.Admin.Emails) > 0 {
+		skipped, err := userService.EnsureAdmins(context.Background(), cfg.Admin.Emails)
+		if err != nil {
+			log.Fatalf("Failed to promote admins: %v", err)
+		}
+		for _, email := range skipped {
+			log.Printf("Warning: ADMIN_EMAILS lists %s, which has no account with a verified email yet", email)
+		}
+	}
+
+	// Initialize sessions
+	sessionStore := storage.Sessions
+	sessionSecret := []byte(cfg.Session.Secret)
+	if len(sessionSecret) == 0 {
+		log.Printf("Warning: SESSION_SECRET is not set, generating a random one; sessions will not survive a restart")
+		sessionSecret = make([]byte, 32)
+		if _, err := rand.Read(sessionSecret); err != nil {
+			log.Fatalf("Failed to generate session secret: %v", err)
+		}
+	}
+	sessions := services.NewSessionManager(sessionStore, sessionSecret, time.Duration(cfg.Session.TTL)*time.Hour)
+	sessions.SetSecure(cfg.Session.SecureCookie)
+
+	// Initialize email and account recovery
+	var mailer services.Mailer
+	if cfg.Mail.SMTPHost != "" {
+		mailer = services.NewSMTPMailer(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword, cfg.Mail.From)
+	} else {
+		log.Printf("Warning: SMTP_HOST is not set, emails will be logged instead of sent")
+		mailer = services.NewFileMailer(cfg.Mail.OutboxDir, cfg.Mail.From)
+	}
+	tokenStore := storage.Tokens
+	passwordResets := services.NewPasswordResetService(userService, tokenStore, sessionStore, mailer, cfg.BaseURL())
+	verifications := services.NewEmailVerificationService(userService, tokenStore, mailer, cfg.BaseURL())
+
+	// Initialize login throttling
+	loginGuard := services.NewLoginGuard(storage.LoginAttempts, userService, mailer)
+	loginGuard.SetLockout(cfg.Account.LockoutThreshold, time.Duration(cfg.Account.LockoutMinutes)*time.Minute)
+	twoFactor := services.NewTwoFactorService(userService, tokenStore)
+
+	// Initialize single sign-on
+	var oidcLogins *services.OIDCLoginService
+	if cfg.OIDC.IssuerURL != "" {
+		provider := services.NewOIDCProvider(cfg.OIDC.IssuerURL, cfg.OIDC.ClientID, cfg.OIDC.ClientSecret, cfg.OIDCRedirectURL())
+		oidcLogins = services.NewOIDCLoginService(provider, userService, sessionSecret)
+		log.Printf("🔑 Single sign-on enabled with %s", provider.Issuer())
+	}
 
 	// Load story data
 	if err := storyService.LoadStory(); err != nil {
@@ -49,59 +125,121 @@ func main() {
 
 	log.Printf("Successfully loaded story with %d arcs", len(storyService.GetAvailableArcs()))
 
+	// Watch story files for changes
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	if cfg.Story.WatchInterval > 0 {
+		watcher := services.NewStoryWatcher(storyService, time.Duration(cfg.Story.WatchInterval)*time.Second)
+		go watcher.Run(watchCtx)
+		log.Printf("👀 Watching %s for story changes", cfg.Story.DataFile)
+	}
+
+	// Readiness checks, the database is only checked when there is one
+	readiness := services.NewHealthChecker(time.Duration(cfg.Server.HealthCheckTimeout) * time.Second)
+	if storage.Ping != nil {
+		readiness.Add(cfg.Database.Storage, storage.Ping)
+	}
+	readiness.Add("story", func(context.Context) error {
+		return storyService.Validate()
+	})
+
 	// Initialize handlers
-	homeHandler := handlers.NewHomeHandler(cfg.Story.TemplateDir, userService)
-	selectionHandler := handlers.NewSelectionHandler(cfg.Story.TemplateDir)
-	storyHandler := handlers.NewStoryHandler(storyService, userService, cfg.Story.TemplateDir)
+	homeHandler := handlers.NewHomeHandler(cfg.Story.TemplateDir)
+	selectionHandler := handlers.NewSelectionHandler(storyService, cfg.Story.TemplateDir)
+	storyHandler := handlers.NewStoryHandler(storyService, userService, storage.States, cfg.Story.TemplateDir)
+	storyHandler.SetSecure(cfg.Session.SecureCookie)
 	apiHandler := handlers.NewAPIHandler(storyService)
-	authHandler := handlers.NewAuthHandler(userService)
-	dashboardHandler := handlers.NewDashboardHandler(userService, cfg.Story.TemplateDir)
-	profileHandler := handlers.NewProfileHandler(userService, storyService, cfg.Story.TemplateDir)
+	healthHandler := handlers.NewHealthHandler(readiness)
+	authHandler := handlers.NewAuthHandler(userService, sessions, verifications, loginGuard, twoFactor)
+	dashboardHandler := handlers.NewDashboardHandler(storyService, cfg.Story.TemplateDir)
+	profileHandler := handlers.NewProfileHandler(storyService, cfg.Story.TemplateDir)
+	adminHandler := handlers.NewAdminHandler(storyService, userService)
+	passwordHandler := handlers.NewPasswordHandler(passwordResets)
+	verificationHandler := handlers.NewVerificationHandler(verifications, cfg.Story.TemplateDir)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactor)
+	loginPage := handlers.NewPageHandler(cfg.Story.TemplateDir, "login.html")
 
 	// Auth middleware
-	requireAuth := middleware.RequireAuth(userService)
+	authenticate := middleware.Authenticate(sessions, userService)
+	requireAuth := middleware.RequireAuth
+	requireAuthor := middleware.RequireAdminTokenOrRole(cfg.Admin.Token, models.RoleAuthor)
+	requireAdmin := middleware.RequireAdminTokenOrRole(cfg.Admin.Token, models.RoleAdmin)
 
 	// Setup routes
 	mux := http.NewServeMux()
 
-	// Static files
-	fs := http.FileServer(http.Dir(cfg.Story.StaticDir))
-	mux.Handle("/static/", http.StripPrefix("/static/", fs))
-
 	// Web routes
 	mux.Handle("/", homeHandler)
-	mux.Handle("/login", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, cfg.Story.TemplateDir+"/login.html")
-	}))
-	mux.Handle("/register", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, cfg.Story.TemplateDir+"/register.html")
-	}))
+	mux.Handle("/login", loginPage)
+	mux.Handle("/register", handlers.NewPageHandler(cfg.Story.TemplateDir, "register.html"))
+	mux.Handle("/forgot-password", handlers.NewPageHandler(cfg.Story.TemplateDir, "forgot_password.html"))
+	mux.Handle("/reset-password", handlers.NewPageHandler(cfg.Story.TemplateDir, "reset_password.html"))
+	mux.HandleFunc("/verify-email", verificationHandler.VerifyEmail)
 	mux.Handle("/dashboard", requireAuth(dashboardHandler))
 	mux.Handle("/selection", selectionHandler)
 	mux.Handle("/story", storyHandler)
 	mux.Handle("/profile", requireAuth(profileHandler))
 
+	// Health probes; /api/health is kept for monitors that still use it
+	mux.HandleFunc("/livez", healthHandler.Livez)
+	mux.HandleFunc("/readyz", healthHandler.Readyz)
+	mux.HandleFunc("/api/health", healthHandler.Readyz)
+
 	// API routes
-	mux.HandleFunc("/api/health", apiHandler.HealthCheck)
 	mux.HandleFunc("/api/stats", apiHandler.GetStoryStats)
 	mux.HandleFunc("/api/arcs", apiHandler.GetAllArcs)
 	mux.HandleFunc("/api/arc", apiHandler.GetArc)
 	mux.HandleFunc("/api/gophers", apiHandler.GetGophers)
 	mux.HandleFunc("/api/gopher-stats", apiHandler.GetGopherStats)
+	mux.HandleFunc("/api/story/analysis", apiHandler.GetStoryAnalysis)
+	mux.HandleFunc("/api/story/graph", apiHandler.GetStoryGraph)
 
 	// Auth routes
 	mux.HandleFunc("/api/auth/register", authHandler.Register)
 	mux.HandleFunc("/api/auth/login", authHandler.Login)
+	mux.HandleFunc("/api/auth/login/2fa", authHandler.LoginTwoFactor)
 	mux.HandleFunc("/api/auth/logout", authHandler.Logout)
-	mux.HandleFunc("/api/bookmark", authHandler.AddBookmark)
+	mux.HandleFunc("/api/auth/forgot-password", passwordHandler.ForgotPassword)
+	mux.HandleFunc("/api/auth/reset-password", passwordHandler.ResetPassword)
+	mux.Handle("/api/auth/resend-verification", requireAuth(http.HandlerFunc(verificationHandler.ResendVerification)))
+	mux.Handle("/api/auth/logout-all", requireAuth(http.HandlerFunc(authHandler.LogoutAll)))
+	mux.Handle("/api/bookmark", requireAuth(http.HandlerFunc(authHandler.AddBookmark)))
+
+	// Single sign-on routes
+	if oidcLogins != nil {
+		oidcHandler := handlers.NewOIDCHandler(oidcLogins, sessions, twoFactor)
+		oidcHandler.SetSecure(cfg.Session.SecureCookie)
+		mux.HandleFunc("/auth/oidc/login", oidcHandler.Login)
+		mux.HandleFunc("/auth/oidc/callback", oidcHandler.Callback)
+		loginPage.With("SSOProvider", cfg.OIDC.ProviderName)
+	}
+
+	// Two-factor authentication routes
+	mux.Handle("/api/2fa/enroll", requireAuth(http.HandlerFunc(twoFactorHandler.Enroll)))
+	mux.Handle("/api/2fa/qr.png", requireAuth(http.HandlerFunc(twoFactorHandler.QRCode)))
+	mux.Handle("/api/2fa/confirm", requireAuth(http.HandlerFunc(twoFactorHandler.Confirm)))
+	mux.Handle("/api/2fa/disable", requireAuth(http.HandlerFunc(twoFactorHandler.Disable)))
+
+	// Admin routes
+	mux.Handle("/api/admin/reload", requireAuthor(http.HandlerFunc(adminHandler.ReloadStory)))
+	mux.Handle("/api/admin/users/role", requireAdmin(http.HandlerFunc(adminHandler.SetUserRole)))
+
+	// Static files are served without looking up the session, every other
+	// route sees the signed-in user in its request context
+	root := http.NewServeMux()
+	fs := http.FileServer(http.Dir(cfg.Story.StaticDir))
+	root.Handle("/static/", http.StripPrefix("/static/", fs))
+	root.Handle("/", authenticate(mux))
 
 	// Apply middleware
 	handler := middleware.Chain(
-		mux,
+		root,
+		middleware.RealIP(cfg.Server.TrustProxy),
 		middleware.Logger,
 		middleware.Recovery,
 		middleware.SecurityHeaders,
-		middleware.CORS,
+		middleware.CORS(cfg.CORS.AllowedOrigins),
+		middleware.CSRF(cfg.Session.SecureCookie),
 	)
 
 	// Create server
@@ -121,7 +259,7 @@ func main() {
 	}()
 
 	// Log server startup info
-	log.Printf("🚀 Starting GopherTales server on %s", cfg.Address())
+	log.Printf("🚀 Starting GopherTales %s (%s) on %s", version.Version, version.Commit, cfg.Address())
 	log.Printf("🌐 Visit http://%s to start your adventure!", cfg.Address())
 
 	// Wait for interrupt signal to gracefully shutdown the server
@@ -142,3 +280,26 @@ func main() {
 		log.Println("Server gracefully stopped")
 	}
 }
+
+// migrateMongo applies pending migrations, or with apply false only checks
+// that none are pending, for deployments that run "gophertales migrate" as
+// a separate step
+func migrateMongo(db *database.MongoDB, apply bool) error {
+	ctx := context.Background()
+	if !apply {
+		pending, err := db.PendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d migrations are pending, run 'gophertales migrate' or set MIGRATE_ON_START=true", len(pending))
+		}
+		return nil
+	}
+
+	applied, err := db.Migrate(ctx)
+	for _, migration := range applied {
+		log.Printf("Applied migration %d: %s", migration.Version, migration.Description)
+	}
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config holds all configuration for the application
@@ -11,6 +12,12 @@ type Config struct {
 	Server   ServerConfig
 	Story    StoryConfig
 	Database DatabaseConfig
+	Admin    AdminConfig
+	Session  SessionConfig
+	CORS     CORSConfig
+	Mail     MailConfig
+	Account  AccountConfig
+	OIDC     OIDCConfig
 }
 
 // ServerConfig holds server-specific configuration
@@ -20,19 +27,77 @@ type ServerConfig struct {
 	ReadTimeout  int
 	WriteTimeout int
 	IdleTimeout  int
+	PublicURL    string // Base URL used in links sent by email, defaults to http://HOST:PORT
+	TrustProxy   bool   // Take client addresses from X-Forwarded-For
+	// Seconds each /readyz dependency check may take before it counts as
+	// failing
+	HealthCheckTimeout int
 }
 
 // StoryConfig holds story-specific configuration
 type StoryConfig struct {
-	DataFile    string
-	StaticDir   string
-	TemplateDir string
+	DataFile      string // Story file (.json, .yaml, .toml, .twee) or a directory of <gopher>/<arc> files
+	StaticDir     string
+	TemplateDir   string
+	WatchInterval int // Seconds between checks for story changes, 0 disables hot reload
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
-	MongoURI string
-	DBName   string
+	Storage    string // "mongo", "sqlite", or "memory" to run without a database
+	MongoURI   string
+	DBName     string
+	SQLitePath string // Database file when Storage is "sqlite"
+	// Seconds a single database operation may take before the request
+	// fails with a 504, 0 for no limit
+	OperationTimeout int
+	// Apply pending MongoDB migrations at startup. When false the server
+	// refuses to start until "gophertales migrate" has run them.
+	MigrateOnStart bool
+}
+
+// AdminConfig holds configuration for the admin API
+type AdminConfig struct {
+	Token  string   // Bearer token for /api/admin routes, empty only allows signed-in users with a role
+	Emails []string // Verified accounts promoted to admin at startup, to bootstrap the first admin
+}
+
+// SessionConfig holds configuration for login sessions
+type SessionConfig struct {
+	Secret       string // HMAC key for session cookies, a random key is generated when empty
+	TTL          int    // Hours a session stays valid without being used
+	SecureCookie bool   // Only send session cookies over HTTPS
+}
+
+// CORSConfig holds cross-origin request configuration
+type CORSConfig struct {
+	AllowedOrigins []string // Origins such as "https://example.com" allowed to call the API with credentials
+}
+
+// MailConfig holds outgoing email configuration
+type MailConfig struct {
+	SMTPHost     string // SMTP server, empty writes emails to OutboxDir instead
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+	OutboxDir    string // Directory for .eml files when SMTP is not configured, empty only logs them
+}
+
+// AccountConfig holds user account policy
+type AccountConfig struct {
+	RequireVerifiedEmail bool // Only save bookmarks and progress for users who verified their email
+	LockoutThreshold     int  // Failed logins that temporarily lock an account, 0 disables lockout
+	LockoutMinutes       int  // How long a locked account stays locked
+}
+
+// OIDCConfig holds the OpenID Connect provider for single sign-on
+type OIDCConfig struct {
+	IssuerURL    string // Provider issuer, such as https://accounts.google.com; empty disables single sign-on
+	ClientID     string
+	ClientSecret string // Empty for public clients, which rely on PKCE alone
+	RedirectURL  string // Callback registered with the provider, defaults to PUBLIC_URL + /auth/oidc/callback
+	ProviderName string // Shown on the "Sign in with ..." button
 }
 
 // Load reads configuration from environment variables with sensible defaults
@@ -44,15 +109,57 @@ func Load() *Config {
 			ReadTimeout:  getEnvAsInt("READ_TIMEOUT", 15),
 			WriteTimeout: getEnvAsInt("WRITE_TIMEOUT", 15),
 			IdleTimeout:  getEnvAsInt("IDLE_TIMEOUT", 60),
+			PublicURL:    getEnv("PUBLIC_URL", ""),
+			TrustProxy:   getEnvAsBool("TRUST_PROXY", false),
+
+			HealthCheckTimeout: getEnvAsInt("HEALTH_CHECK_TIMEOUT", 2),
 		},
 		Story: StoryConfig{
-			DataFile:    getEnv("STORY_DATA_FILE", "gopher_six.json"),
-			StaticDir:   getEnv("STATIC_DIR", "./static"),
-			TemplateDir: getEnv("TEMPLATE_DIR", "./templates"),
+			DataFile:      getEnv("STORY_DATA_FILE", "gopher_six.json"),
+			StaticDir:     getEnv("STATIC_DIR", "./static"),
+			TemplateDir:   getEnv("TEMPLATE_DIR", "./templates"),
+			WatchInterval: getEnvAsInt("STORY_WATCH_INTERVAL", 0),
 		},
 		Database: DatabaseConfig{
-			MongoURI: getEnv("MONGO_URI", ""),
-			DBName:   getEnv("DB_NAME", "gophertales"),
+			Storage:    strings.ToLower(getEnv("STORAGE", "mongo")),
+			MongoURI:   getEnv("MONGO_URI", ""),
+			DBName:     getEnv("DB_NAME", "gophertales"),
+			SQLitePath: getEnv("SQLITE_PATH", "gophertales.db"),
+
+			OperationTimeout: getEnvAsInt("DB_OPERATION_TIMEOUT", 5),
+			MigrateOnStart:   getEnvAsBool("MIGRATE_ON_START", true),
+		},
+		Admin: AdminConfig{
+			Token:  getEnv("ADMIN_TOKEN", ""),
+			Emails: getEnvAsList("ADMIN_EMAILS"),
+		},
+		Session: SessionConfig{
+			Secret:       getEnv("SESSION_SECRET", ""),
+			TTL:          getEnvAsInt("SESSION_TTL_HOURS", 24),
+			SecureCookie: getEnvAsBool("SESSION_SECURE_COOKIE", true),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: getEnvAsList("CORS_ALLOWED_ORIGINS"),
+		},
+		Mail: MailConfig{
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			From:         getEnv("MAIL_FROM", "GopherTales <no-reply@gophertales.local>"),
+			OutboxDir:    getEnv("MAIL_OUTBOX_DIR", ""),
+		},
+		Account: AccountConfig{
+			RequireVerifiedEmail: getEnvAsBool("REQUIRE_VERIFIED_EMAIL", false),
+			LockoutThreshold:     getEnvAsInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+			LockoutMinutes:       getEnvAsInt("LOGIN_LOCKOUT_MINUTES", 15),
+		},
+		OIDC: OIDCConfig{
+			IssuerURL:    getEnv("OIDC_ISSUER_URL", ""),
+			ClientID:     getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+			ProviderName: getEnv("OIDC_PROVIDER_NAME", "Single Sign-On"),
 		},
 	}
 }
@@ -62,6 +169,22 @@ func (c *Config) Address() string {
 	return c.Server.Host + ":" + c.Server.Port
 }
 
+// BaseURL returns the public URL of the site without a trailing slash
+func (c *Config) BaseURL() string {
+	if c.Server.PublicURL != "" {
+		return strings.TrimSuffix(c.Server.PublicURL, "/")
+	}
+	return "http://" + c.Address()
+}
+
+// OIDCRedirectURL returns the single sign-on callback URL
+func (c *Config) OIDCRedirectURL() string {
+	if c.OIDC.RedirectURL != "" {
+		return c.OIDC.RedirectURL
+	}
+	return c.BaseURL() + "/auth/oidc/callback"
+}
+
 // getEnv gets an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -80,3 +203,25 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsBool gets an environment variable as boolean or returns a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+		log.Printf("Warning: Invalid boolean value for %s: %s, using default %t", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list, skipping empty entries
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
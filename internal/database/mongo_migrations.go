@@ -0,0 +1,353 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoMigration is one versioned change to the MongoDB indexes or data.
+// Replicas starting together may both run a migration before either records
+// it, so Up must be safe to run twice.
+type MongoMigration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   time.Time // Zero while pending
+}
+
+// mongoMigrations are applied in version order. Add new ones at the end and
+// never change one that may already have run.
+var mongoMigrations = []MongoMigration{
+	{Version: 1, Description: "lowercase and trim user emails", Up: normalizeEmails},
+	{Version: 2, Description: "create user indexes", Up: createUserIndexes},
+	{Version: 3, Description: "create session and token indexes", Up: createSessionIndexes},
+	{Version: 4, Description: "create login attempt indexes", Up: createLoginAttemptIndexes},
+	{Version: 5, Description: "convert progress to reading_progress", Up: convertLegacyProgress},
+	{Version: 6, Description: "create story state indexes", Up: createStoryStateIndexes},
+}
+
+// appliedMigration is a document in the "schema_migrations" collection
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// Migrate applies the migrations not yet recorded in "schema_migrations"
+// and returns the ones it ran
+func (m *MongoDB) Migrate(ctx context.Context) ([]MongoMigration, error) {
+	pending, err := m.PendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := m.Database.Collection("schema_migrations")
+	for i, migration := range pending {
+		if err := migration.Up(ctx, m.Database); err != nil {
+			return pending[:i], fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+
+		_, err := records.InsertOne(ctx, appliedMigration{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now(),
+		})
+		// Another replica finished it first
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return pending[:i], fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		}
+	}
+	return pending, nil
+}
+
+// PendingMigrations returns the migrations Migrate would run
+func (m *MongoDB) PendingMigrations(ctx context.Context) ([]MongoMigration, error) {
+	statuses, err := m.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []MongoMigration
+	for i, status := range statuses {
+		if status.AppliedAt.IsZero() {
+			pending = append(pending, mongoMigrations[i])
+		}
+	}
+	return pending, nil
+}
+
+// MigrationStatus lists every known migration in version order with when
+// it was applied
+func (m *MongoDB) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	cursor, err := m.Database.Collection("schema_migrations").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	var applied []appliedMigration
+	if err := cursor.All(ctx, &applied); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	appliedAt := make(map[int]time.Time, len(applied))
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, len(mongoMigrations))
+	for i, migration := range mongoMigrations {
+		statuses[i] = MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   appliedAt[migration.Version],
+		}
+	}
+	return statuses, nil
+}
+
+// normalizeEmails fixes accounts created before emails were normalized, so
+// the unique email index can be built. Accounts whose emails only differ in
+// case or surrounding spaces are left for an operator to merge, since
+// picking one would lock the others out.
+func normalizeEmails(ctx context.Context, db *mongo.Database) error {
+	if err := checkDuplicateEmails(ctx, db.Collection("users")); err != nil {
+		return err
+	}
+
+	_, err := db.Collection("users").UpdateMany(ctx,
+		bson.M{"email": bson.M{"$regex": `[A-Z]|^\s|\s$`}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"email": bson.M{"$toLower": bson.M{"$trim": bson.M{"input": "$email"}}},
+		}}}},
+	)
+	return err
+}
+
+// createUserIndexes makes emails unique, so concurrent registrations cannot
+// create duplicate accounts, and allows one account per external identity
+func createUserIndexes(ctx context.Context, db *mongo.Database) error {
+	if err := checkDuplicateEmails(ctx, db.Collection("users")); err != nil {
+		return err
+	}
+
+	_, err := db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// Accounts without external identities are skipped
+			Keys: bson.D{{Key: "identities.issuer", Value: 1}, {Key: "identities.subject", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"identities.subject": bson.M{"$exists": true},
+			}),
+		},
+	})
+	return err
+}
+
+// checkDuplicateEmails fails, listing the accounts involved, when several
+// accounts have the same email once it is lowercased and trimmed
+func checkDuplicateEmails(ctx context.Context, users *mongo.Collection) error {
+	cursor, err := users.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"$toLower": bson.M{"$trim": bson.M{"input": "$email"}}},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to look for duplicate emails: %w", err)
+	}
+	var duplicates []struct {
+		Email string               `bson:"_id"`
+		IDs   []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return fmt.Errorf("failed to look for duplicate emails: %w", err)
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	conflicts := make([]string, len(duplicates))
+	for i, d := range duplicates {
+		ids := make([]string, len(d.IDs))
+		for j, id := range d.IDs {
+			ids[j] = id.Hex()
+		}
+		conflicts[i] = fmt.Sprintf("%s (%s)", d.Email, strings.Join(ids, ", "))
+	}
+	return fmt.Errorf("emails shared by several accounts, merge or change them first: %s", strings.Join(conflicts, "; "))
+}
+
+// createSessionIndexes lets MongoDB delete sessions and one-time tokens
+// once they expire, and indexes the lookups that revoke them per user
+func createSessionIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("sessions").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}},
+		},
+	})
+	return err
+}
+
+// createLoginAttemptIndexes expires failed login counters, and the audit
+// trail after 90 days
+func createLoginAttemptIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("login_counters").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("login_attempts").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "timestamp", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32((90 * 24 * time.Hour).Seconds())),
+		},
+		{
+			Keys: bson.D{{Key: "email", Value: 1}, {Key: "timestamp", Value: -1}},
+		},
+	})
+	return err
+}
+
+// createStoryStateIndexes lets MongoDB delete the story state of abandoned
+// playthroughs
+func createStoryStateIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("story_states").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// convertLegacyProgress moves the old "progress" field into
+// "reading_progress". It held a number per gopher, 10 once a reader opened
+// an arc and 100 once they reached an ending, without saying which arcs;
+// each gopher becomes a record with no visits, started when the account was
+// last updated, and marked legacy_completed if it was at 100. Progress
+// already kept in the new form wins, except that it keeps the completion.
+func convertLegacyProgress(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("users")
+
+	// Accounts that never had progress may have a null reading_progress,
+	// which dotted updates such as RecordVisit's cannot write into
+	_, err := users.UpdateMany(ctx,
+		bson.M{"reading_progress": nil},
+		bson.M{"$set": bson.M{"reading_progress": bson.M{}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := users.Find(ctx, bson.M{"progress": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"progress": 1, "reading_progress": 1, "updated_at": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user legacyProgressUser
+		if err := cursor.Decode(&user); err != nil {
+			return fmt.Errorf("failed to read progress of user %v: %w", cursor.Current.Lookup("_id"), err)
+		}
+		// Only touch accounts still in the old form, in case another
+		// replica converted this one meanwhile
+		_, err := users.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "progress": bson.M{"$exists": true}},
+			legacyProgressUpdate(user, time.Now()),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to convert progress of user %v: %w", user.ID, err)
+		}
+	}
+	return cursor.Err()
+}
+
+// legacyProgressUser is the part of a user document convertLegacyProgress
+// reads
+type legacyProgressUser struct {
+	ID              interface{}        `bson:"_id"`
+	Progress        map[string]float64 `bson:"progress"`
+	ReadingProgress bson.M             `bson:"reading_progress"`
+	UpdatedAt       time.Time          `bson:"updated_at"`
+}
+
+// legacyProgressUpdate builds the update that converts one user's progress.
+// Gophers are set one by one, so a visit recorded by a running server while
+// the migration runs is not overwritten.
+func legacyProgressUpdate(user legacyProgressUser, now time.Time) bson.M {
+	startedAt := user.UpdatedAt
+	if startedAt.IsZero() {
+		startedAt = now
+	}
+
+	set := bson.M{}
+	for gopher, completion := range user.Progress {
+		// RecordVisit rejects these names, so nobody could read them
+		if gopher == "" || strings.ContainsAny(gopher, ".$") {
+			continue
+		}
+		completed := completion >= 100
+
+		if _, kept := user.ReadingProgress[gopher]; kept {
+			if completed {
+				set["reading_progress."+gopher+".legacy_completed"] = true
+			}
+			continue
+		}
+		record := bson.M{
+			"visited_arcs":    bson.A{},
+			"path":            bson.A{},
+			"endings_reached": bson.A{},
+			"started_at":      startedAt,
+			"updated_at":      startedAt,
+		}
+		if completed {
+			record["legacy_completed"] = true
+		}
+		set["reading_progress."+gopher] = record
+	}
+
+	update := bson.M{"$unset": bson.M{"progress": ""}}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	return update
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestMongoMigrations_Ordered(t *testing.T) {
+	for i, migration := range mongoMigrations {
+		if migration.Version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %d", i, i+1, migration.Version)
+		}
+		if migration.Description == "" || migration.Up == nil {
+			t.Errorf("Expected migration %d to have a description and an Up function", migration.Version)
+		}
+	}
+}
+
+func TestNormalizeEmails_Duplicates(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+
+	mt.Run("duplicates abort before writing", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "gophertales.users", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: "ada@example.com"},
+			{Key: "ids", Value: bson.A{first, second}},
+			{Key: "count", Value: 2},
+		}))
+
+		err := normalizeEmails(context.Background(), mt.DB)
+		if err == nil {
+			t.Fatalf("Expected duplicate emails to fail the migration")
+		}
+		for _, want := range []string{"ada@example.com", first.Hex(), second.Hex()} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error to mention %s, got %v", want, err)
+			}
+		}
+		var commands []string
+		for _, event := range mt.GetAllStartedEvents() {
+			commands = append(commands, event.CommandName)
+		}
+		if strings.Join(commands, ",") != "aggregate" {
+			t.Errorf("Expected only the aggregate and no writes, got %v", commands)
+		}
+	})
+
+	mt.Run("no duplicates", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "gophertales.users", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		if err := normalizeEmails(context.Background(), mt.DB); err != nil {
+			t.Errorf("Expected emails to be normalized, got %v", err)
+		}
+	})
+}
+
+func TestLegacyProgressUpdate(t *testing.T) {
+	updatedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	id := primitive.NewObjectID()
+
+	// A user as the first release stored them, who later started reading
+	// green with the new progress records
+	raw, err := bson.Marshal(bson.M{
+		"_id":        id,
+		"email":      "ada@example.com",
+		"progress":   bson.M{"blue": int32(100), "red": int32(10), "green": 100.0, "bad.name": int32(100)},
+		"updated_at": updatedAt,
+		"reading_progress": bson.M{
+			"green": bson.M{"visited_arcs": bson.A{"intro"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal legacy user: %v", err)
+	}
+	var user legacyProgressUser
+	if err := bson.Unmarshal(raw, &user); err != nil {
+		t.Fatalf("Failed to decode legacy user: %v", err)
+	}
+
+	update := legacyProgressUpdate(user, time.Now())
+
+	if _, ok := update["$unset"].(bson.M)["progress"]; !ok {
+		t.Errorf("Expected the old progress field to be removed, got %v", update)
+	}
+	set := update["$set"].(bson.M)
+	if len(set) != 3 {
+		t.Errorf("Expected blue, red and green's completion to be set, got %v", set)
+	}
+
+	blue, _ := set["reading_progress.blue"].(bson.M)
+	if blue["legacy_completed"] != true || blue["started_at"] != updatedAt {
+		t.Errorf("Expected blue completed and started at %v, got %v", updatedAt, blue)
+	}
+	red, _ := set["reading_progress.red"].(bson.M)
+	if _, completed := red["legacy_completed"]; completed || red == nil {
+		t.Errorf("Expected red started but not completed, got %v", red)
+	}
+	if set["reading_progress.green.legacy_completed"] != true {
+		t.Errorf("Expected green's existing record kept and marked completed, got %v", set)
+	}
+	if _, ok := set["reading_progress.green"]; ok {
+		t.Errorf("Expected green's existing record not to be replaced")
+	}
+}
@@ -7,11 +7,13 @@ import (
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type MongoDB struct {
 	Client   *mongo.Client
 	Database *mongo.Database
+	timeout  time.Duration
 }
 
 func NewMongoDB(connectionString, dbName string) (*MongoDB, error) {
@@ -38,3 +40,29 @@ func (m *MongoDB) Close() error {
 	defer cancel()
 	return m.Client.Disconnect(ctx)
 }
+
+// SetOperationTimeout bounds every query made through WithTimeout. Zero
+// leaves queries bounded only by their caller's context.
+func (m *MongoDB) SetOperationTimeout(timeout time.Duration) {
+	m.timeout = timeout
+}
+
+// WithTimeout returns a context for one query, cancelled after the
+// operation timeout or when ctx is
+func (m *MongoDB) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, m.timeout)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Ping checks that the primary can be reached within the operation timeout
+func (m *MongoDB) Ping(ctx context.Context) error {
+	ctx, cancel := m.WithTimeout(ctx)
+	defer cancel()
+	return m.Client.Ping(ctx, readpref.Primary())
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed sqlite_migrations/*.sql
+var sqliteMigrations embed.FS
+
+type SQLite struct {
+	DB      *sql.DB
+	timeout time.Duration
+}
+
+// NewSQLite opens the database file, creating it and its directory if
+// needed, and applies any schema migrations it has not seen yet
+func NewSQLite(path string) (*SQLite, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection serializes
+	// writes in Go instead of failing them with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	s := &SQLite{DB: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLite) Close() error {
+	return s.DB.Close()
+}
+
+// SetOperationTimeout bounds every query made through WithTimeout. Zero
+// leaves queries bounded only by their caller's context.
+func (s *SQLite) SetOperationTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// WithTimeout returns a context for one query, cancelled after the
+// operation timeout or when ctx is
+func (s *SQLite) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, s.timeout)
+}
+
+// Ping checks that the database file can still be reached within the
+// operation timeout
+func (s *SQLite) Ping(ctx context.Context) error {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	return s.DB.PingContext(ctx)
+}
+
+// migrate applies the embedded migrations in version order. Each runs in a
+// transaction together with its row in schema_migrations, so a failed
+// migration leaves the database as it was.
+func (s *SQLite) migrate() error {
+	_, err := s.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := s.DB.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(sqliteMigrations, "sqlite_migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		base := filepath.Base(name)
+		prefix, _, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("migration %s does not start with a version number", base)
+		}
+		if applied[version] {
+			continue
+		}
+
+		script, err := sqliteMigrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := s.apply(version, base, string(script)); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", base, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLite) apply(version int, name, script string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		version, name, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsUniqueViolation reports whether err is a SQLite unique or primary key
+// constraint failure
+func IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
@@ -0,0 +1,111 @@
+-- Times are stored as Unix milliseconds and IDs as hex ObjectIDs, so
+-- accounts keep the same IDs whichever storage backend holds them.
+
+CREATE TABLE users (
+    id                   TEXT PRIMARY KEY,
+    name                 TEXT NOT NULL,
+    email                TEXT NOT NULL UNIQUE,
+    password_hash        TEXT NOT NULL DEFAULT '',
+    role                 TEXT NOT NULL DEFAULT 'reader',
+    email_verified       INTEGER NOT NULL DEFAULT 0,
+    verification_sent_at INTEGER,
+    totp_enabled         INTEGER NOT NULL DEFAULT 0,
+    totp_secret          TEXT NOT NULL DEFAULT '',
+    totp_pending_secret  TEXT NOT NULL DEFAULT '',
+    totp_last_step       INTEGER NOT NULL DEFAULT 0,
+    created_at           INTEGER NOT NULL,
+    updated_at           INTEGER NOT NULL
+);
+
+CREATE TABLE user_identities (
+    issuer    TEXT NOT NULL,
+    subject   TEXT NOT NULL,
+    user_id   TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    linked_at INTEGER NOT NULL,
+    PRIMARY KEY (issuer, subject)
+);
+CREATE INDEX user_identities_user_id ON user_identities (user_id);
+
+CREATE TABLE recovery_codes (
+    user_id   TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+-- Reading progress, one row per user and gopher. Visited arcs and endings
+-- are sets kept in first-visit order; path_steps is the trimmed history.
+CREATE TABLE reading_progress (
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    gopher     TEXT NOT NULL,
+    started_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    PRIMARY KEY (user_id, gopher)
+);
+
+CREATE TABLE visited_arcs (
+    user_id TEXT NOT NULL,
+    gopher  TEXT NOT NULL,
+    arc     TEXT NOT NULL,
+    ending  INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (user_id, gopher, arc),
+    FOREIGN KEY (user_id, gopher) REFERENCES reading_progress (user_id, gopher) ON DELETE CASCADE
+);
+
+CREATE TABLE path_steps (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    TEXT NOT NULL,
+    gopher     TEXT NOT NULL,
+    arc        TEXT NOT NULL,
+    visited_at INTEGER NOT NULL,
+    FOREIGN KEY (user_id, gopher) REFERENCES reading_progress (user_id, gopher) ON DELETE CASCADE
+);
+CREATE INDEX path_steps_user_gopher ON path_steps (user_id, gopher, id);
+
+CREATE TABLE bookmarks (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    gopher     TEXT NOT NULL,
+    arc        TEXT NOT NULL,
+    title      TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+CREATE INDEX bookmarks_user_id ON bookmarks (user_id, id);
+
+CREATE TABLE sessions (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    created_at   INTEGER NOT NULL,
+    last_seen_at INTEGER NOT NULL,
+    expires_at   INTEGER NOT NULL
+);
+CREATE INDEX sessions_user_id ON sessions (user_id);
+CREATE INDEX sessions_expires_at ON sessions (expires_at);
+
+CREATE TABLE tokens (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    purpose    TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    used_at    INTEGER
+);
+CREATE INDEX tokens_user_purpose ON tokens (user_id, purpose);
+CREATE INDEX tokens_expires_at ON tokens (expires_at);
+
+CREATE TABLE login_counters (
+    key             TEXT PRIMARY KEY,
+    failures        INTEGER NOT NULL,
+    last_failure_at INTEGER NOT NULL,
+    expires_at      INTEGER NOT NULL
+);
+CREATE INDEX login_counters_expires_at ON login_counters (expires_at);
+
+CREATE TABLE login_attempts (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    email     TEXT NOT NULL,
+    ip        TEXT NOT NULL,
+    reason    TEXT NOT NULL,
+    timestamp INTEGER NOT NULL
+);
+CREATE INDEX login_attempts_email ON login_attempts (email, timestamp);
+CREATE INDEX login_attempts_timestamp ON login_attempts (timestamp);
@@ -0,0 +1,10 @@
+-- Story variables per playthrough and gopher, shared by every replica.
+-- vars holds the variables as a JSON object.
+
+CREATE TABLE story_states (
+    id         TEXT PRIMARY KEY,
+    vars       TEXT NOT NULL,
+    last_arc   TEXT NOT NULL,
+    expires_at INTEGER NOT NULL
+);
+CREATE INDEX story_states_expires_at ON story_states (expires_at);
@@ -0,0 +1,3 @@
+-- When the last password reset email was sent, to throttle resends.
+
+ALTER TABLE users ADD COLUMN reset_sent_at INTEGER;
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewSQLite_MigratesOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "gophertales.db")
+	migrations, _ := filepath.Glob("sqlite_migrations/*.sql")
+
+	for i := 0; i < 2; i++ {
+		db, err := NewSQLite(path)
+		if err != nil {
+			t.Fatalf("Failed to open database (attempt %d): %v", i+1, err)
+		}
+
+		var applied int
+		if err := db.DB.QueryRow(`SELECT count(*) FROM schema_migrations`).Scan(&applied); err != nil {
+			t.Fatalf("Failed to count migrations: %v", err)
+		}
+		if applied != len(migrations) {
+			t.Errorf("Expected %d applied migrations, got %d", len(migrations), applied)
+		}
+		db.Close()
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	db, err := NewSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	insert := `INSERT INTO login_counters (key, failures, last_failure_at, expires_at) VALUES ('k', 1, 0, 0)`
+	if _, err := db.DB.Exec(insert); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	_, err = db.DB.Exec(insert)
+	if !IsUniqueViolation(err) {
+		t.Errorf("Expected a unique violation, got %v", err)
+	}
+	if IsUniqueViolation(nil) {
+		t.Errorf("Expected nil not to be a unique violation")
+	}
+}
+
+func TestSQLite_WithTimeout(t *testing.T) {
+	db, err := NewSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := db.WithTimeout(context.Background())
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("Expected no deadline without an operation timeout")
+	}
+	cancel()
+
+	db.SetOperationTimeout(time.Minute)
+	ctx, cancel = db.WithTimeout(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Expected a deadline within a minute, got %v, %v", deadline, ok)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"GopherTales/internal/middleware"
+	"GopherTales/internal/models"
+	"GopherTales/internal/services"
+)
+
+// AdminHandler handles admin API requests
+type AdminHandler struct {
+	storyService *services.StoryService
+	userService  *services.UserService
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(storyService *services.StoryService, userService *services.UserService) *AdminHandler {
+	return &AdminHandler{
+		storyService: storyService,
+		userService:  userService,
+	}
+}
+
+// ReloadStory re-reads the story from disk. The new story only replaces the
+// current one if it loads and validates; otherwise the errors are returned
+// and readers keep the version they had.
+func (h *AdminHandler) ReloadStory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := http.StatusOK
+	var response map[string]any
+
+	if err := h.storyService.Reload(); err != nil {
+		log.Printf("Story reload failed, keeping the current story: %v", err)
+		status = http.StatusUnprocessableEntity
+		response = map[string]any{
+			"status": "failed",
+			"error":  err.Error(),
+		}
+
+		var validationErr *services.StoryValidationError
+		if errors.As(err, &validationErr) {
+			response["issues"] = validationErr.Issues
+		}
+	} else {
+		log.Printf("Story reloaded from %s", h.storyService.DataFile())
+		response = map[string]any{
+			"status": "reloaded",
+			"stats":  h.storyService.GetStoryStats(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding reload response: %v", err)
+	}
+}
+
+// SetUserRole changes the role of the account with the given email. Admins
+// cannot demote themselves, so there is always someone left to undo mistakes.
+func (h *AdminHandler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Email string      `json:"email"`
+		Role  models.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if current, ok := middleware.UserFromContext(r.Context()); ok &&
+		current.Email == services.NormalizeEmail(req.Email) && req.Role != models.RoleAdmin {
+		http.Error(w, "Admins cannot remove their own admin role", http.StatusConflict)
+		return
+	}
+
+	user, err := h.userService.SetRole(r.Context(), req.Email, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRole):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			if middleware.WriteTimeoutError(w, r, err) {
+				return
+			}
+			log.Printf("Error setting user role: %v", err)
+			http.Error(w, "Failed to set role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("Role of %s set to %s", user.Email, user.Role)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user":    user,
+	})
+}
@@ -21,27 +21,6 @@ func NewAPIHandler(storyService *services.StoryService) *APIHandler {
 	}
 }
 
-// HealthCheck handles health check requests
-func (a *APIHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	response := map[string]any{
-		"status":  "healthy",
-		"service": "GopherTales",
-		"version": "1.0.0",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding health check response: %v", err)
-	}
-}
-
 // GetStoryStats returns statistics about the loaded story
 func (a *APIHandler) GetStoryStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -106,7 +85,7 @@ func (a *APIHandler) GetArc(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	if gopher != "" {
-		arc, finalArcName, err = a.storyService.GetGopherArc(gopher, arcName)
+		arc, finalArcName, err = a.storyService.GetGopherArc(gopher, arcName, nil)
 	} else {
 		arc, finalArcName, err = a.storyService.GetArc(arcName)
 	}
@@ -132,14 +111,14 @@ func (a *APIHandler) GetArc(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// GetGophers returns all available gopher characters
+// GetGophers returns the catalog of gopher characters that have a story
 func (a *APIHandler) GetGophers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gophers := a.storyService.GetAvailableGophers()
+	gophers := a.storyService.GetCatalog()
 
 	response := map[string]any{
 		"gophers": gophers,
@@ -172,3 +151,73 @@ func (a *APIHandler) GetGopherStats(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
+
+// GetStoryAnalysis returns the story graph analysis for one gopher, or for
+// every gopher when no gopher is given
+func (a *APIHandler) GetStoryAnalysis(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var response any
+	if gopher := r.URL.Query().Get("gopher"); gopher != "" {
+		analysis, err := a.storyService.AnalyzeGopher(gopher)
+		if err != nil {
+			http.Error(w, "Gopher not found", http.StatusNotFound)
+			return
+		}
+		response = analysis
+	} else {
+		response = a.storyService.AnalyzeStory()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding story analysis response: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// GetStoryGraph returns a gopher's story graph as a Graphviz DOT or Mermaid diagram
+func (a *APIHandler) GetStoryGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	gopher := r.URL.Query().Get("gopher")
+	if gopher == "" {
+		http.Error(w, "Gopher is required", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = services.GraphFormatDOT
+	}
+	if !services.IsGraphFormat(format) {
+		http.Error(w, "Format must be dot or mermaid", http.StatusBadRequest)
+		return
+	}
+
+	graph, err := a.storyService.ExportGraph(gopher, format)
+	if err != nil {
+		log.Printf("Error exporting graph for gopher '%s': %v", gopher, err)
+		http.Error(w, "Gopher not found", http.StatusNotFound)
+		return
+	}
+
+	if format == services.GraphFormatDOT {
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write([]byte(graph)); err != nil {
+		log.Printf("Error writing story graph response: %v", err)
+	}
+}
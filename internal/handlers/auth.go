@@ -2,21 +2,34 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson/primitive"
-
+	"GopherTales/internal/middleware"
 	"GopherTales/internal/models"
 	"GopherTales/internal/services"
 )
 
 type AuthHandler struct {
-	userService *services.UserService
+	userService   *services.UserService
+	sessions      *services.SessionManager
+	verifications *services.EmailVerificationService
+	loginGuard    *services.LoginGuard
+	twoFactor     *services.TwoFactorService
 }
 
-func NewAuthHandler(userService *services.UserService) *AuthHandler {
-	return &AuthHandler{userService: userService}
+func NewAuthHandler(userService *services.UserService, sessions *services.SessionManager, verifications *services.EmailVerificationService, loginGuard *services.LoginGuard, twoFactor *services.TwoFactorService) *AuthHandler {
+	return &AuthHandler{
+		userService:   userService,
+		sessions:      sessions,
+		verifications: verifications,
+		loginGuard:    loginGuard,
+		twoFactor:     twoFactor,
+	}
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -31,20 +44,29 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userService.Register(req.Name, req.Email, req.Password)
+	user, err := h.userService.Register(r.Context(), req.Name, req.Email, req.Password)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if writeValidationError(w, err) || middleware.WriteTimeoutError(w, r, err) {
+			return
+		}
+		log.Printf("Error registering user: %v", err)
+		http.Error(w, "Registration failed", http.StatusInternalServerError)
 		return
 	}
 
-	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "user_id",
-		Value:    user.ID.Hex(),
-		Expires:  time.Now().Add(24 * time.Hour),
-		HttpOnly: true,
-		Path:     "/",
-	})
+	// A failed email does not fail the signup, the user can ask for a resend
+	if err := h.verifications.SendVerification(r.Context(), user); err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+
+	if _, err := h.sessions.Start(r.Context(), w, user.ID); err != nil {
+		if middleware.WriteTimeoutError(w, r, err) {
+			return
+		}
+		log.Printf("Error starting session: %v", err)
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -66,20 +88,126 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userService.Login(req.Email, req.Password)
+	// The attempt is counted before the password is checked, so concurrent
+	// guesses cannot all get past the throttle
+	attempt, err := h.loginGuard.Reserve(r.Context(), middleware.ClientIP(r), req.Email)
+	if err != nil {
+		var throttled *services.LoginThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			http.Error(w, throttled.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if middleware.WriteTimeoutError(w, r, err) {
+			return
+		}
+		log.Printf("Error checking login attempts: %v", err)
+		http.Error(w, "Login failed", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.userService.Login(r.Context(), req.Email, req.Password)
 	if err != nil {
+		if !errors.Is(err, services.ErrInvalidCredentials) {
+			if err := attempt.Release(r.Context()); err != nil {
+				log.Printf("Error releasing login attempt: %v", err)
+			}
+			if middleware.WriteTimeoutError(w, r, err) {
+				return
+			}
+			log.Printf("Error logging in: %v", err)
+			http.Error(w, "Login failed", http.StatusInternalServerError)
+			return
+		}
+		attempt.Failed(r.Context())
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
+	if err := attempt.Succeeded(r.Context()); err != nil {
+		log.Printf("Error releasing login attempt: %v", err)
+	}
 
-	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "user_id",
-		Value:    user.ID.Hex(),
-		Expires:  time.Now().Add(24 * time.Hour),
-		HttpOnly: true,
-		Path:     "/",
-	})
+	// The account's failures are only cleared once the second factor is
+	// checked, so knowing the password does not reset guessing at codes
+	if user.TOTPEnabled {
+		token, err := h.twoFactor.BeginLogin(r.Context(), user)
+		if err != nil {
+			if middleware.WriteTimeoutError(w, r, err) {
+				return
+			}
+			log.Printf("Error starting two-factor login: %v", err)
+			http.Error(w, "Login failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":             true,
+			"two_factor_required": true,
+			"two_factor_token":    token,
+		})
+		return
+	}
+
+	h.completeLogin(w, r, user)
+}
+
+// LoginTwoFactor finishes a login for an account with two-factor
+// authentication, given the token from Login and a TOTP or recovery code
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+		Code  string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.twoFactor.CompleteLogin(r.Context(), req.Token, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidToken), errors.Is(err, services.ErrTOTPNotEnabled):
+			// Two-factor authentication may have been turned off since the
+			// password was checked, so the login has to start over
+			http.Error(w, "Login expired, please sign in again", http.StatusUnauthorized)
+		case errors.Is(err, services.ErrInvalidTOTPCode):
+			if err := h.loginGuard.RecordFailure(r.Context(), middleware.ClientIP(r), user.Email); err != nil {
+				log.Printf("Error recording failed login: %v", err)
+			}
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			if middleware.WriteTimeoutError(w, r, err) {
+				return
+			}
+			log.Printf("Error completing two-factor login: %v", err)
+			http.Error(w, "Login failed", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.completeLogin(w, r, user)
+}
+
+// completeLogin clears the account's failed logins and starts a session
+func (h *AuthHandler) completeLogin(w http.ResponseWriter, r *http.Request, user *models.User) {
+	if err := h.loginGuard.RecordSuccess(r.Context(), user.Email); err != nil {
+		log.Printf("Error clearing failed logins: %v", err)
+	}
+
+	if _, err := h.sessions.Start(r.Context(), w, user.ID); err != nil {
+		if middleware.WriteTimeoutError(w, r, err) {
+			return
+		}
+		log.Printf("Error starting session: %v", err)
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -95,13 +223,9 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "user_id",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: true,
-		Path:     "/",
-	})
+	if err := h.sessions.End(w, r); err != nil {
+		log.Printf("Error revoking session: %v", err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -109,22 +233,67 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *AuthHandler) AddBookmark(w http.ResponseWriter, r *http.Request) {
+// LogoutAll revokes every session of the signed-in user, on every device
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get user from cookie
-	cookie, err := r.Cookie("user_id")
-	if err != nil {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(cookie.Value)
+	revoked, err := h.sessions.EndAll(r.Context(), w, user.ID)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		if middleware.WriteTimeoutError(w, r, err) {
+			return
+		}
+		log.Printf("Error revoking sessions: %v", err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":          true,
+		"sessions_revoked": revoked,
+	})
+}
+
+// writeValidationError writes a *services.ValidationError as a 400 with its
+// field errors, reporting whether err was one
+func writeValidationError(w http.ResponseWriter, err error) bool {
+	var validationErr *services.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  validationErr.Error(),
+		"fields": validationErr.Fields,
+	})
+	return true
+}
+
+func (h *AuthHandler) AddBookmark(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !h.userService.CanSaveProgress(user) {
+		http.Error(w, "Verify your email to save bookmarks", http.StatusForbidden)
 		return
 	}
 
@@ -149,7 +318,10 @@ func (h *AuthHandler) AddBookmark(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Add bookmark
-	if err := h.userService.AddBookmark(userID, bookmark); err != nil {
+	if err := h.userService.AddBookmark(r.Context(), user.ID, bookmark); err != nil {
+		if middleware.WriteTimeoutError(w, r, err) {
+			return
+		}
 		http.Error(w, "Failed to add bookmark", http.StatusInternalServerError)
 		return
 	}
@@ -4,20 +4,19 @@ import (
 	"html/template"
 	"net/http"
 
-	"go.mongodb.org/mongo-driver/bson/primitive"
-
+	"GopherTales/internal/middleware"
 	"GopherTales/internal/services"
 )
 
 type DashboardHandler struct {
-	userService *services.UserService
-	templateDir string
+	storyService *services.StoryService
+	templateDir  string
 }
 
-func NewDashboardHandler(userService *services.UserService, templateDir string) *DashboardHandler {
+func NewDashboardHandler(storyService *services.StoryService, templateDir string) *DashboardHandler {
 	return &DashboardHandler{
-		userService: userService,
-		templateDir: templateDir,
+		storyService: storyService,
+		templateDir:  templateDir,
 	}
 }
 
@@ -27,27 +26,23 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user from cookie
-	cookie, err := r.Cookie("user_id")
-	if err != nil {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-		return
-	}
-
-	userID, err := primitive.ObjectIDFromHex(cookie.Value)
-	if err != nil {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
 
-	user, err := h.userService.GetUserByID(userID)
-	if err != nil {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-		return
+	progress := h.storyService.SummarizeAllProgress(user.Progress)
+	endingsFound := 0
+	for _, p := range progress {
+		endingsFound += p.EndingsFound
 	}
 
 	data := map[string]interface{}{
-		"User": user,
+		"User":         user,
+		"Progress":     progress,
+		"EndingsFound": endingsFound,
+		"CSRFToken":    middleware.CSRFTokenFromContext(r.Context()),
 	}
 
 	tmpl, err := template.ParseFiles(h.templateDir + "/dashboard.html")
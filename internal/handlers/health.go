@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"GopherTales/internal/services"
+	"GopherTales/internal/version"
+)
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	checker *services.HealthChecker
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(checker *services.HealthChecker) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+	}
+}
+
+// Livez reports that the process is up and serving HTTP. It checks no
+// dependencies, so a database outage takes replicas out of rotation through
+// Readyz instead of getting them all restarted.
+func (h *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeHealth(w, http.StatusOK, map[string]any{
+		"status":  "ok",
+		"service": "GopherTales",
+		"version": version.Version,
+		"commit":  version.Commit,
+	})
+}
+
+// Readyz reports whether the server can handle requests, with the status of
+// each dependency. It answers 503 while any check fails so load balancers
+// stop sending traffic. Why a check failed is only logged, since the probe
+// is public and errors can name internal hosts.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ready, checks := h.checker.Check(r.Context())
+	for name, check := range checks {
+		if check.Error != "" {
+			log.Printf("Readiness check %s failing: %s", name, check.Error)
+		}
+	}
+
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	writeHealth(w, code, map[string]any{
+		"status":  status,
+		"service": "GopherTales",
+		"version": version.Version,
+		"commit":  version.Commit,
+		"checks":  checks,
+	})
+}
+
+func writeHealth(w http.ResponseWriter, code int, response map[string]any) {
+	// Probes must see the current state, never a cached one
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding health check response: %v", err)
+	}
+}
@@ -5,23 +5,18 @@ import (
 	"log"
 	"net/http"
 
-	"go.mongodb.org/mongo-driver/bson/primitive"
-
-	"GopherTales/internal/models"
-	"GopherTales/internal/services"
+	"GopherTales/internal/middleware"
 )
 
 // HomeHandler handles the home page requests
 type HomeHandler struct {
 	templateDir string
-	userService *services.UserService
 }
 
 // NewHomeHandler creates a new home handler
-func NewHomeHandler(templateDir string, userService *services.UserService) *HomeHandler {
+func NewHomeHandler(templateDir string) *HomeHandler {
 	return &HomeHandler{
 		templateDir: templateDir,
-		userService: userService,
 	}
 }
 
@@ -46,18 +41,12 @@ func (h *HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	// Check if user is logged in
-	var user *models.User
-	if cookie, err := r.Cookie("user_id"); err == nil {
-		if userID, err := primitive.ObjectIDFromHex(cookie.Value); err == nil {
-			if u, err := h.userService.GetUserByID(userID); err == nil {
-				user = u
-			}
-		}
-	}
+	user, _ := middleware.UserFromContext(r.Context())
 
 	data := map[string]interface{}{
 		"User":       user,
 		"IsLoggedIn": user != nil,
+		"CSRFToken":  middleware.CSRFTokenFromContext(r.Context()),
 	}
 
 	// Execute the template
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"GopherTales/internal/middleware"
+	"GopherTales/internal/services"
+)
+
+// oidcFlowCookieName holds the signed state of a sign-in in progress
+const oidcFlowCookieName = "oidc_flow"
+
+// OIDCHandler signs users in with an OpenID Connect provider
+type OIDCHandler struct {
+	logins    *services.OIDCLoginService
+	sessions  *services.SessionManager
+	twoFactor *services.TwoFactorService
+	secure    bool
+}
+
+// NewOIDCHandler creates a single sign-on handler. The flow cookie is
+// marked Secure unless SetSecure(false) is called for plain-HTTP development.
+func NewOIDCHandler(logins *services.OIDCLoginService, sessions *services.SessionManager, twoFactor *services.TwoFactorService) *OIDCHandler {
+	return &OIDCHandler{
+		logins:    logins,
+		sessions:  sessions,
+		twoFactor: twoFactor,
+		secure:    true,
+	}
+}
+
+// SetSecure sets whether the flow cookie is only sent over HTTPS
+func (h *OIDCHandler) SetSecure(secure bool) {
+	h.secure = secure
+}
+
+// Login redirects to the provider to sign in
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authURL, flow, err := h.logins.Begin(r.Context())
+	if err != nil {
+		log.Printf("Error starting single sign-on: %v", err)
+		redirectToLogin(w, r, "Single sign-on is unavailable right now, please try again later")
+		return
+	}
+
+	h.setFlowCookie(w, flow, int(10*time.Minute/time.Second))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback finishes signing in when the provider redirects back
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The flow cookie is single use whatever the outcome
+	h.setFlowCookie(w, "", -1)
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		redirectToLogin(w, r, "Sign-in was cancelled or refused by your identity provider")
+		return
+	}
+
+	cookie, err := r.Cookie(oidcFlowCookieName)
+	if err != nil {
+		redirectToLogin(w, r, services.ErrOIDCLoginState.Error())
+		return
+	}
+
+	user, err := h.logins.Finish(r.Context(), cookie.Value, query.Get("state"), query.Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOIDCLoginState),
+			errors.Is(err, services.ErrOIDCEmailNotVerified),
+			errors.Is(err, services.ErrOIDCAccountUnverified):
+			redirectToLogin(w, r, err.Error())
+		default:
+			if middleware.WriteTimeoutError(w, r, err) {
+				return
+			}
+			log.Printf("Error finishing single sign-on: %v", err)
+			redirectToLogin(w, r, "Sign-in failed, please try again")
+		}
+		return
+	}
+
+	// Accounts with two-factor authentication still need their code; the
+	// login page picks the token up from the URL fragment
+	if user.TOTPEnabled {
+		token, err := h.twoFactor.BeginLogin(r.Context(), user)
+		if err != nil {
+			if middleware.WriteTimeoutError(w, r, err) {
+				return
+			}
+			log.Printf("Error starting two-factor login: %v", err)
+			redirectToLogin(w, r, "Sign-in failed, please try again")
+			return
+		}
+		http.Redirect(w, r, "/login#two_factor_token="+url.QueryEscape(token), http.StatusFound)
+		return
+	}
+
+	if _, err := h.sessions.Start(r.Context(), w, user.ID); err != nil {
+		if middleware.WriteTimeoutError(w, r, err) {
+			return
+		}
+		log.Printf("Error starting session: %v", err)
+		redirectToLogin(w, r, "Sign-in failed, please try again")
+		return
+	}
+	http.Redirect(w, r, "/dashboard", http.StatusFound)
+}
+
+func (h *OIDCHandler) setFlowCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookieName,
+		Value:    value,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.secure,
+		SameSite: http.SameSiteLaxMode, // Sent on the provider's top-level redirect back
+		Path:     "/auth/oidc",
+	})
+}
+
+// redirectToLogin sends the user back to the login page with a message
+func redirectToLogin(w http.ResponseWriter, r *http.Request, message string) {
+	http.Redirect(w, r, "/login?sso_error="+url.QueryEscape(message), http.StatusFound)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"GopherTales/internal/middleware"
+)
+
+// PageHandler renders a template that only needs the reader's CSRF token
+// and some fixed values, such as the login and registration pages
+type PageHandler struct {
+	templateDir string
+	name        string
+	data        map[string]any
+}
+
+// NewPageHandler creates a handler for the named template
+func NewPageHandler(templateDir, name string) *PageHandler {
+	return &PageHandler{
+		templateDir: templateDir,
+		name:        name,
+		data:        make(map[string]any),
+	}
+}
+
+// With adds a fixed value to the template data and returns the handler
+func (h *PageHandler) With(key string, value any) *PageHandler {
+	h.data[key] = value
+	return h
+}
+
+// ServeHTTP renders the page
+func (h *PageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmpl, err := template.ParseFiles(h.templateDir + "/" + h.name)
+	if err != nil {
+		log.Printf("Error parsing %s: %v", h.name, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"CSRFToken": middleware.CSRFTokenFromContext(r.Context()),
+	}
+	for key, value := range h.data {
+		data[key] = value
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error executing %s: %v", h.name, err)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"GopherTales/internal/middleware"
+	"GopherTales/internal/services"
+)
+
+// PasswordHandler handles forgotten password requests
+type PasswordHandler struct {
+	resets *services.PasswordResetService
+}
+
+// NewPasswordHandler creates a new password handler
+func NewPasswordHandler(resets *services.PasswordResetService) *PasswordHandler {
+	return &PasswordHandler{resets: resets}
+}
+
+// ForgotPassword emails a reset link. It responds the same way whether or
+// not the account exists: the email is sent in the background, so neither
+// a mail failure nor a slow mail server shows in the response.
+func (h *PasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		if err := h.resets.RequestReset(ctx, req.Email); err != nil {
+			log.Printf("Error sending password reset: %v", err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "If an account exists for that email, a reset link is on its way",
+	})
+}
+
+// ResetPassword sets a new password from a reset token
+func (h *PasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.resets.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		if writeValidationError(w, err) || middleware.WriteTimeoutError(w, r, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidToken) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error resetting password: %v", err)
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"redirect_url": "/login",
+	})
+}
@@ -4,20 +4,17 @@ import (
 	"html/template"
 	"net/http"
 
-	"go.mongodb.org/mongo-driver/bson/primitive"
-
+	"GopherTales/internal/middleware"
 	"GopherTales/internal/services"
 )
 
 type ProfileHandler struct {
-	userService  *services.UserService
 	storyService *services.StoryService
 	templateDir  string
 }
 
-func NewProfileHandler(userService *services.UserService, storyService *services.StoryService, templateDir string) *ProfileHandler {
+func NewProfileHandler(storyService *services.StoryService, templateDir string) *ProfileHandler {
 	return &ProfileHandler{
-		userService:  userService,
 		storyService: storyService,
 		templateDir:  templateDir,
 	}
@@ -29,21 +26,8 @@ func (h *ProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user from cookie
-	cookie, err := r.Cookie("user_id")
-	if err != nil {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-		return
-	}
-
-	userID, err := primitive.ObjectIDFromHex(cookie.Value)
-	if err != nil {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-		return
-	}
-
-	user, err := h.userService.GetUserByID(userID)
-	if err != nil {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
@@ -51,28 +35,40 @@ func (h *ProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Get all stats
 	storyStats := h.storyService.GetStoryStats()
 	gopherStats := h.storyService.GetGopherStats()
-	issues := h.storyService.ValidateStoryIntegrity()
+	analysis := h.storyService.AnalyzeStory()
+
+	problemCount := 0
+	for _, a := range analysis {
+		if a.HasProblems() {
+			problemCount++
+		}
+	}
 
-	// Calculate user's total progress
-	totalProgress := 0
-	progressCount := 0
-	for _, progress := range user.Progress {
-		totalProgress += progress
-		progressCount++
+	// Calculate user's progress from the story graph
+	progress := h.storyService.SummarizeAllProgress(user.Progress)
+	totalPercent := 0
+	endingsFound := 0
+	for _, p := range progress {
+		totalPercent += p.ArcPercent
+		endingsFound += p.EndingsFound
 	}
 	avgProgress := 0
-	if progressCount > 0 {
-		avgProgress = totalProgress / progressCount
+	if len(progress) > 0 {
+		avgProgress = totalPercent / len(progress)
 	}
 
 	data := map[string]interface{}{
 		"User":          user,
 		"StoryStats":    storyStats,
 		"GopherStats":   gopherStats,
-		"Issues":        issues,
-		"HasIssues":     len(issues) > 0,
+		"Analysis":      analysis,
+		"ProblemCount":  problemCount,
+		"HasIssues":     problemCount > 0,
+		"Progress":      progress,
 		"TotalProgress": avgProgress,
+		"EndingsFound":  endingsFound,
 		"BookmarkCount": len(user.Bookmarks),
+		"CSRFToken":     middleware.CSRFTokenFromContext(r.Context()),
 	}
 
 	tmpl, err := template.ParseFiles(h.templateDir + "/profile.html")
@@ -4,17 +4,21 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+
+	"GopherTales/internal/services"
 )
 
 // SelectionHandler handles the gopher selection page requests
 type SelectionHandler struct {
-	templateDir string
+	storyService *services.StoryService
+	templateDir  string
 }
 
 // NewSelectionHandler creates a new selection handler
-func NewSelectionHandler(templateDir string) *SelectionHandler {
+func NewSelectionHandler(storyService *services.StoryService, templateDir string) *SelectionHandler {
 	return &SelectionHandler{
-		templateDir: templateDir,
+		storyService: storyService,
+		templateDir:  templateDir,
 	}
 }
 
@@ -38,8 +42,12 @@ func (h *SelectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Set content type
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	data := map[string]any{
+		"Gophers": h.storyService.GetCatalog(),
+	}
+
 	// Execute the template
-	if err := tmpl.Execute(w, nil); err != nil {
+	if err := tmpl.Execute(w, data); err != nil {
 		log.Printf("Error executing selection template: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
+	"time"
 
-	"go.mongodb.org/mongo-driver/bson/primitive"
-
+	"GopherTales/internal/middleware"
 	"GopherTales/internal/models"
 	"GopherTales/internal/services"
 )
@@ -16,18 +20,28 @@ import (
 type StoryHandler struct {
 	storyService *services.StoryService
 	userService  *services.UserService
+	stateStore   services.StateStore
 	templateDir  string
+	secure       bool
 }
 
-// NewStoryHandler creates a new story handler
-func NewStoryHandler(storyService *services.StoryService, userService *services.UserService, templateDir string) *StoryHandler {
+// NewStoryHandler creates a new story handler. The playthrough cookie is
+// marked Secure unless SetSecure(false) is called for plain-HTTP development.
+func NewStoryHandler(storyService *services.StoryService, userService *services.UserService, stateStore services.StateStore, templateDir string) *StoryHandler {
 	return &StoryHandler{
 		storyService: storyService,
 		userService:  userService,
+		stateStore:   stateStore,
 		templateDir:  templateDir,
+		secure:       true,
 	}
 }
 
+// SetSecure sets whether the playthrough cookie is only sent over HTTPS
+func (h *StoryHandler) SetSecure(secure bool) {
+	h.secure = secure
+}
+
 // ServeHTTP handles HTTP requests for story pages
 func (h *StoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET requests
@@ -39,6 +53,7 @@ func (h *StoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Get parameters from query
 	arcName := r.URL.Query().Get("arc")
 	gopher := r.URL.Query().Get("gopher")
+	wantsJSON := r.Header.Get("Accept") == "application/json" || r.URL.Query().Get("format") == "json"
 
 	var arc models.Arc
 	var finalArcName string
@@ -46,12 +61,45 @@ func (h *StoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Handle gopher-based stories
 	if gopher != "" {
-		arc, finalArcName, err = h.storyService.GetGopherArc(gopher, arcName)
+		playthroughID := h.playthroughID(w, r)
+		state, err := h.stateStore.Get(r.Context(), playthroughID, gopher)
+		if err != nil {
+			if middleware.WriteTimeoutError(w, r, err) {
+				return
+			}
+			log.Printf("Error loading story state: %v", err)
+			http.Error(w, "Story not available", http.StatusInternalServerError)
+			return
+		}
+
+		arc, finalArcName, err = h.storyService.GetGopherArc(gopher, arcName, state)
+		if errors.Is(err, services.ErrArcNotReachable) {
+			if wantsJSON {
+				http.Error(w, "Story arc not available", http.StatusForbidden)
+				return
+			}
+			// Send the reader back to where they are in the story
+			current := state.LastArc
+			if _, exists := h.storyService.GetGopherArcs(gopher)[current]; !exists {
+				current = "intro"
+			}
+			http.Redirect(w, r, "/story?"+url.Values{"gopher": {gopher}, "arc": {current}}.Encode(), http.StatusSeeOther)
+			return
+		}
 		if err != nil {
 			log.Printf("Error getting gopher arc '%s' for gopher '%s': %v", arcName, gopher, err)
 			http.Error(w, "Story not available", http.StatusNotFound)
 			return
 		}
+
+		// JSON requests are used for previews and preloading, so they must
+		// not advance the reader's state
+		if !wantsJSON {
+			expiresAt := time.Now().Add(services.PlaythroughTTL)
+			if err := h.stateStore.Save(r.Context(), playthroughID, gopher, state, expiresAt); err != nil {
+				log.Printf("Error saving story state: %v", err)
+			}
+		}
 	} else {
 		// Fallback to original story structure
 		if arcName != "" && !h.storyService.ValidateArc(arcName) {
@@ -68,7 +116,7 @@ func (h *StoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if client wants JSON response
-	if r.Header.Get("Accept") == "application/json" || r.URL.Query().Get("format") == "json" {
+	if wantsJSON {
 		h.serveJSON(w, arc, finalArcName, gopher)
 		return
 	}
@@ -89,30 +137,23 @@ func (h *StoryHandler) serveHTML(w http.ResponseWriter, r *http.Request, arc mod
 	}
 
 	// Get user for progress tracking
-	var user *models.User
-	if cookie, err := r.Cookie("user_id"); err == nil {
-		if userID, err := primitive.ObjectIDFromHex(cookie.Value); err == nil {
-			if u, err := h.userService.GetUserByID(userID); err == nil {
-				user = u
-				// Update progress if gopher story
-				if gopher != "" {
-					// Simple progress calculation based on arc depth
-					progressValue := 10 // Base progress per arc
-					if len(arc.Options) == 0 {
-						progressValue = 100 // Ending arc
-					}
-					h.userService.UpdateProgress(userID, gopher, progressValue)
-				}
-			}
+	user, ok := middleware.UserFromContext(r.Context())
+	// Record the visit, skipping page refreshes so the choice history only
+	// grows when the reader moves on
+	if ok && gopher != "" && h.userService.CanSaveProgress(user) && user.Progress[gopher].LastArc() != arcName {
+		isEnding := h.storyService.IsEnding(gopher, arcName)
+		if err := h.userService.RecordVisit(r.Context(), user.ID, gopher, arcName, isEnding); err != nil {
+			log.Printf("Error recording progress for gopher '%s': %v", gopher, err)
 		}
 	}
 
 	// Create page data
 	pageData := models.PageData{
-		Arc:     arc,
-		ArcName: arcName,
-		Gopher:  gopher,
-		User:    user,
+		Arc:       arc,
+		ArcName:   arcName,
+		Gopher:    gopher,
+		User:      user,
+		CSRFToken: middleware.CSRFTokenFromContext(r.Context()),
 	}
 
 	// Set content type
@@ -142,3 +183,29 @@ func (h *StoryHandler) serveJSON(w http.ResponseWriter, arc models.Arc, arcName,
 		return
 	}
 }
+
+// playthroughID returns the reader's playthrough identifier, issuing a new
+// one in a cookie if the request does not carry one yet
+func (h *StoryHandler) playthroughID(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie("playthrough_id"); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Error generating playthrough ID: %v", err)
+	}
+	id := hex.EncodeToString(buf)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "playthrough_id",
+		Value:    id,
+		Expires:  time.Now().Add(services.PlaythroughTTL),
+		HttpOnly: true,
+		Secure:   h.secure,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	})
+
+	return id
+}
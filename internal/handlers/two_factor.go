@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"GopherTales/internal/middleware"
+	"GopherTales/internal/services"
+)
+
+// TwoFactorHandler lets signed-in users enrol in, re-enrol in and disable
+// TOTP two-factor authentication
+type TwoFactorHandler struct {
+	twoFactor *services.TwoFactorService
+}
+
+// NewTwoFactorHandler creates a new two-factor handler
+func NewTwoFactorHandler(twoFactor *services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{twoFactor: twoFactor}
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// Enroll starts enrolment with a new secret. Users who already have 2FA
+// must send a current code to replace it.
+func (h *TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req twoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	secret, uri, err := h.twoFactor.BeginEnrolment(r.Context(), user, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_code_url": "/api/2fa/qr.png",
+	})
+}
+
+// QRCode serves the pending enrolment's otpauth:// URI as a PNG QR code
+func (h *TwoFactorHandler) QRCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	png, err := h.twoFactor.EnrolmentQRCode(user)
+	if err != nil {
+		writeTwoFactorError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+// Confirm enables 2FA once the user enters a code from their new secret,
+// returning their recovery codes
+func (h *TwoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req twoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.twoFactor.ConfirmEnrolment(r.Context(), user, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":        true,
+		"recovery_codes": codes,
+	})
+}
+
+// Disable turns off 2FA after checking a current code or recovery code
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req twoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.twoFactor.Disable(r.Context(), user, req.Code); err != nil {
+		writeTwoFactorError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// writeTwoFactorError maps two-factor service errors to status codes
+func writeTwoFactorError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTOTPCode):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, services.ErrTOTPNotEnabled), errors.Is(err, services.ErrNoTOTPEnrolment):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		if middleware.WriteTimeoutError(w, r, err) {
+			return
+		}
+		log.Printf("Error managing two-factor authentication: %v", err)
+		http.Error(w, "Two-factor authentication failed", http.StatusInternalServerError)
+	}
+}
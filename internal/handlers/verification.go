@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+
+	"GopherTales/internal/middleware"
+	"GopherTales/internal/services"
+)
+
+// VerificationHandler handles email verification links and resends
+type VerificationHandler struct {
+	verifications *services.EmailVerificationService
+	templateDir   string
+}
+
+// NewVerificationHandler creates a new verification handler
+func NewVerificationHandler(verifications *services.EmailVerificationService, templateDir string) *VerificationHandler {
+	return &VerificationHandler{
+		verifications: verifications,
+		templateDir:   templateDir,
+	}
+}
+
+// VerifyEmail confirms the email address from a verification link
+func (h *VerificationHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmpl, err := template.ParseFiles(h.templateDir + "/verify_email.html")
+	if err != nil {
+		log.Printf("Error parsing verify email template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	verified := true
+	if err := h.verifications.Verify(r.Context(), r.URL.Query().Get("token")); err != nil {
+		if middleware.WriteTimeoutError(w, r, err) {
+			return
+		}
+		if !errors.Is(err, services.ErrInvalidToken) {
+			log.Printf("Error verifying email: %v", err)
+		}
+		status = http.StatusBadRequest
+		verified = false
+	}
+
+	user, _ := middleware.UserFromContext(r.Context())
+	data := map[string]interface{}{
+		"Verified":   verified,
+		"IsLoggedIn": user != nil,
+		"CSRFToken":  middleware.CSRFTokenFromContext(r.Context()),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error executing verify email template: %v", err)
+	}
+}
+
+// ResendVerification emails the signed-in user a new verification link
+func (h *VerificationHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.verifications.SendVerification(r.Context(), user); err != nil {
+		switch {
+		case errors.Is(err, services.ErrVerificationThrottled):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, services.ErrAlreadyVerified):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			if middleware.WriteTimeoutError(w, r, err) {
+				return
+			}
+			log.Printf("Error sending verification email: %v", err)
+			http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Verification email sent",
+	})
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"GopherTales/internal/models"
+)
+
+// RequireAdminToken only lets requests through that carry the admin token as
+// "Authorization: Bearer <token>". An empty token disables the wrapped routes.
+func RequireAdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeJSONError(w, http.StatusNotFound, "admin API is disabled")
+				return
+			}
+
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdminTokenOrRole lets scripts in with the admin token and people in
+// with a signed-in account that has the role. Requests carrying an
+// Authorization header are only checked against the token.
+func RequireAdminTokenOrRole(token string, role models.Role) func(http.Handler) http.Handler {
+	requireToken := RequireAdminToken(token)
+	requireRole := RequireRole(role)
+	return func(next http.Handler) http.Handler {
+		byToken := requireToken(next)
+		byRole := requireRole(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				byToken.ServeHTTP(w, r)
+				return
+			}
+			byRole.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeJSONError writes {"error": message} with the given status
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
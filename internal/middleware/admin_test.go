@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"GopherTales/internal/models"
+)
+
+func TestRequireAdminTokenOrRole(t *testing.T) {
+	handler := RequireAdminTokenOrRole("t0ken", models.RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	admin := &models.User{Role: models.RoleAdmin}
+	author := &models.User{Role: models.RoleAuthor}
+
+	tests := []struct {
+		name          string
+		authorization string
+		user          *models.User
+		wantStatus    int
+	}{
+		{name: "token", authorization: "Bearer t0ken", wantStatus: http.StatusNoContent},
+		{name: "wrong token", authorization: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token with admin session", authorization: "Bearer nope", user: admin, wantStatus: http.StatusUnauthorized},
+		{name: "admin session", user: admin, wantStatus: http.StatusNoContent},
+		{name: "author session", user: author, wantStatus: http.StatusForbidden},
+		{name: "anonymous", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/users/role", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.user != nil {
+				req = req.WithContext(WithUser(req.Context(), tt.user, &models.Session{}))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
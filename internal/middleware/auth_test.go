@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+func TestRequireAuth(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Name: "Ada"}
+
+	var seen *models.User
+	handler := RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = UserFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name         string
+		path         string
+		user         *models.User
+		wantStatus   int
+		wantLocation string
+		wantJSON     bool
+	}{
+		{name: "page without user", path: "/dashboard", wantStatus: http.StatusSeeOther, wantLocation: "/login"},
+		{name: "api without user", path: "/api/bookmark", wantStatus: http.StatusUnauthorized, wantJSON: true},
+		{name: "page with user", path: "/dashboard", user: user, wantStatus: http.StatusNoContent},
+		{name: "api with user", path: "/api/bookmark", user: user, wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.user != nil {
+				req = req.WithContext(WithUser(req.Context(), tt.user, &models.Session{UserID: tt.user.ID}))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if location := rec.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("Expected Location '%s', got '%s'", tt.wantLocation, location)
+			}
+			if tt.wantJSON && !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+				t.Errorf("Expected a JSON error, got Content-Type '%s'", rec.Header().Get("Content-Type"))
+			}
+			if tt.user != nil && seen != tt.user {
+				t.Error("Expected the handler to see the user from the context")
+			}
+		})
+	}
+}
+
+func TestUserFromContext_Anonymous(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if user, ok := UserFromContext(req.Context()); ok || user != nil {
+		t.Errorf("Expected no user, got %+v", user)
+	}
+	if session, ok := SessionFromContext(req.Context()); ok || session != nil {
+		t.Errorf("Expected no session, got %+v", session)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	handler := RequireRole(models.RoleAuthor)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name       string
+		path       string
+		user       *models.User
+		wantStatus int
+	}{
+		{name: "anonymous page", path: "/admin", wantStatus: http.StatusSeeOther},
+		{name: "anonymous api", path: "/api/admin/reload", wantStatus: http.StatusUnauthorized},
+		{name: "account without role", path: "/api/admin/reload", user: &models.User{}, wantStatus: http.StatusForbidden},
+		{name: "reader", path: "/api/admin/reload", user: &models.User{Role: models.RoleReader}, wantStatus: http.StatusForbidden},
+		{name: "reader page", path: "/admin", user: &models.User{Role: models.RoleReader}, wantStatus: http.StatusForbidden},
+		{name: "author", path: "/api/admin/reload", user: &models.User{Role: models.RoleAuthor}, wantStatus: http.StatusNoContent},
+		{name: "admin includes author", path: "/api/admin/reload", user: &models.User{Role: models.RoleAdmin}, wantStatus: http.StatusNoContent},
+		{name: "unknown role", path: "/api/admin/reload", user: &models.User{Role: "owner"}, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.user != nil {
+				req = req.WithContext(WithUser(req.Context(), tt.user, &models.Session{}))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestWriteTimeoutError(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		err         error
+		wantHandled bool
+		wantStatus  int
+		wantJSON    bool
+	}{
+		{name: "deadline on api", path: "/api/bookmark", err: fmt.Errorf("query: %w", context.DeadlineExceeded), wantHandled: true, wantStatus: http.StatusGatewayTimeout, wantJSON: true},
+		{name: "deadline on page", path: "/dashboard", err: context.DeadlineExceeded, wantHandled: true, wantStatus: http.StatusGatewayTimeout},
+		{name: "cancelled", path: "/api/bookmark", err: context.Canceled, wantHandled: true, wantStatus: http.StatusServiceUnavailable, wantJSON: true},
+		{name: "other error", path: "/api/bookmark", err: errors.New("boom"), wantStatus: http.StatusOK},
+		{name: "no error", path: "/api/bookmark", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+
+			if handled := WriteTimeoutError(rec, req, tt.err); handled != tt.wantHandled {
+				t.Errorf("Expected handled %v, got %v", tt.wantHandled, handled)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantJSON && !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+				t.Errorf("Expected a JSON error, got Content-Type '%s'", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
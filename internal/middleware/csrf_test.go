@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	var seenToken string
+	handler := CSRF(false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenToken = CSRFTokenFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// A first visit issues a token and exposes it to the page
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected GET to pass, got %d", rec.Code)
+	}
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == CSRFCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value == "" {
+		t.Fatal("Expected a CSRF cookie to be issued")
+	}
+	if seenToken != cookie.Value {
+		t.Errorf("Expected the context token to match the cookie")
+	}
+	token := cookie.Value
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		header     string
+		form       string
+		auth       string
+		wantStatus int
+	}{
+		{name: "post with header", method: http.MethodPost, path: "/api/bookmark", header: token, wantStatus: http.StatusNoContent},
+		{name: "post with form field", method: http.MethodPost, path: "/logout", form: token, wantStatus: http.StatusNoContent},
+		{name: "post without token", method: http.MethodPost, path: "/api/bookmark", wantStatus: http.StatusForbidden},
+		{name: "post with wrong token", method: http.MethodPost, path: "/api/auth/logout", header: "forged", wantStatus: http.StatusForbidden},
+		{name: "delete without token", method: http.MethodDelete, path: "/api/bookmark", wantStatus: http.StatusForbidden},
+		{name: "bearer token request", method: http.MethodPost, path: "/api/admin/reload", auth: "Bearer secret", wantStatus: http.StatusNoContent},
+		{name: "get without token", method: http.MethodGet, path: "/api/stats", wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			if tt.form != "" {
+				body := url.Values{CSRFFormField: {tt.form}}.Encode()
+				req = httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(tt.method, tt.path, nil)
+			}
+			req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: token})
+			if tt.header != "" {
+				req.Header.Set(CSRFHeaderName, tt.header)
+			}
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	handler := CORS([]string{"https://reader.example"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		origin     string
+		wantOrigin string
+	}{
+		{origin: "https://reader.example", wantOrigin: "https://reader.example"},
+		{origin: "https://evil.example", wantOrigin: ""},
+		{origin: "", wantOrigin: ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("Origin '%s': expected Access-Control-Allow-Origin '%s', got '%s'", tt.origin, tt.wantOrigin, got)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -21,6 +25,34 @@ func Logger(next http.Handler) http.Handler {
 	})
 }
 
+// RealIP sets the request's RemoteAddr to the client address reported by
+// the reverse proxy in X-Forwarded-For. Only enable it behind a proxy that
+// appends to that header, otherwise clients can spoof their address.
+func RealIP(trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if trustProxy {
+				// The proxy appends the address it saw, so the last entry is
+				// the one it vouches for
+				forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+				if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); net.ParseIP(ip) != nil {
+					r.RemoteAddr = net.JoinHostPort(ip, "0")
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the IP address of the request's client, without the port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // Recovery middleware recovers from panics and returns a 500 error
 func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -34,20 +66,56 @@ func Recovery(next http.Handler) http.Handler {
 	})
 }
 
-// CORS middleware adds CORS headers
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// WriteTimeoutError answers a request whose work failed because it ran out
+// of time (504) or was cancelled (503), and reports whether err was either.
+// API routes get JSON, pages plain text.
+func WriteTimeoutError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var status int
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		status = http.StatusServiceUnavailable
+	default:
+		return false
+	}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+	log.Printf("Request %s %s gave up: %v", r.Method, r.URL.Path, err)
+	if isAPIRequest(r) {
+		writeJSONError(w, status, strings.ToLower(http.StatusText(status)))
+	} else {
+		http.Error(w, http.StatusText(status), status)
+	}
+	return true
+}
 
-		next.ServeHTTP(w, r)
-	})
+// CORS middleware adds CORS headers for requests from allowed origins.
+// Other origins get no CORS headers, so browsers keep them same-origin.
+func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+
+			if origin := r.Header.Get("Origin"); origin != "" && allowed[origin] {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+CSRFHeaderName)
+			}
+
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // SecurityHeaders middleware adds security headers
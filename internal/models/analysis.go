@@ -0,0 +1,71 @@
+package models
+
+// StoryAnalysis describes the structure of one gopher's arc graph
+type StoryAnalysis struct {
+	Gopher          string       `json:"gopher"`
+	ArcCount        int          `json:"arc_count"`
+	ReachableCount  int          `json:"reachable_count"`
+	UnreachableArcs []string     `json:"unreachable_arcs"`
+	DeadEnds        []string     `json:"dead_ends"` // Reachable arcs from which no ending can be reached
+	BrokenLinks     []BrokenLink `json:"broken_links"`
+	Cycles          []Cycle      `json:"cycles"`
+	Endings         []Ending     `json:"endings"`
+	EndingCount     int          `json:"ending_count"`
+	Depths          []DepthStats `json:"depths"`
+	PathsTruncated  bool         `json:"paths_truncated"` // Longest path search hit its step budget
+	MissingIntro    bool         `json:"missing_intro"`
+}
+
+// BrokenLink is an option that points at an arc which does not exist
+type BrokenLink struct {
+	Arc    string `json:"arc"`
+	Option string `json:"option"`
+	Target string `json:"target"`
+}
+
+// Cycle is a group of arcs that can be revisited in a loop
+type Cycle struct {
+	Arcs    []string `json:"arcs"`
+	HasExit bool     `json:"has_exit"` // False when readers who enter the loop can never leave it
+}
+
+// Ending is an arc with no options
+type Ending struct {
+	Arc          string `json:"arc"`
+	Title        string `json:"title"`
+	Reachable    bool   `json:"reachable"`
+	ShortestPath int    `json:"shortest_path"` // Fewest choices from intro, -1 if unreachable
+	LongestPath  int    `json:"longest_path"`  // Most choices from intro without revisiting an arc, -1 if unreachable or not found
+	// LongestPathTruncated is set when the search stopped early, so
+	// LongestPath is only the longest path found, or -1 if none was
+	LongestPathTruncated bool `json:"longest_path_truncated"`
+}
+
+// DepthStats summarises the arcs found at a given distance from intro
+type DepthStats struct {
+	Depth           int     `json:"depth"`
+	Arcs            int     `json:"arcs"`
+	Options         int     `json:"options"`
+	BranchingFactor float64 `json:"branching_factor"`
+}
+
+// TrappedCycles returns the cycles that readers cannot leave
+func (a *StoryAnalysis) TrappedCycles() []Cycle {
+	var trapped []Cycle
+	for _, cycle := range a.Cycles {
+		if !cycle.HasExit {
+			trapped = append(trapped, cycle)
+		}
+	}
+	return trapped
+}
+
+// HasProblems reports whether the analysis found anything a writer should fix
+func (a *StoryAnalysis) HasProblems() bool {
+	return a.MissingIntro ||
+		len(a.UnreachableArcs) > 0 ||
+		len(a.DeadEnds) > 0 ||
+		len(a.BrokenLinks) > 0 ||
+		len(a.TrappedCycles()) > 0 ||
+		a.EndingCount == 0
+}
@@ -0,0 +1,12 @@
+package models
+
+// Gopher is a playable character from the story's gopher catalog
+type Gopher struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Portrait    string   `json:"portrait"`    // Image file under the static directory
+	ThemeColor  string   `json:"theme_color"` // CSS colour used to accent the gopher's card and pages
+	Tagline     string   `json:"tagline"`
+	Traits      []string `json:"traits,omitempty"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// LintIssue is an authoring mistake found in a story file
+type LintIssue struct {
+	Severity string `json:"severity"` // "error" or "warning"
+	Code     string `json:"code"`
+	Gopher   string `json:"gopher,omitempty"`
+	Arc      string `json:"arc,omitempty"`
+	Message  string `json:"message"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginCounter tracks recent failed logins for one IP address or account
+type LoginCounter struct {
+	Key           string    `bson:"_id" json:"key"` // "ip:<address>" or "account:<email>"
+	Failures      int       `bson:"failures" json:"failures"`
+	LastFailureAt time.Time `bson:"last_failure_at" json:"last_failure_at"`
+	ExpiresAt     time.Time `bson:"expires_at" json:"expires_at"` // Failures are forgotten after this
+}
+
+// LoginAttempt is an audit record of a failed or blocked login
+type LoginAttempt struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email     string             `bson:"email" json:"email"`
+	IP        string             `bson:"ip" json:"ip"`
+	Reason    string             `bson:"reason" json:"reason"` // "invalid_credentials", "throttled" or "locked"
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+}
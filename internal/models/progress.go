@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// GopherProgress records one reader's journey through a gopher's story
+type GopherProgress struct {
+	VisitedArcs    []string   `bson:"visited_arcs" json:"visited_arcs"`       // Distinct arcs, in order of first visit
+	Path           []PathStep `bson:"path" json:"path"`                       // Every arc opened, oldest first
+	EndingsReached []string   `bson:"endings_reached" json:"endings_reached"` // Distinct endings, in order of first visit
+	StartedAt      time.Time  `bson:"started_at" json:"started_at"`
+	UpdatedAt      time.Time  `bson:"updated_at" json:"updated_at"`
+	// Reached an ending before endings were recorded by name
+	LegacyCompleted bool `bson:"legacy_completed,omitempty" json:"legacy_completed,omitempty"`
+}
+
+// PathStep is a single arc in a reader's choice history
+type PathStep struct {
+	Arc       string    `bson:"arc" json:"arc"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+// LastArc returns the most recently opened arc, or "" if there is none
+func (p GopherProgress) LastArc() string {
+	if len(p.Path) == 0 {
+		return ""
+	}
+	return p.Path[len(p.Path)-1].Arc
+}
+
+// ProgressSummary measures a reader's progress against the current story graph
+type ProgressSummary struct {
+	Gopher         string    `json:"gopher"`
+	Name           string    `json:"name"`
+	ArcsVisited    int       `json:"arcs_visited"`   // Distinct arcs visited that can still be reached from intro
+	ReachableArcs  int       `json:"reachable_arcs"` // Arcs that can be reached from intro
+	EndingsFound   int       `json:"endings_found"`
+	TotalEndings   int       `json:"total_endings"` // Endings that can be reached from intro
+	ArcPercent     int       `json:"arc_percent"`
+	EndingPercent  int       `json:"ending_percent"`
+	Choices        int       `json:"choices"` // Length of the choice history
+	LastArc        string    `json:"last_arc"`
+	LastPlayed     time.Time `json:"last_played"`
+	StoryCompleted bool      `json:"story_completed"` // Every reachable ending has been found
+}
@@ -0,0 +1,43 @@
+package models
+
+// Role says what a user may do beyond reading. Each role includes the
+// permissions of the roles below it: admin > author > reader.
+type Role string
+
+const (
+	RoleReader Role = "reader"
+	RoleAuthor Role = "author" // May reload and edit stories
+	RoleAdmin  Role = "admin"  // May also manage users and their roles
+)
+
+// roleRanks orders the roles
+var roleRanks = map[Role]int{
+	RoleReader: 1,
+	RoleAuthor: 2,
+	RoleAdmin:  3,
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Includes reports whether r grants everything other grants
+func (r Role) Includes(other Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[other]
+}
+
+// EffectiveRole returns the user's role. Accounts created before roles
+// existed have none stored and are readers.
+func (u *User) EffectiveRole() Role {
+	if u.Role.Valid() {
+		return u.Role
+	}
+	return RoleReader
+}
+
+// HasRole reports whether the user has the role or one above it
+func (u *User) HasRole(role Role) bool {
+	return u.EffectiveRole().Includes(role)
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a server-side login session. Only a hash of the session token
+// is stored, so a leaked sessions collection cannot be replayed as cookies.
+type Session struct {
+	ID         string             `bson:"_id" json:"-"` // SHA-256 of the session token, hex encoded
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	LastSeenAt time.Time          `bson:"last_seen_at" json:"last_seen_at"`
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expires_at"` // Moves forward while the session is in use
+}
+
+// Expired reports whether the session is no longer valid at the given time
+func (s *Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
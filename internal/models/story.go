@@ -2,16 +2,21 @@ package models
 
 // Option represents a choice in the story that leads to another arc
 type Option struct {
-	Text string `json:"text"`
-	Arc  string `json:"arc"`
+	Text     string `json:"text"`
+	Arc      string `json:"arc"`
+	Requires string `json:"requires,omitempty"` // Condition on the reader's story state, e.g. "has_map == true"
 }
 
 // Arc represents a single story segment with choices
 type Arc struct {
-	Title   string   `json:"title"`
-	Story   []string `json:"story"`
-	Options []Option `json:"options"`
-	Image   string   `json:"-"` // Not stored in JSON, computed dynamically
+	Title      string             `json:"title"`
+	Story      []string           `json:"story"`
+	Options    []Option           `json:"options"`
+	Set        map[string]any     `json:"set,omitempty"`        // Variables assigned when the arc is entered
+	Increment  map[string]float64 `json:"increment,omitempty"`  // Numeric variables adjusted when the arc is entered
+	Image      string             `json:"image,omitempty"`      // Illustration under the static directory, defaults to the gopher's portrait
+	Background string             `json:"background,omitempty"` // Page background image under the static directory
+	Audio      string             `json:"audio,omitempty"`      // Music or ambience under the static directory, played on a loop
 }
 
 // Story represents the complete story with all arcs
@@ -19,12 +24,36 @@ type Story struct {
 	Arcs map[string]Arc `json:"-"`
 }
 
+// StoryState holds the variables a reader has accumulated during one playthrough
+type StoryState struct {
+	Vars    map[string]any `json:"vars"`
+	LastArc string         `json:"last_arc"` // Arc whose entry effects were applied most recently
+}
+
+// NewStoryState creates an empty story state
+func NewStoryState() *StoryState {
+	return &StoryState{Vars: make(map[string]any)}
+}
+
+// Clone returns a copy of the state that can be modified independently
+func (s *StoryState) Clone() *StoryState {
+	clone := &StoryState{
+		Vars:    make(map[string]any, len(s.Vars)),
+		LastArc: s.LastArc,
+	}
+	for k, v := range s.Vars {
+		clone.Vars[k] = v
+	}
+	return clone
+}
+
 // PageData represents the data passed to templates
 type PageData struct {
-	Arc     Arc
-	ArcName string
-	Gopher  string
-	User    *User
+	Arc       Arc
+	ArcName   string
+	Gopher    string
+	User      *User
+	CSRFToken string // Echoed back by scripts on POST requests
 }
 
 // GetArc retrieves an arc by name, returns default "intro" if not found
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenPurpose says what a one-time token may be used for
+type TokenPurpose string
+
+const (
+	TokenPasswordReset     TokenPurpose = "password_reset"
+	TokenEmailVerification TokenPurpose = "email_verification"
+	TokenTwoFactorLogin    TokenPurpose = "two_factor_login" // Password accepted, waiting for the second factor
+)
+
+// OneTimeToken is a single-use, expiring token sent to a user by email or
+// handed to a client mid-login. Only a hash of the token is stored.
+type OneTimeToken struct {
+	ID        string             `bson:"_id"` // SHA-256 of the token, hex encoded
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Purpose   TokenPurpose       `bson:"purpose"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+}
@@ -6,14 +6,24 @@ import (
 )
 
 type User struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name         string             `bson:"name" json:"name"`
-	Email        string             `bson:"email" json:"email"`
-	PasswordHash string             `bson:"password_hash" json:"-"`
-	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
-	Progress     map[string]int     `bson:"progress" json:"progress"`
-	Bookmarks    []Bookmark         `bson:"bookmarks" json:"bookmarks"`
+	ID                 primitive.ObjectID        `bson:"_id,omitempty" json:"id"`
+	Name               string                    `bson:"name" json:"name"`
+	Email              string                    `bson:"email" json:"email"`
+	PasswordHash       string                    `bson:"password_hash" json:"-"`
+	Role               Role                      `bson:"role,omitempty" json:"role,omitempty"`
+	EmailVerified      bool                      `bson:"email_verified" json:"email_verified"`
+	VerificationSentAt time.Time                 `bson:"verification_sent_at,omitempty" json:"-"` // Last verification email, used to throttle resends
+	ResetSentAt        time.Time                 `bson:"reset_sent_at,omitempty" json:"-"`        // Last password reset email, used to throttle resends
+	TOTPEnabled        bool                      `bson:"totp_enabled" json:"totp_enabled"`
+	TOTPSecret         string                    `bson:"totp_secret,omitempty" json:"-"`
+	TOTPPendingSecret  string                    `bson:"totp_pending_secret,omitempty" json:"-"` // Secret being enrolled, until a code from it is confirmed
+	TOTPLastStep       int64                     `bson:"totp_last_step,omitempty" json:"-"`      // Last accepted time step, so codes cannot be replayed
+	RecoveryCodes      []string                  `bson:"recovery_codes,omitempty" json:"-"`      // SHA-256 of the unused recovery codes
+	Identities         []ExternalIdentity        `bson:"identities,omitempty" json:"identities,omitempty"`
+	CreatedAt          time.Time                 `bson:"created_at" json:"created_at"`
+	UpdatedAt          time.Time                 `bson:"updated_at" json:"updated_at"`
+	Progress           map[string]GopherProgress `bson:"reading_progress" json:"progress"` // Keyed by gopher, replaces the old integer "progress" field
+	Bookmarks          []Bookmark                `bson:"bookmarks" json:"bookmarks"`
 }
 
 type Bookmark struct {
@@ -23,6 +33,13 @@ type Bookmark struct {
 	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
 }
 
+// ExternalIdentity links a user to their account at an OpenID Connect provider
+type ExternalIdentity struct {
+	Issuer   string    `bson:"issuer" json:"issuer"`
+	Subject  string    `bson:"subject" json:"-"` // The provider's stable ID for the account
+	LinkedAt time.Time `bson:"linked_at" json:"linked_at"`
+}
+
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
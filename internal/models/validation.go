@@ -0,0 +1,8 @@
+package models
+
+// FieldError describes why one input field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"` // Machine-readable reason, e.g. "required", "too_short", "invalid", "taken"
+	Message string `json:"message"`
+}
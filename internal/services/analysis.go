@@ -0,0 +1,353 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"GopherTales/internal/models"
+)
+
+// maxPathSearchSteps bounds the longest-path search, which is exponential in
+// the worst case. Stories that exceed it report PathsTruncated, and their
+// endings LongestPathTruncated.
+const maxPathSearchSteps = 500000
+
+// AnalyzeStory runs a graph analysis over every gopher's arcs
+func (s *StoryService) AnalyzeStory() map[string]*models.StoryAnalysis {
+	_, gophers, _ := s.snapshot()
+	results := make(map[string]*models.StoryAnalysis, len(gophers))
+	for gopher, arcs := range gophers {
+		results[gopher] = AnalyzeArcs(gopher, arcs)
+	}
+	return results
+}
+
+// AnalyzeGopher runs a graph analysis over a single gopher's arcs
+func (s *StoryService) AnalyzeGopher(gopher string) (*models.StoryAnalysis, error) {
+	_, gophers, _ := s.snapshot()
+	arcs, exists := gophers[gopher]
+	if !exists {
+		return nil, fmt.Errorf("gopher '%s' not found", gopher)
+	}
+	return AnalyzeArcs(gopher, arcs), nil
+}
+
+// AnalyzeArcs inspects an arc map starting from "intro". Every option counts
+// as an edge regardless of its requirement, so the result describes every
+// path a writer has made possible rather than what a given reader will see.
+func AnalyzeArcs(gopher string, arcs map[string]models.Arc) *models.StoryAnalysis {
+	return analyzeArcs(gopher, arcs, maxPathSearchSteps)
+}
+
+// analyzeArcs is AnalyzeArcs with a step budget for the longest-path search
+func analyzeArcs(gopher string, arcs map[string]models.Arc, maxSteps int) *models.StoryAnalysis {
+	g := newArcGraph(arcs)
+
+	analysis := &models.StoryAnalysis{
+		Gopher:          gopher,
+		ArcCount:        len(arcs),
+		UnreachableArcs: []string{},
+		DeadEnds:        []string{},
+		BrokenLinks:     []models.BrokenLink{},
+		Cycles:          []models.Cycle{},
+		Endings:         []models.Ending{},
+		Depths:          []models.DepthStats{},
+	}
+
+	for _, name := range g.names {
+		for _, option := range arcs[name].Options {
+			if _, exists := arcs[option.Arc]; !exists {
+				analysis.BrokenLinks = append(analysis.BrokenLinks, models.BrokenLink{
+					Arc:    name,
+					Option: option.Text,
+					Target: option.Arc,
+				})
+			}
+		}
+	}
+
+	_, hasIntro := arcs["intro"]
+	analysis.MissingIntro = !hasIntro
+
+	depth := g.distancesFrom("intro")
+	analysis.ReachableCount = len(depth)
+	for _, name := range g.names {
+		if _, reachable := depth[name]; !reachable {
+			analysis.UnreachableArcs = append(analysis.UnreachableArcs, name)
+		}
+	}
+
+	// Arcs that can still lead to an ending
+	var endings []string
+	for _, name := range g.names {
+		if len(arcs[name].Options) == 0 {
+			endings = append(endings, name)
+		}
+	}
+	canFinish := g.reverseReachable(endings)
+	for _, name := range g.names {
+		if _, reachable := depth[name]; reachable && !canFinish[name] {
+			analysis.DeadEnds = append(analysis.DeadEnds, name)
+		}
+	}
+
+	analysis.Cycles = g.cycles(depth)
+
+	longest, truncated := g.longestPaths("intro", maxSteps)
+	analysis.PathsTruncated = truncated
+	for _, name := range endings {
+		ending := models.Ending{
+			Arc:          name,
+			Title:        arcs[name].Title,
+			ShortestPath: -1,
+			LongestPath:  -1,
+		}
+		if d, reachable := depth[name]; reachable {
+			ending.Reachable = true
+			ending.ShortestPath = d
+			ending.LongestPathTruncated = truncated
+			if l, found := longest[name]; found {
+				ending.LongestPath = l
+			}
+		}
+		analysis.Endings = append(analysis.Endings, ending)
+	}
+	analysis.EndingCount = len(analysis.Endings)
+
+	analysis.Depths = depthStats(arcs, depth)
+
+	return analysis
+}
+
+// arcGraph is the adjacency view of an arc map, ignoring broken links
+type arcGraph struct {
+	names []string
+	edges map[string][]string
+}
+
+func newArcGraph(arcs map[string]models.Arc) *arcGraph {
+	g := &arcGraph{
+		names: make([]string, 0, len(arcs)),
+		edges: make(map[string][]string, len(arcs)),
+	}
+
+	for name := range arcs {
+		g.names = append(g.names, name)
+	}
+	sort.Strings(g.names)
+
+	for _, name := range g.names {
+		seen := make(map[string]bool)
+		for _, option := range arcs[name].Options {
+			if _, exists := arcs[option.Arc]; !exists || seen[option.Arc] {
+				continue
+			}
+			seen[option.Arc] = true
+			g.edges[name] = append(g.edges[name], option.Arc)
+		}
+	}
+
+	return g
+}
+
+// distancesFrom returns the fewest number of choices needed to reach each arc
+func (g *arcGraph) distancesFrom(start string) map[string]int {
+	depth := make(map[string]int)
+	if !g.has(start) {
+		return depth
+	}
+
+	depth[start] = 0
+	queue := []string{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range g.edges[current] {
+			if _, seen := depth[next]; !seen {
+				depth[next] = depth[current] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return depth
+}
+
+// reverseReachable returns every arc that has a path to one of the targets
+func (g *arcGraph) reverseReachable(targets []string) map[string]bool {
+	reverse := make(map[string][]string)
+	for _, from := range g.names {
+		for _, to := range g.edges[from] {
+			reverse[to] = append(reverse[to], from)
+		}
+	}
+
+	reached := make(map[string]bool)
+	queue := append([]string(nil), targets...)
+	for _, t := range targets {
+		reached[t] = true
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, prev := range reverse[current] {
+			if !reached[prev] {
+				reached[prev] = true
+				queue = append(queue, prev)
+			}
+		}
+	}
+
+	return reached
+}
+
+// cycles finds strongly connected groups of reachable arcs (Tarjan's algorithm)
+func (g *arcGraph) cycles(reachable map[string]int) []models.Cycle {
+	index := 0
+	indices := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	var connect func(v string)
+	connect = func(v string) {
+		indices[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range g.edges[v] {
+			if _, visited := indices[w]; !visited {
+				connect(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], indices[w])
+			}
+		}
+
+		if lowlink[v] == indices[v] {
+			var component []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, name := range g.names {
+		if _, ok := reachable[name]; !ok {
+			continue
+		}
+		if _, visited := indices[name]; !visited {
+			connect(name)
+		}
+	}
+
+	cycles := []models.Cycle{}
+	for _, component := range components {
+		members := make(map[string]bool, len(component))
+		for _, name := range component {
+			members[name] = true
+		}
+
+		isCycle := len(component) > 1
+		hasExit := false
+		for _, name := range component {
+			for _, next := range g.edges[name] {
+				if next == name {
+					isCycle = true
+				}
+				if !members[next] {
+					hasExit = true
+				}
+			}
+		}
+		if !isCycle {
+			continue
+		}
+
+		sort.Strings(component)
+		cycles = append(cycles, models.Cycle{Arcs: component, HasExit: hasExit})
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i].Arcs[0] < cycles[j].Arcs[0]
+	})
+	return cycles
+}
+
+// longestPaths returns, for each arc, the most choices on a path from start
+// that never revisits an arc. The search gives up after maxSteps visits.
+func (g *arcGraph) longestPaths(start string, maxSteps int) (map[string]int, bool) {
+	longest := make(map[string]int)
+	if !g.has(start) {
+		return longest, false
+	}
+
+	onPath := make(map[string]bool)
+	steps := 0
+	truncated := false
+
+	var walk func(arc string, length int)
+	walk = func(arc string, length int) {
+		steps++
+		if steps > maxSteps {
+			truncated = true
+			return
+		}
+
+		if current, seen := longest[arc]; !seen || length > current {
+			longest[arc] = length
+		}
+
+		onPath[arc] = true
+		for _, next := range g.edges[arc] {
+			if !onPath[next] {
+				walk(next, length+1)
+			}
+		}
+		onPath[arc] = false
+	}
+
+	walk(start, 0)
+	return longest, truncated
+}
+
+func (g *arcGraph) has(name string) bool {
+	i := sort.SearchStrings(g.names, name)
+	return i < len(g.names) && g.names[i] == name
+}
+
+// depthStats groups reachable arcs by their distance from intro
+func depthStats(arcs map[string]models.Arc, depth map[string]int) []models.DepthStats {
+	byDepth := make(map[int]*models.DepthStats)
+	maxDepth := -1
+	for name, d := range depth {
+		stats, exists := byDepth[d]
+		if !exists {
+			stats = &models.DepthStats{Depth: d}
+			byDepth[d] = stats
+		}
+		stats.Arcs++
+		stats.Options += len(arcs[name].Options)
+		if d > maxDepth {
+			maxDepth = d
+		}
+	}
+
+	result := make([]models.DepthStats, 0, maxDepth+1)
+	for d := 0; d <= maxDepth; d++ {
+		stats := byDepth[d]
+		stats.BranchingFactor = float64(stats.Options) / float64(stats.Arcs)
+		result = append(result, *stats)
+	}
+	return result
+}
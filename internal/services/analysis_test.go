@@ -0,0 +1,161 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"GopherTales/internal/models"
+)
+
+func TestAnalyzeArcs(t *testing.T) {
+	arcs := map[string]models.Arc{
+		"intro": {
+			Title: "Intro",
+			Options: []models.Option{
+				{Text: "Left", Arc: "left"},
+				{Text: "Right", Arc: "right"},
+				{Text: "Nowhere", Arc: "missing"},
+			},
+		},
+		"left": {
+			Title:   "Left",
+			Options: []models.Option{{Text: "Finish", Arc: "end-good"}},
+		},
+		"right": {
+			Title: "Right",
+			Options: []models.Option{
+				{Text: "Loop", Arc: "trap-a"},
+				{Text: "Back", Arc: "left"},
+			},
+		},
+		"trap-a":   {Title: "Trap A", Options: []models.Option{{Text: "On", Arc: "trap-b"}}},
+		"trap-b":   {Title: "Trap B", Options: []models.Option{{Text: "Again", Arc: "trap-a"}}},
+		"end-good": {Title: "Good Ending"},
+		"orphan":   {Title: "Orphan", Options: []models.Option{{Text: "Go", Arc: "end-good"}}},
+		"lost-end": {Title: "Lost Ending"},
+	}
+
+	a := AnalyzeArcs("test", arcs)
+
+	if a.ArcCount != 8 {
+		t.Errorf("Expected 8 arcs, got %d", a.ArcCount)
+	}
+	if a.ReachableCount != 6 {
+		t.Errorf("Expected 6 reachable arcs, got %d", a.ReachableCount)
+	}
+	if !reflect.DeepEqual(a.UnreachableArcs, []string{"lost-end", "orphan"}) {
+		t.Errorf("Unexpected unreachable arcs: %v", a.UnreachableArcs)
+	}
+	if !reflect.DeepEqual(a.DeadEnds, []string{"trap-a", "trap-b"}) {
+		t.Errorf("Unexpected dead ends: %v", a.DeadEnds)
+	}
+	if len(a.BrokenLinks) != 1 || a.BrokenLinks[0].Target != "missing" {
+		t.Errorf("Unexpected broken links: %v", a.BrokenLinks)
+	}
+
+	if len(a.Cycles) != 1 {
+		t.Fatalf("Expected 1 cycle, got %d", len(a.Cycles))
+	}
+	if a.Cycles[0].HasExit {
+		t.Error("Expected trap cycle to have no exit")
+	}
+	if len(a.TrappedCycles()) != 1 {
+		t.Errorf("Expected 1 trapped cycle, got %d", len(a.TrappedCycles()))
+	}
+
+	if a.EndingCount != 2 {
+		t.Fatalf("Expected 2 endings, got %d", a.EndingCount)
+	}
+	for _, ending := range a.Endings {
+		switch ending.Arc {
+		case "end-good":
+			if !ending.Reachable || ending.ShortestPath != 2 || ending.LongestPath != 3 {
+				t.Errorf("Unexpected end-good stats: %+v", ending)
+			}
+		case "lost-end":
+			if ending.Reachable || ending.ShortestPath != -1 || ending.LongestPath != -1 {
+				t.Errorf("Unexpected lost-end stats: %+v", ending)
+			}
+		default:
+			t.Errorf("Unexpected ending %s", ending.Arc)
+		}
+	}
+
+	expectedDepths := []models.DepthStats{
+		{Depth: 0, Arcs: 1, Options: 3, BranchingFactor: 3},
+		{Depth: 1, Arcs: 2, Options: 3, BranchingFactor: 1.5},
+		{Depth: 2, Arcs: 2, Options: 1, BranchingFactor: 0.5},
+		{Depth: 3, Arcs: 1, Options: 1, BranchingFactor: 1},
+	}
+	if !reflect.DeepEqual(a.Depths, expectedDepths) {
+		t.Errorf("Unexpected depth stats: %+v", a.Depths)
+	}
+
+	if !a.HasProblems() {
+		t.Error("Expected analysis to report problems")
+	}
+}
+
+func TestAnalyzeArcs_TruncatedPaths(t *testing.T) {
+	arcs := map[string]models.Arc{
+		"intro": {Options: []models.Option{{Text: "A", Arc: "a"}, {Text: "B", Arc: "b"}}},
+		"a":     {Options: []models.Option{{Text: "End", Arc: "end-a"}}},
+		"b":     {Options: []models.Option{{Text: "End", Arc: "end-b"}}},
+		"end-a": {},
+		"end-b": {},
+	}
+
+	// Three steps reach end-a but never end-b
+	a := analyzeArcs("test", arcs, 3)
+	if !a.PathsTruncated {
+		t.Fatal("Expected the path search to be truncated")
+	}
+	for _, ending := range a.Endings {
+		want := map[string]int{"end-a": 2, "end-b": -1}[ending.Arc]
+		if !ending.LongestPathTruncated || ending.LongestPath != want {
+			t.Errorf("Expected %s to report a truncated longest path of %d, got %+v", ending.Arc, want, ending)
+		}
+	}
+}
+
+func TestAnalyzeArcs_MissingIntro(t *testing.T) {
+	a := AnalyzeArcs("test", map[string]models.Arc{
+		"start": {Title: "Start"},
+	})
+
+	if !a.MissingIntro {
+		t.Error("Expected missing intro to be reported")
+	}
+	if a.ReachableCount != 0 {
+		t.Errorf("Expected no reachable arcs, got %d", a.ReachableCount)
+	}
+	if !a.HasProblems() {
+		t.Error("Expected analysis to report problems")
+	}
+}
+
+func TestStoryService_AnalyzeGopher(t *testing.T) {
+	service := NewStoryService("nonexistent.json")
+	service.gopherStories = map[string]map[string]models.Arc{
+		"blue": {
+			"intro": {Title: "Intro", Options: []models.Option{{Text: "Home", Arc: "home"}}},
+			"home":  {Title: "Home"},
+		},
+	}
+
+	a, err := service.AnalyzeGopher("blue")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if a.HasProblems() {
+		t.Errorf("Expected healthy story, got %+v", a)
+	}
+
+	if _, err := service.AnalyzeGopher("pink"); err == nil {
+		t.Error("Expected error for unknown gopher")
+	}
+
+	if all := service.AnalyzeStory(); len(all) != 1 {
+		t.Errorf("Expected analysis for 1 gopher, got %d", len(all))
+	}
+}
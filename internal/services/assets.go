@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"GopherTales/internal/models"
+)
+
+// SetStaticDir sets the directory arc assets are served from. When set,
+// LoadStory fails if an arc refers to a file that does not exist there.
+func (s *StoryService) SetStaticDir(dir string) {
+	s.staticDir = dir
+}
+
+// arcAsset is a file an arc refers to
+type arcAsset struct {
+	field string
+	path  string
+}
+
+// arcAssets returns the asset files an arc refers to
+func arcAssets(arc models.Arc) []arcAsset {
+	var assets []arcAsset
+	for _, asset := range []arcAsset{
+		{"image", arc.Image},
+		{"background", arc.Background},
+		{"audio", arc.Audio},
+	} {
+		if asset.path != "" {
+			assets = append(assets, asset)
+		}
+	}
+	return assets
+}
+
+// checkAsset reports why an asset cannot be served from the static directory.
+// Without a static directory only the path itself is checked.
+func checkAsset(staticDir, asset string) (code string, err error) {
+	if strings.Contains(asset, `\`) || !filepath.IsLocal(filepath.FromSlash(asset)) {
+		return "invalid-asset-path", fmt.Errorf("'%s' must be a relative path inside the static directory", asset)
+	}
+	if staticDir == "" {
+		return "", nil
+	}
+
+	info, err := os.Stat(filepath.Join(staticDir, filepath.FromSlash(asset)))
+	if err != nil {
+		return "missing-asset", fmt.Errorf("'%s' not found in %s", asset, staticDir)
+	}
+	if info.IsDir() {
+		return "missing-asset", fmt.Errorf("'%s' is a directory, not a file", asset)
+	}
+	return "", nil
+}
+
+// lintAssets checks every asset referenced by a gopher's arcs
+func lintAssets(staticDir, gopher string, arcs map[string]models.Arc) []models.LintIssue {
+	var issues []models.LintIssue
+	for _, name := range sortedKeys(arcs) {
+		for _, asset := range arcAssets(arcs[name]) {
+			code, err := checkAsset(staticDir, asset.path)
+			if err != nil {
+				issues = append(issues, models.LintIssue{
+					Severity: LintError,
+					Code:     code,
+					Gopher:   gopher,
+					Arc:      name,
+					Message:  fmt.Sprintf("%s %v", asset.field, err),
+				})
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoryService_LoadStory_Assets(t *testing.T) {
+	staticDir := t.TempDir()
+	writeFile(t, filepath.Join(staticDir, "gopher_blue.png"), "png")
+	writeFile(t, filepath.Join(staticDir, "blue", "sky.jpg"), "jpg")
+	writeFile(t, filepath.Join(staticDir, "audio", "wind.mp3"), "mp3")
+
+	path := writeTempStory(t, `{
+		"catalog": [{"id": "blue", "name": "Blue Gopher", "portrait": "gopher_blue.png"}],
+		"blue": {
+			"intro": {
+				"title": "Intro",
+				"story": ["Up we go."],
+				"options": [{"text": "Land", "arc": "home"}],
+				"background": "blue/sky.jpg",
+				"audio": "audio/wind.mp3"
+			},
+			"home": {"title": "Home", "story": ["Back home."], "options": [], "image": "blue/sky.jpg"}
+		}
+	}`)
+
+	service := NewStoryService(path)
+	service.SetStaticDir(staticDir)
+	if err := service.LoadStory(); err != nil {
+		t.Fatalf("Failed to load story with assets: %v", err)
+	}
+
+	intro, _, err := service.GetGopherArc("blue", "intro", nil)
+	if err != nil {
+		t.Fatalf("Failed to get intro: %v", err)
+	}
+	if intro.Image != "gopher_blue.png" {
+		t.Errorf("Expected image to fall back to the portrait, got '%s'", intro.Image)
+	}
+	if intro.Background != "blue/sky.jpg" || intro.Audio != "audio/wind.mp3" {
+		t.Errorf("Expected declared assets, got background '%s' audio '%s'", intro.Background, intro.Audio)
+	}
+
+	home, _, err := service.GetGopherArc("blue", "home", nil)
+	if err != nil {
+		t.Fatalf("Failed to get home: %v", err)
+	}
+	if home.Image != "blue/sky.jpg" {
+		t.Errorf("Expected declared image, got '%s'", home.Image)
+	}
+}
+
+func TestStoryService_LoadStory_InvalidAssets(t *testing.T) {
+	staticDir := t.TempDir()
+	writeFile(t, filepath.Join(staticDir, "audio", "keep.mp3"), "mp3")
+
+	path := writeTempStory(t, `{
+		"blue": {
+			"intro": {
+				"title": "Intro",
+				"story": ["Hi."],
+				"options": [],
+				"image": "missing.png",
+				"background": "../secret.txt",
+				"audio": "audio"
+			}
+		}
+	}`)
+
+	service := NewStoryService(path)
+	service.SetStaticDir(staticDir)
+	err := service.LoadStory()
+
+	var validationErr *StoryValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected StoryValidationError, got %v", err)
+	}
+	codes := issueCodes(validationErr.Issues)
+	if codes["missing-asset"] != 2 || codes["invalid-asset-path"] != 1 {
+		t.Errorf("Expected 2 missing-asset and 1 invalid-asset-path issues, got %v", codes)
+	}
+	if arcs := service.GetAvailableGophers(); len(arcs) != 0 {
+		t.Errorf("Expected nothing to be loaded, got %v", arcs)
+	}
+
+	// Without a static directory only the paths are checked
+	issues := LintStoryFile(path, "")
+	if codes := issueCodes(issues); codes["missing-asset"] != 0 || codes["invalid-asset-path"] != 1 {
+		t.Errorf("Expected only the path issue without a static directory, got %v", codes)
+	}
+}
@@ -0,0 +1,474 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Condition is a parsed option requirement such as "has_map == true && courage >= 2"
+//
+// Supported syntax:
+//   - literals: numbers, true, false, and single- or double-quoted strings
+//   - variables: identifiers made of letters, digits, '_' and '-'
+//   - comparisons: == != < <= > >=
+//   - logic: && || ! and parentheses
+//
+// Undefined variables take the zero value of whatever they are compared
+// against, so "has_map == false" holds for a reader who never found the map.
+type Condition struct {
+	source string
+	root   condNode
+}
+
+// ParseCondition compiles an expression into a Condition
+func ParseCondition(expr string) (*Condition, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &condParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+
+	return &Condition{source: expr, root: root}, nil
+}
+
+// String returns the original expression
+func (c *Condition) String() string {
+	return c.source
+}
+
+// Eval evaluates the condition against a set of story variables
+func (c *Condition) Eval(vars map[string]any) (bool, error) {
+	value, err := c.root.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	return truthy(value), nil
+}
+
+// EvalCondition parses and evaluates an expression in one step.
+// An empty expression is always satisfied.
+func EvalCondition(expr string, vars map[string]any) (bool, error) {
+	if strings.TrimSpace(expr) == "" {
+		return true, nil
+	}
+
+	cond, err := ParseCondition(expr)
+	if err != nil {
+		return false, err
+	}
+	return cond.Eval(vars)
+}
+
+// Tokenizer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type condToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenizeCondition(expr string) ([]condToken, error) {
+	var tokens []condToken
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, condToken{kind: tokLParen, text: "(", pos: i})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, condToken{kind: tokRParen, text: ")", pos: i})
+			i++
+
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != r {
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			tokens = append(tokens, condToken{kind: tokString, text: string(runes[start+1 : i]), pos: start})
+			i++
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, condToken{kind: tokNumber, text: string(runes[start:i]), pos: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '-') {
+				i++
+			}
+			tokens = append(tokens, condToken{kind: tokIdent, text: string(runes[start:i]), pos: start})
+
+		default:
+			start := i
+			op := string(r)
+			if i+1 < len(runes) {
+				if two := string(runes[i : i+2]); isTwoCharOp(two) {
+					op = two
+				}
+			}
+			if !isOneCharOp(op) && !isTwoCharOp(op) {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, start)
+			}
+			tokens = append(tokens, condToken{kind: tokOp, text: op, pos: start})
+			i += len([]rune(op))
+		}
+	}
+
+	tokens = append(tokens, condToken{kind: tokEOF, text: "end of expression", pos: len(runes)})
+	return tokens, nil
+}
+
+func isOneCharOp(op string) bool {
+	return op == "<" || op == ">" || op == "!"
+}
+
+func isTwoCharOp(op string) bool {
+	switch op {
+	case "==", "!=", "<=", ">=", "&&", "||":
+		return true
+	}
+	return false
+}
+
+// Parser (recursive descent, lowest precedence first)
+
+type condParser struct {
+	tokens []condToken
+	pos    int
+}
+
+func (p *condParser) peek() condToken {
+	return p.tokens[p.pos]
+}
+
+func (p *condParser) next() condToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *condParser) parseOr() (condNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseAnd() (condNode, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseComparison() (condNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind == tokOp {
+		switch tok.text {
+		case "==", "!=", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return &compareNode{op: tok.text, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseUnary() (condNode, error) {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *condParser) parsePrimary() (condNode, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("expected ')' at position %d", closing.pos)
+		}
+		return inner, nil
+
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+		}
+		return &literalNode{value: n}, nil
+
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		}
+		return &variableNode{name: tok.text}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// Evaluation
+
+type condNode interface {
+	eval(vars map[string]any) (any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) eval(map[string]any) (any, error) {
+	return n.value, nil
+}
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(vars map[string]any) (any, error) {
+	return normalizeValue(vars[n.name]), nil
+}
+
+type notNode struct {
+	operand condNode
+}
+
+func (n *notNode) eval(vars map[string]any) (any, error) {
+	value, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type logicNode struct {
+	op          string
+	left, right condNode
+}
+
+func (n *logicNode) eval(vars map[string]any) (any, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit
+	if n.op == "&&" && !truthy(left) {
+		return false, nil
+	}
+	if n.op == "||" && truthy(left) {
+		return true, nil
+	}
+
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right condNode
+}
+
+func (n *compareNode) eval(vars map[string]any) (any, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// Undefined variables adopt the zero value of the other side
+	if left == nil {
+		left = zeroLike(right)
+	}
+	if right == nil {
+		right = zeroLike(left)
+	}
+
+	switch n.op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	}
+
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number with %T using %s", right, n.op)
+		}
+		return compareOrdered(n.op, l, r), nil
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %T using %s", right, n.op)
+		}
+		return compareOrdered(n.op, l, r), nil
+	}
+
+	return nil, fmt.Errorf("operator %s not supported for %T", n.op, left)
+}
+
+// valuesEqual compares two scalar values. Values of different types, and
+// any value that is not a string, number or boolean, are never equal.
+func valuesEqual(left, right any) bool {
+	switch l := normalizeValue(left).(type) {
+	case string:
+		r, ok := right.(string)
+		return ok && l == r
+	case float64:
+		r, ok := normalizeValue(right).(float64)
+		return ok && l == r
+	case bool:
+		r, ok := right.(bool)
+		return ok && l == r
+	}
+	return false
+}
+
+// IsScalarValue reports whether v can be stored in a story variable: a
+// string, number or boolean
+func IsScalarValue(v any) bool {
+	switch normalizeValue(v).(type) {
+	case string, float64, bool:
+		return true
+	}
+	return false
+}
+
+func compareOrdered[T float64 | string](op string, l, r T) bool {
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+	return false
+}
+
+// normalizeValue converts numeric variants to float64 so comparisons are consistent
+func normalizeValue(v any) any {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	}
+	return v
+}
+
+func zeroLike(v any) any {
+	switch v.(type) {
+	case bool:
+		return false
+	case float64:
+		return float64(0)
+	case string:
+		return ""
+	}
+	return nil
+}
+
+func truthy(v any) bool {
+	switch val := normalizeValue(v).(type) {
+	case bool:
+		return val
+	case float64:
+		return val != 0
+	case string:
+		return val != ""
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestEvalCondition(t *testing.T) {
+	vars := map[string]any{
+		"has_map": true,
+		"courage": float64(3),
+		"name":    "purple",
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"", true},
+		{"has_map", true},
+		{"has_map == true", true},
+		{"has_map != true", false},
+		{"!has_map", false},
+		{"courage >= 3", true},
+		{"courage > 3", false},
+		{"courage < 10 && has_map", true},
+		{"courage > 10 || has_map == false", false},
+		{"(courage > 10 || has_map) && name == 'purple'", true},
+		{"name == \"blue\"", false},
+		{"missing == false", true},
+		{"missing == 0", true},
+		{"missing > 0", false},
+		{"missing", false},
+		{"!missing", true},
+	}
+
+	for _, test := range tests {
+		result, err := EvalCondition(test.expr, vars)
+		if err != nil {
+			t.Errorf("EvalCondition(%q): unexpected error: %v", test.expr, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("EvalCondition(%q): expected %v, got %v", test.expr, test.expected, result)
+		}
+	}
+}
+
+func TestParseCondition_Invalid(t *testing.T) {
+	invalid := []string{
+		"has_map ==",
+		"(courage > 1",
+		"courage > 1)",
+		"name == 'unterminated",
+		"courage # 2",
+		"&& has_map",
+	}
+
+	for _, expr := range invalid {
+		if _, err := ParseCondition(expr); err == nil {
+			t.Errorf("ParseCondition(%q): expected error", expr)
+		}
+	}
+}
+
+func TestEvalCondition_TypeMismatch(t *testing.T) {
+	vars := map[string]any{"courage": float64(1)}
+
+	if _, err := EvalCondition("courage < 'high'", vars); err == nil {
+		t.Error("Expected error when ordering a number against a string")
+	}
+
+	// Equality across types is allowed and simply false
+	result, err := EvalCondition("courage == 'high'", vars)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result {
+		t.Error("Expected number and string to compare unequal")
+	}
+}
+
+func TestEvalCondition_NonScalarVariables(t *testing.T) {
+	vars := map[string]any{
+		"items": []any{"map"},
+		"bag":   map[string]any{"coins": 1},
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"items == 1", false},
+		{"items == bag", false},
+		{"bag != 'full'", true},
+	}
+	for _, test := range tests {
+		result, err := EvalCondition(test.expr, vars)
+		if err != nil {
+			t.Errorf("EvalCondition(%q): unexpected error: %v", test.expr, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("EvalCondition(%q): expected %v, got %v", test.expr, test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"GopherTales/internal/models"
+)
+
+// Supported story graph export formats
+const (
+	GraphFormatDOT     = "dot"
+	GraphFormatMermaid = "mermaid"
+)
+
+// IsGraphFormat reports whether format is a supported export format
+func IsGraphFormat(format string) bool {
+	return format == GraphFormatDOT || format == GraphFormatMermaid
+}
+
+// ExportGraph renders a gopher's arcs as a diagram in the given format
+func (s *StoryService) ExportGraph(gopher, format string) (string, error) {
+	if !IsGraphFormat(format) {
+		return "", fmt.Errorf("unknown graph format '%s'", format)
+	}
+
+	_, gophers, _ := s.snapshot()
+	arcs, exists := gophers[gopher]
+	if !exists {
+		return "", fmt.Errorf("gopher '%s' not found", gopher)
+	}
+
+	if format == GraphFormatMermaid {
+		return ExportMermaid(arcs), nil
+	}
+	return ExportDOT(gopher, arcs), nil
+}
+
+// graphEdge is an option as drawn in an exported diagram
+type graphEdge struct {
+	from, to string
+	label    string
+	broken   bool
+	requires string
+}
+
+// storyGraph collects nodes and edges in a stable order for export
+type storyGraph struct {
+	arcs    []string
+	missing []string
+	edges   []graphEdge
+}
+
+func newStoryGraph(arcs map[string]models.Arc) *storyGraph {
+	g := &storyGraph{}
+	for name := range arcs {
+		g.arcs = append(g.arcs, name)
+	}
+	sort.Strings(g.arcs)
+
+	missing := make(map[string]bool)
+	for _, name := range g.arcs {
+		for _, option := range arcs[name].Options {
+			_, exists := arcs[option.Arc]
+			if !exists && !missing[option.Arc] {
+				missing[option.Arc] = true
+				g.missing = append(g.missing, option.Arc)
+			}
+			g.edges = append(g.edges, graphEdge{
+				from:     name,
+				to:       option.Arc,
+				label:    option.Text,
+				broken:   !exists,
+				requires: option.Requires,
+			})
+		}
+	}
+	sort.Strings(g.missing)
+
+	return g
+}
+
+// ExportDOT renders arcs as a Graphviz digraph. Endings are drawn as double
+// octagons, broken links point at red dashed placeholder nodes, and options
+// with requirements are drawn as dashed edges.
+func ExportDOT(name string, arcs map[string]models.Arc) string {
+	g := newStoryGraph(arcs)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(name))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=\"#f8f9fa\", fontname=\"Helvetica\"];\n")
+	b.WriteString("  edge [fontname=\"Helvetica\", fontsize=10];\n\n")
+
+	for _, arcName := range g.arcs {
+		arc := arcs[arcName]
+		label := dotQuote(arc.Title + "\n(" + arcName + ")")
+		switch {
+		case len(arc.Options) == 0:
+			fmt.Fprintf(&b, "  %s [label=%s, shape=doubleoctagon, fillcolor=\"#97BC62\"];\n", dotQuote(arcName), label)
+		case arcName == "intro":
+			fmt.Fprintf(&b, "  %s [label=%s, fillcolor=\"#D0BDF4\"];\n", dotQuote(arcName), label)
+		default:
+			fmt.Fprintf(&b, "  %s [label=%s];\n", dotQuote(arcName), label)
+		}
+	}
+	for _, arcName := range g.missing {
+		fmt.Fprintf(&b, "  %s [label=%s, style=\"dashed\", color=\"#e74c3c\", fontcolor=\"#e74c3c\"];\n",
+			dotQuote(arcName), dotQuote(arcName+"\n(missing)"))
+	}
+
+	b.WriteString("\n")
+	for _, e := range g.edges {
+		label := e.label
+		if e.requires != "" {
+			label += "\n[" + e.requires + "]"
+		}
+
+		var attrs []string
+		attrs = append(attrs, "label="+dotQuote(label))
+		if e.requires != "" {
+			attrs = append(attrs, "style=dashed")
+		}
+		if e.broken {
+			attrs = append(attrs, "color=\"#e74c3c\"", "fontcolor=\"#e74c3c\"")
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", dotQuote(e.from), dotQuote(e.to), strings.Join(attrs, ", "))
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ExportMermaid renders arcs as a Mermaid flowchart using the same
+// conventions as ExportDOT
+func ExportMermaid(arcs map[string]models.Arc) string {
+	g := newStoryGraph(arcs)
+
+	// Arc names may clash with Mermaid keywords such as "end", so nodes get
+	// generated IDs
+	ids := make(map[string]string, len(g.arcs)+len(g.missing))
+	for i, arcName := range append(append([]string{}, g.arcs...), g.missing...) {
+		ids[arcName] = fmt.Sprintf("n%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+
+	var endings, broken []string
+	for _, arcName := range g.arcs {
+		arc := arcs[arcName]
+		label := mermaidQuote(arc.Title + "<br/>(" + arcName + ")")
+		if len(arc.Options) == 0 {
+			fmt.Fprintf(&b, "  %s([%s])\n", ids[arcName], label)
+			endings = append(endings, ids[arcName])
+		} else {
+			fmt.Fprintf(&b, "  %s[%s]\n", ids[arcName], label)
+		}
+	}
+	for _, arcName := range g.missing {
+		fmt.Fprintf(&b, "  %s[%s]\n", ids[arcName], mermaidQuote(arcName+"<br/>(missing)"))
+		broken = append(broken, ids[arcName])
+	}
+
+	var brokenLinks []string
+	for i, e := range g.edges {
+		label := e.label
+		if e.requires != "" {
+			label += " [" + e.requires + "]"
+		}
+		arrow := "-->"
+		if e.requires != "" {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s|%s| %s\n", ids[e.from], arrow, mermaidQuote(label), ids[e.to])
+		if e.broken {
+			brokenLinks = append(brokenLinks, fmt.Sprint(i))
+		}
+	}
+
+	b.WriteString("  classDef ending fill:#97BC62,color:#fff,stroke:#97BC62\n")
+	b.WriteString("  classDef broken fill:#fff,color:#e74c3c,stroke:#e74c3c,stroke-dasharray:5 5\n")
+	if _, exists := ids["intro"]; exists && len(arcs["intro"].Options) > 0 {
+		b.WriteString("  classDef start fill:#D0BDF4,stroke:#D0BDF4\n")
+		fmt.Fprintf(&b, "  class %s start\n", ids["intro"])
+	}
+	if len(endings) > 0 {
+		fmt.Fprintf(&b, "  class %s ending\n", strings.Join(endings, ","))
+	}
+	if len(broken) > 0 {
+		fmt.Fprintf(&b, "  class %s broken\n", strings.Join(broken, ","))
+	}
+	if len(brokenLinks) > 0 {
+		fmt.Fprintf(&b, "  linkStyle %s stroke:#e74c3c,color:#e74c3c\n", strings.Join(brokenLinks, ","))
+	}
+
+	return b.String()
+}
+
+// dotQuote quotes a string as a DOT identifier, turning newlines into line breaks
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// mermaidQuote quotes a label, escaping characters Mermaid would interpret
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "|", "#124;")
+	return `"` + s + `"`
+}
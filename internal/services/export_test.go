@@ -0,0 +1,82 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"GopherTales/internal/models"
+)
+
+func exportTestArcs() map[string]models.Arc {
+	return map[string]models.Arc{
+		"intro": {
+			Title: "The \"Start\"",
+			Options: []models.Option{
+				{Text: "Go home", Arc: "home"},
+				{Text: "Use the map", Arc: "home", Requires: "has_map"},
+				{Text: "Get lost", Arc: "nowhere"},
+			},
+		},
+		"home": {Title: "Home"},
+	}
+}
+
+func TestExportDOT(t *testing.T) {
+	dot := ExportDOT("blue", exportTestArcs())
+
+	expected := []string{
+		`digraph "blue" {`,
+		`"intro" [label="The \"Start\"\n(intro)", fillcolor="#D0BDF4"];`,
+		`"home" [label="Home\n(home)", shape=doubleoctagon`,
+		`"nowhere" [label="nowhere\n(missing)", style="dashed", color="#e74c3c"`,
+		`"intro" -> "home" [label="Go home"];`,
+		`"intro" -> "home" [label="Use the map\n[has_map]", style=dashed];`,
+		`"intro" -> "nowhere" [label="Get lost", color="#e74c3c"`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(dot, want) {
+			t.Errorf("Expected DOT output to contain %q\n%s", want, dot)
+		}
+	}
+}
+
+func TestExportMermaid(t *testing.T) {
+	mermaid := ExportMermaid(exportTestArcs())
+
+	// Nodes are numbered by sorted arc name, then missing targets
+	expected := []string{
+		"flowchart TD",
+		`n0(["Home<br/>(home)"])`,
+		`n1["The #quot;Start#quot;<br/>(intro)"]`,
+		`n2["nowhere<br/>(missing)"]`,
+		`n1 -->|"Go home"| n0`,
+		`n1 -.->|"Use the map [has_map]"| n0`,
+		`n1 -->|"Get lost"| n2`,
+		"class n0 ending",
+		"class n2 broken",
+		"linkStyle 2 stroke:#e74c3c",
+	}
+	for _, want := range expected {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Expected Mermaid output to contain %q\n%s", want, mermaid)
+		}
+	}
+}
+
+func TestStoryService_ExportGraph(t *testing.T) {
+	service := NewStoryService("nonexistent.json")
+	service.gopherStories = map[string]map[string]models.Arc{"blue": exportTestArcs()}
+
+	if _, err := service.ExportGraph("blue", GraphFormatDOT); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := service.ExportGraph("blue", GraphFormatMermaid); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := service.ExportGraph("blue", "svg"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+	if _, err := service.ExportGraph("pink", GraphFormatDOT); err == nil {
+		t.Error("Expected error for unknown gopher")
+	}
+}
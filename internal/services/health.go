@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DependencyStatus is the outcome of one readiness check
+type DependencyStatus struct {
+	Status    string `json:"status"` // "ok" or "failing"
+	Error     string `json:"-"`      // Why the check failed, for the server log only
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// HealthChecker decides whether the server is ready for traffic by checking
+// each dependency it needs. Checks run concurrently and each gets the same
+// timeout, so one hung dependency fails the probe instead of stalling it.
+type HealthChecker struct {
+	timeout time.Duration
+	checks  []healthCheck
+}
+
+// NewHealthChecker creates a checker whose checks may each take timeout,
+// zero for no limit
+func NewHealthChecker(timeout time.Duration) *HealthChecker {
+	return &HealthChecker{timeout: timeout}
+}
+
+// Add registers a dependency. Checks should give up when ctx is done.
+func (h *HealthChecker) Add(name string, check func(ctx context.Context) error) {
+	h.checks = append(h.checks, healthCheck{name: name, check: check})
+}
+
+// Check runs every check and reports whether all of them passed, with the
+// status of each dependency by name
+func (h *HealthChecker) Check(ctx context.Context) (bool, map[string]DependencyStatus) {
+	statuses := make(map[string]DependencyStatus, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func(c healthCheck) {
+			defer wg.Done()
+			status := h.run(ctx, c)
+			mu.Lock()
+			statuses[c.name] = status
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, status := range statuses {
+		if status.Status != "ok" {
+			ready = false
+		}
+	}
+	return ready, statuses
+}
+
+// run runs one check, giving up on it once the timeout passes even if the
+// check itself does not
+func (h *HealthChecker) run(ctx context.Context, c healthCheck) DependencyStatus {
+	var cancel context.CancelFunc
+	if h.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	status := DependencyStatus{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		status.Status = "failing"
+		status.Error = err.Error()
+	}
+	return status
+}
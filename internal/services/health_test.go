@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthChecker_Check(t *testing.T) {
+	tests := []struct {
+		name      string
+		check     func(ctx context.Context) error
+		wantReady bool
+		wantError string
+	}{
+		{
+			name:      "passing",
+			check:     func(ctx context.Context) error { return nil },
+			wantReady: true,
+		},
+		{
+			name:      "failing",
+			check:     func(ctx context.Context) error { return errors.New("connection refused") },
+			wantError: "connection refused",
+		},
+		{
+			name: "hung",
+			check: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			wantError: context.DeadlineExceeded.Error(),
+		},
+		{
+			// A check that ignores its context must not stall the probe
+			name: "ignores timeout",
+			check: func(ctx context.Context) error {
+				time.Sleep(time.Second)
+				return nil
+			},
+			wantError: context.DeadlineExceeded.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewHealthChecker(50 * time.Millisecond)
+			checker.Add("story", func(ctx context.Context) error { return nil })
+			checker.Add("database", tt.check)
+
+			start := time.Now()
+			ready, statuses := checker.Check(context.Background())
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("Expected checks to give up after the timeout, took %v", elapsed)
+			}
+
+			if ready != tt.wantReady {
+				t.Errorf("Expected ready %v, got %v", tt.wantReady, ready)
+			}
+			if statuses["story"].Status != "ok" {
+				t.Errorf("Expected story ok, got %+v", statuses["story"])
+			}
+			database := statuses["database"]
+			if database.Error != tt.wantError {
+				t.Errorf("Expected error %q, got %q", tt.wantError, database.Error)
+			}
+			if wantStatus := map[bool]string{true: "ok", false: "failing"}[tt.wantReady]; database.Status != wantStatus {
+				t.Errorf("Expected status %s, got %s", wantStatus, database.Status)
+			}
+
+			// The error is for the server log, never the public response
+			body, err := json.Marshal(database)
+			if err != nil {
+				t.Fatalf("Failed to encode status: %v", err)
+			}
+			if tt.wantError != "" && strings.Contains(string(body), tt.wantError) {
+				t.Errorf("Expected the encoded status to leave out the error, got %s", body)
+			}
+		})
+	}
+}
+
+func TestStoryService_Validate(t *testing.T) {
+	if err := NewStoryService("unused.json").Validate(); !errors.Is(err, ErrStoryNotLoaded) {
+		t.Errorf("Expected ErrStoryNotLoaded before loading, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "story.json")
+	writeStoryFile(t, path, reloadTestStory("First"), 0)
+	service := NewStoryService(path)
+	if err := service.LoadStory(); err != nil {
+		t.Fatalf("Failed to load story: %v", err)
+	}
+	if err := service.Validate(); err != nil {
+		t.Errorf("Expected valid story, got %v", err)
+	}
+
+	// Broken links load, but are not ready to serve
+	writeStoryFile(t, path, `{"blue": {
+		"intro": {"title": "Start", "story": ["Hello"], "options": [{"text": "Go", "arc": "nowhere"}]}
+	}}`, 0)
+	broken := NewStoryService(path)
+	if err := broken.LoadStory(); err != nil {
+		t.Fatalf("Failed to load story: %v", err)
+	}
+	var validation *StoryValidationError
+	if err := broken.Validate(); !errors.As(err, &validation) {
+		t.Errorf("Expected a StoryValidationError, got %v", err)
+	}
+
+	// The result is kept from the last load, and a failed reload keeps it
+	if err := service.Validate(); err != nil {
+		t.Errorf("Expected the loaded story to stay valid until reloaded, got %v", err)
+	}
+	if err := service.Reload(); err == nil {
+		t.Errorf("Expected reloading the broken story to fail")
+	}
+	if err := service.Validate(); err != nil {
+		t.Errorf("Expected the served story to stay valid, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"GopherTales/internal/models"
+)
+
+// Lint severities
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintStoryFile loads a story file through the regular loader and lints it,
+// checking arc assets against staticDir when it is set. Load failures are
+// returned as a single issue.
+func LintStoryFile(path, staticDir string) []models.LintIssue {
+	service := NewStoryService(path)
+	if err := service.LoadStory(); err != nil {
+		issue := models.LintIssue{
+			Severity: LintError,
+			Code:     "load",
+			Message:  err.Error(),
+		}
+
+		var fileErr *StoryFileError
+		if errors.As(err, &fileErr) {
+			issue.Code = "syntax"
+			issue.Message = fileErr.Err.Error()
+			issue.Line = fileErr.Line
+			issue.Column = fileErr.Column
+		}
+		return []models.LintIssue{issue}
+	}
+
+	// Assets are checked by Lint rather than by LoadStory, so they are
+	// reported alongside every other issue
+	service.staticDir = staticDir
+	return service.Lint()
+}
+
+// Lint checks the loaded story for authoring mistakes
+func (s *StoryService) Lint() []models.LintIssue {
+	return s.lint(s.snapshot())
+}
+
+// lint checks one snapshot of a story
+func (s *StoryService) lint(story *models.Story, gophers map[string]map[string]models.Arc, catalog []models.Gopher) []models.LintIssue {
+	if len(gophers) == 0 {
+		return append(lintArcs("", story.Arcs), lintAssets(s.staticDir, "", story.Arcs)...)
+	}
+
+	issues := lintCatalog(catalog, gophers)
+	for _, gopher := range sortedKeys(gophers) {
+		issues = append(issues, lintArcs(gopher, gophers[gopher])...)
+		issues = append(issues, lintAssets(s.staticDir, gopher, gophers[gopher])...)
+	}
+	return issues
+}
+
+// themeColorPattern matches hex colours and named CSS colours
+var themeColorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// lintCatalog checks the gopher catalog against the gophers that have stories
+func lintCatalog(catalog []models.Gopher, gophers map[string]map[string]models.Arc) []models.LintIssue {
+	var issues []models.LintIssue
+	add := func(severity, code, gopher, format string, args ...any) {
+		issues = append(issues, models.LintIssue{
+			Severity: severity,
+			Code:     code,
+			Gopher:   gopher,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(catalog) == 0 {
+		add(LintWarning, "missing-catalog", "", "story has no gopher catalog, so gophers get generated names and the default portrait")
+		return issues
+	}
+
+	listed := make(map[string]bool, len(catalog))
+	for i, profile := range catalog {
+		if profile.ID == "" {
+			add(LintError, "invalid-catalog-entry", "", "catalog entry %d has no id", i+1)
+			continue
+		}
+		if listed[profile.ID] {
+			add(LintError, "duplicate-catalog-entry", profile.ID, "gopher '%s' is listed in the catalog more than once", profile.ID)
+			continue
+		}
+		listed[profile.ID] = true
+
+		if _, exists := gophers[profile.ID]; !exists {
+			add(LintWarning, "unused-catalog-entry", profile.ID, "catalog lists '%s' but it has no story", profile.ID)
+		}
+		if profile.Name == "" {
+			add(LintWarning, "missing-gopher-name", profile.ID, "catalog entry has no name")
+		}
+		if profile.Portrait == "" {
+			add(LintWarning, "missing-portrait", profile.ID, "catalog entry has no portrait")
+		}
+		if profile.ThemeColor != "" && !themeColorPattern.MatchString(profile.ThemeColor) {
+			add(LintWarning, "invalid-theme-color", profile.ID, "theme colour '%s' is not a hex or named CSS colour", profile.ThemeColor)
+		}
+	}
+
+	for _, gopher := range sortedKeys(gophers) {
+		if !listed[gopher] {
+			add(LintWarning, "unknown-gopher", gopher, "'%s' is not in the gopher catalog and has no portrait", gopher)
+		}
+	}
+	return issues
+}
+
+// lintArcs checks a single arc map
+func lintArcs(gopher string, arcs map[string]models.Arc) []models.LintIssue {
+	var issues []models.LintIssue
+	add := func(severity, code, arc, format string, args ...any) {
+		issues = append(issues, models.LintIssue{
+			Severity: severity,
+			Code:     code,
+			Gopher:   gopher,
+			Arc:      arc,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	analysis := AnalyzeArcs(gopher, arcs)
+	if analysis.MissingIntro {
+		add(LintError, "missing-intro", "", "story has no 'intro' arc")
+	}
+
+	names := make([]string, 0, len(arcs))
+	for name := range arcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		arc := arcs[name]
+
+		if strings.TrimSpace(arc.Title) == "" {
+			add(LintError, "empty-title", name, "arc has no title")
+		}
+		if len(arc.Story) == 0 {
+			add(LintWarning, "empty-paragraph", name, "arc has no story paragraphs")
+		}
+		for i, paragraph := range arc.Story {
+			if strings.TrimSpace(paragraph) == "" {
+				add(LintWarning, "empty-paragraph", name, "paragraph %d is empty", i+1)
+			}
+		}
+
+		for _, variable := range sortedKeys(arc.Set) {
+			if !IsScalarValue(arc.Set[variable]) {
+				add(LintError, "invalid-set", name, "'%s' is set to a value that is not a string, number or boolean", variable)
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, option := range arc.Options {
+			text := strings.ToLower(strings.TrimSpace(option.Text))
+			if text == "" {
+				add(LintError, "empty-option", name, "option to '%s' has no text", option.Arc)
+			} else if seen[text] {
+				add(LintWarning, "duplicate-option", name, "option text %q appears more than once", option.Text)
+			}
+			seen[text] = true
+
+			if option.Requires != "" {
+				if _, err := ParseCondition(option.Requires); err != nil {
+					add(LintError, "invalid-requirement", name, "requirement %q: %v", option.Requires, err)
+				}
+			}
+		}
+	}
+
+	for _, link := range analysis.BrokenLinks {
+		add(LintError, "broken-link", link.Arc, "option %q points to missing arc '%s'", link.Option, link.Target)
+	}
+	for _, name := range analysis.UnreachableArcs {
+		add(LintWarning, "unreachable-arc", name, "arc cannot be reached from intro")
+	}
+	for _, name := range analysis.DeadEnds {
+		add(LintError, "dead-end", name, "no ending can be reached from this arc")
+	}
+
+	return issues
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"GopherTales/internal/models"
+)
+
+func writeTempStory(t *testing.T, content string) string {
+	t.Helper()
+
+	tmpFile, err := os.CreateTemp("", "lint-story-*.json")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	t.Cleanup(func() { os.Remove(tmpFile.Name()) })
+
+	if _, err := tmpFile.WriteString(content); err != nil {
+		t.Fatalf("Failed to write to temp file: %v", err)
+	}
+	tmpFile.Close()
+
+	return tmpFile.Name()
+}
+
+func issueCodes(issues []models.LintIssue) map[string]int {
+	codes := make(map[string]int)
+	for _, issue := range issues {
+		codes[issue.Code]++
+	}
+	return codes
+}
+
+func TestLintStoryFile_SyntaxError(t *testing.T) {
+	path := writeTempStory(t, "{\n  \"blue\": {\n    \"intro\": {\"title\": \"x\",, }\n  }\n}")
+
+	issues := LintStoryFile(path, "")
+	if len(issues) != 1 {
+		t.Fatalf("Expected 1 issue, got %d: %v", len(issues), issues)
+	}
+	if issues[0].Code != "syntax" {
+		t.Errorf("Expected syntax issue, got %s", issues[0].Code)
+	}
+	if issues[0].Line != 3 || issues[0].Column != 28 {
+		t.Errorf("Expected position 3:28, got %d:%d", issues[0].Line, issues[0].Column)
+	}
+}
+
+func TestLoadStory_GopherTypeError(t *testing.T) {
+	// A schema mistake in gopher data must not fall back to the classic format
+	path := writeTempStory(t, `{"blue": {"intro": {"title": "x", "story": "not a list"}}}`)
+
+	service := NewStoryService(path)
+	err := service.LoadStory()
+	if err == nil {
+		t.Fatal("Expected error for wrong field type")
+	}
+
+	var fileErr *StoryFileError
+	if !errors.As(err, &fileErr) {
+		t.Fatalf("Expected StoryFileError, got %T", err)
+	}
+	if fileErr.Line != 1 {
+		t.Errorf("Expected error on line 1, got %d", fileErr.Line)
+	}
+}
+
+func TestLintStoryFile_Issues(t *testing.T) {
+	path := writeTempStory(t, `{
+		"catalog": [
+			{"id": "blue", "name": "Blue Gopher", "portrait": "gopher_blue.png", "theme_color": "#3498db"},
+			{"id": "blue", "name": "Blue Again", "portrait": "gopher_blue.png"},
+			{"id": "grey", "name": "Grey Gopher", "theme_color": "url(x)"}
+		],
+		"blue": {
+			"intro": {
+				"title": "Intro",
+				"story": ["Start.", " "],
+				"options": [
+					{"text": "Go on", "arc": "middle"},
+					{"text": "go on", "arc": "middle"},
+					{"text": "Vanish", "arc": "missing"},
+					{"text": "Secret", "arc": "middle", "requires": "has_map =="}
+				]
+			},
+			"middle": {
+				"title": "",
+				"story": ["Middle."],
+				"options": [{"text": "Finish", "arc": "home"}]
+			},
+			"home": {"title": "Home", "story": ["The end."], "options": []},
+			"orphan": {"title": "Orphan", "story": ["Alone."], "options": []}
+		},
+		"teal": {
+			"start": {"title": "Start", "story": ["Hi."], "options": []}
+		}
+	}`)
+
+	codes := issueCodes(LintStoryFile(path, ""))
+
+	expected := map[string]int{
+		"empty-paragraph":         1,
+		"duplicate-option":        1,
+		"invalid-requirement":     1,
+		"empty-title":             1,
+		"broken-link":             1,
+		"unreachable-arc":         2, // blue/orphan and teal/start
+		"unknown-gopher":          1,
+		"missing-intro":           1,
+		"duplicate-catalog-entry": 1,
+		"unused-catalog-entry":    1,
+		"missing-portrait":        1,
+		"invalid-theme-color":     1,
+	}
+	for code, count := range expected {
+		if codes[code] != count {
+			t.Errorf("Expected %d %s issue(s), got %d", count, code, codes[code])
+		}
+	}
+	if codes["dead-end"] != 0 {
+		t.Errorf("Expected no dead-end issues, got %d", codes["dead-end"])
+	}
+}
+
+func TestLoadStory_NonScalarSet(t *testing.T) {
+	path := writeTempStory(t, `{"blue": {
+		"intro": {"title": "Intro", "story": ["Hi."], "options": [], "set": {"items": ["map"]}}
+	}}`)
+
+	err := NewStoryService(path).LoadStory()
+	if err == nil || !strings.Contains(err.Error(), "'blue:intro' sets 'items'") {
+		t.Errorf("Expected a list in set to be rejected, got %v", err)
+	}
+
+	issues := lintArcs("blue", map[string]models.Arc{
+		"intro": {Title: "Intro", Story: []string{"Hi."}, Set: map[string]any{"bag": map[string]any{}, "ok": 1}},
+	})
+	if codes := issueCodes(issues); codes["invalid-set"] != 1 {
+		t.Errorf("Expected 1 invalid-set issue, got %d", codes["invalid-set"])
+	}
+}
+
+func TestLintStoryFile_Clean(t *testing.T) {
+	issues := LintStoryFile("../../gopher_six.json", "../../static")
+	if len(issues) != 0 {
+		t.Errorf("Expected bundled story to lint cleanly, got %v", issues)
+	}
+}
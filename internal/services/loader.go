@@ -0,0 +1,422 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"GopherTales/internal/models"
+)
+
+// StorySource is the decoded content of a story file or directory. Exactly
+// one of Gophers (gopher-based stories) or Arcs (classic single story) is set.
+type StorySource struct {
+	Gophers map[string]map[string]models.Arc
+	Arcs    map[string]models.Arc
+	Catalog []models.Gopher // Optional, only for gopher-based stories
+}
+
+// StoryLoader reads story data from a file or directory
+type StoryLoader interface {
+	Load(path string) (*StorySource, error)
+}
+
+// JSONLoader loads a single JSON story file
+type JSONLoader struct{}
+
+// YAMLLoader loads a single YAML story file
+type YAMLLoader struct{}
+
+// TOMLLoader loads a single TOML story file
+type TOMLLoader struct{}
+
+// TweeLoader loads a Twine (Twee 3) story file
+type TweeLoader struct{}
+
+// DirectoryLoader loads one file per arc from <dir>/<gopher>/<arc>.<ext>,
+// where each file holds a single arc in JSON, YAML or TOML. An optional
+// <dir>/catalog.<ext> holds the gopher catalog.
+type DirectoryLoader struct{}
+
+// checkSetValues rejects arcs that set a variable to a list, object or null.
+// Conditions can only compare strings, numbers and booleans.
+func checkSetValues(source *StorySource) error {
+	check := func(gopher string, arcs map[string]models.Arc) error {
+		for _, name := range sortedKeys(arcs) {
+			for _, variable := range sortedKeys(arcs[name].Set) {
+				if !IsScalarValue(arcs[name].Set[variable]) {
+					arc := name
+					if gopher != "" {
+						arc = gopher + ":" + name
+					}
+					return fmt.Errorf("arc '%s' sets '%s' to a value that is not a string, number or boolean", arc, variable)
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, gopher := range sortedKeys(source.Gophers) {
+		if err := check(gopher, source.Gophers[gopher]); err != nil {
+			return err
+		}
+	}
+	return check("", source.Arcs)
+}
+
+// LoaderForPath picks a loader for a story file or directory
+func LoaderForPath(path string) (StoryLoader, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read story file %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return DirectoryLoader{}, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSONLoader{}, nil
+	case ".yaml", ".yml":
+		return YAMLLoader{}, nil
+	case ".toml":
+		return TOMLLoader{}, nil
+	case ".twee", ".tw":
+		return TweeLoader{}, nil
+	}
+	return nil, fmt.Errorf("unsupported story file type '%s' for %s", filepath.Ext(path), path)
+}
+
+// Load implements StoryLoader
+func (JSONLoader) Load(path string) (*StorySource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read story file %s: %w", path, err)
+	}
+	return decodeStorySource(path, data, true)
+}
+
+// Load implements StoryLoader
+func (YAMLLoader) Load(path string) (*StorySource, error) {
+	data, err := readAsJSON(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStorySource(path, data, false)
+}
+
+// Load implements StoryLoader
+func (TOMLLoader) Load(path string) (*StorySource, error) {
+	data, err := readAsJSON(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStorySource(path, data, false)
+}
+
+// Load implements StoryLoader
+func (TweeLoader) Load(path string) (*StorySource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read story file %s: %w", path, err)
+	}
+
+	gophers, err := ParseTwee(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Twee story: %w", &StoryFileError{File: path, Err: err})
+	}
+	return &StorySource{Gophers: gophers}, nil
+}
+
+// Load implements StoryLoader
+func (DirectoryLoader) Load(dir string) (*StorySource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read story directory %s: %w", dir, err)
+	}
+
+	source := &StorySource{Gophers: make(map[string]map[string]models.Arc)}
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && isArcFileExtension(ext) && strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())) == catalogKey {
+			if source.Catalog != nil {
+				return nil, fmt.Errorf("duplicate gopher catalog in %s", dir)
+			}
+			catalog, err := loadCatalogFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			source.Catalog = catalog
+			continue
+		}
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		gopher := entry.Name()
+		arcs, err := loadArcDirectory(filepath.Join(dir, gopher))
+		if err != nil {
+			return nil, err
+		}
+		if len(arcs) > 0 {
+			source.Gophers[gopher] = arcs
+		}
+	}
+
+	if len(source.Gophers) == 0 {
+		return nil, fmt.Errorf("no gopher stories found in %s", dir)
+	}
+	return source, nil
+}
+
+// loadCatalogFile reads a gopher catalog (a list of gophers) from a JSON,
+// YAML or TOML file. TOML has no top-level arrays, so there the list is
+// written as [[catalog]] tables.
+func loadCatalogFile(path string) ([]models.Gopher, error) {
+	data, err := readAsJSON(path)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := strings.EqualFold(filepath.Ext(path), ".json")
+	var catalog []models.Gopher
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		var table struct {
+			Catalog []models.Gopher `json:"catalog"`
+		}
+		err = json.Unmarshal(data, &table)
+		catalog = table.Catalog
+	} else {
+		err = json.Unmarshal(data, &catalog)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal gopher catalog: %w", newStoryFileError(path, data, err, positions))
+	}
+	return catalog, nil
+}
+
+// loadArcDirectory reads every arc file in a gopher's directory
+func loadArcDirectory(dir string) (map[string]models.Arc, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read story directory %s: %w", dir, err)
+	}
+
+	// Sort so duplicate detection reports the same file every time
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	arcs := make(map[string]models.Arc)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || !isArcFileExtension(ext) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if _, exists := arcs[name]; exists {
+			return nil, fmt.Errorf("duplicate arc '%s' in %s", name, dir)
+		}
+
+		data, err := readAsJSON(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var arc models.Arc
+		if err := json.Unmarshal(data, &arc); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal arc: %w", newStoryFileError(path, data, err, ext == ".json"))
+		}
+		arcs[name] = arc
+	}
+
+	return arcs, nil
+}
+
+func isArcFileExtension(ext string) bool {
+	switch ext {
+	case ".json", ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// readAsJSON reads a JSON, YAML or TOML file and returns its content as JSON,
+// so every format is decoded against the same schema
+func readAsJSON(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read story file %s: %w", path, err)
+	}
+
+	var doc any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return data, nil
+
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", &StoryFileError{File: path, Line: yamlErrorLine(err), Err: err})
+		}
+
+	case ".toml":
+		var table map[string]any
+		if _, err := toml.Decode(string(data), &table); err != nil {
+			fileErr := &StoryFileError{File: path, Err: err}
+			var parseErr toml.ParseError
+			if errors.As(err, &parseErr) {
+				fileErr.Line, fileErr.Column = lineColumn(data, int64(parseErr.Position.Start)+1)
+			}
+			return nil, fmt.Errorf("failed to parse TOML: %w", fileErr)
+		}
+		doc = table
+
+	default:
+		return nil, fmt.Errorf("unsupported story file type '%s' for %s", filepath.Ext(path), path)
+	}
+
+	converted, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s: %w", path, err)
+	}
+	return converted, nil
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// yamlErrorLine extracts the line number yaml.v3 embeds in its messages
+func yamlErrorLine(err error) int {
+	m := yamlLinePattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(m[1])
+	return line
+}
+
+// catalogKey is the reserved top-level key (or, for story directories, file
+// name) that holds the gopher catalog
+const catalogKey = "catalog"
+
+// documentEntry is a top-level value of a story document along with where it
+// starts, so decoding errors can point into the original file
+type documentEntry struct {
+	value  json.RawMessage
+	offset int64
+}
+
+// decodeStorySource detects whether JSON data holds gopher-based or classic
+// stories. Positions are only meaningful when data is the original file.
+func decodeStorySource(path string, data []byte, positions bool) (*StorySource, error) {
+	entries, err := splitDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal story data: %w", newStoryFileError(path, data, err, positions))
+	}
+
+	var catalog []models.Gopher
+	catalogEntry, hasCatalog := entries[catalogKey]
+	if hasCatalog {
+		delete(entries, catalogKey)
+		if err := catalogEntry.decode(&catalog); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal gopher catalog: %w", newStoryFileError(path, data, err, positions))
+		}
+	}
+
+	if hasCatalog || isGopherStructure(entries) {
+		gophers := make(map[string]map[string]models.Arc, len(entries))
+		for gopher, entry := range entries {
+			var arcs map[string]models.Arc
+			if err := entry.decode(&arcs); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal story data: %w", newStoryFileError(path, data, err, positions))
+			}
+			gophers[gopher] = arcs
+		}
+		return &StorySource{Gophers: gophers, Catalog: catalog}, nil
+	}
+
+	// Load as classic structure
+	arcs := make(map[string]models.Arc, len(entries))
+	for name, entry := range entries {
+		var arc models.Arc
+		if err := entry.decode(&arc); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal story data: %w", newStoryFileError(path, data, err, positions))
+		}
+		arcs[name] = arc
+	}
+	return &StorySource{Arcs: arcs}, nil
+}
+
+// splitDocument splits a JSON object into its raw top-level values
+func splitDocument(data []byte) (map[string]documentEntry, error) {
+	// Unmarshal first so syntax errors are reported against the whole file
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]documentEntry, len(top))
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		entries[token.(string)] = documentEntry{
+			value:  value,
+			offset: dec.InputOffset() - int64(len(value)),
+		}
+	}
+	return entries, nil
+}
+
+// decode unmarshals the entry, shifting error offsets to the whole document
+func (e documentEntry) decode(v any) error {
+	err := json.Unmarshal(e.value, v)
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		syntaxErr.Offset += e.offset
+	case errors.As(err, &typeErr):
+		typeErr.Offset += e.offset
+	}
+	return err
+}
+
+// isGopherStructure reports whether the document nests arcs under gophers.
+// Gopher stories are objects of arc objects, whereas classic arcs contain
+// strings and arrays.
+func isGopherStructure(entries map[string]documentEntry) bool {
+	found := false
+	for _, entry := range entries {
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(entry.value, &members); err != nil {
+			return false
+		}
+		for _, member := range members {
+			if !bytes.HasPrefix(member, []byte("{")) {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
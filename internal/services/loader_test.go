@@ -0,0 +1,240 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"GopherTales/internal/models"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func expectedLoaderArcs() map[string]models.Arc {
+	return map[string]models.Arc{
+		"intro": {
+			Title: "Intro",
+			Story: []string{"Once upon a time."},
+			Options: []models.Option{
+				{Text: "Go home", Arc: "home"},
+				{Text: "Use the map", Arc: "home", Requires: "has_map == true"},
+			},
+			Set: map[string]any{"has_map": true},
+		},
+		"home": {
+			Title:   "Home",
+			Story:   []string{"The end."},
+			Options: []models.Option{},
+		},
+	}
+}
+
+func TestLoaders_EquivalentFormats(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"story.json": `{
+			"blue": {
+				"intro": {
+					"title": "Intro",
+					"story": ["Once upon a time."],
+					"options": [
+						{"text": "Go home", "arc": "home"},
+						{"text": "Use the map", "arc": "home", "requires": "has_map == true"}
+					],
+					"set": {"has_map": true}
+				},
+				"home": {"title": "Home", "story": ["The end."], "options": []}
+			}
+		}`,
+		"story.yaml": `
+blue:
+  intro:
+    title: Intro
+    story:
+      - Once upon a time.
+    options:
+      - text: Go home
+        arc: home
+      - text: Use the map
+        arc: home
+        requires: has_map == true
+    set:
+      has_map: true
+  home:
+    title: Home
+    story: ["The end."]
+    options: []
+`,
+		"story.toml": `
+[blue.intro]
+title = "Intro"
+story = ["Once upon a time."]
+set = { has_map = true }
+
+[[blue.intro.options]]
+text = "Go home"
+arc = "home"
+
+[[blue.intro.options]]
+text = "Use the map"
+arc = "home"
+requires = "has_map == true"
+
+[blue.home]
+title = "Home"
+story = ["The end."]
+options = []
+`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, content)
+
+		service := NewStoryService(path)
+		if err := service.LoadStory(); err != nil {
+			t.Errorf("%s: failed to load: %v", name, err)
+			continue
+		}
+
+		if got := service.GetGopherArcs("blue"); !reflect.DeepEqual(got, expectedLoaderArcs()) {
+			t.Errorf("%s: unexpected arcs:\n got  %+v\n want %+v", name, got, expectedLoaderArcs())
+		}
+	}
+}
+
+func TestDirectoryLoader(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "blue", "intro.yaml"), `
+title: Intro
+story: ["Once upon a time."]
+options:
+  - {text: Go home, arc: home}
+  - {text: Use the map, arc: home, requires: "has_map == true"}
+set: {has_map: true}
+`)
+	writeFile(t, filepath.Join(dir, "blue", "home.toml"), `
+title = "Home"
+story = ["The end."]
+options = []
+`)
+	writeFile(t, filepath.Join(dir, "pink", "intro.json"), `{"title": "Pink", "story": [], "options": []}`)
+	writeFile(t, filepath.Join(dir, "blue", "notes.txt"), "ignored")
+	writeFile(t, filepath.Join(dir, "README.md"), "ignored")
+	writeFile(t, filepath.Join(dir, "catalog.toml"), `
+[[catalog]]
+id = "pink"
+name = "Pink Gopher"
+portrait = "gopher_pink.png"
+`)
+
+	service := NewStoryService(dir)
+	if err := service.LoadStory(); err != nil {
+		t.Fatalf("Failed to load directory: %v", err)
+	}
+
+	if got := service.GetGopherArcs("blue"); !reflect.DeepEqual(got, expectedLoaderArcs()) {
+		t.Errorf("Unexpected blue arcs:\n got  %+v\n want %+v", got, expectedLoaderArcs())
+	}
+	arc, _, err := service.GetGopherArc("pink", "intro", nil)
+	if err != nil {
+		t.Errorf("Expected pink intro to load: %v", err)
+	}
+	if arc.Image != "gopher_pink.png" {
+		t.Errorf("Expected portrait from catalog file, got '%s'", arc.Image)
+	}
+	if _, exists := service.GetGopherArcs("catalog")["intro"]; exists {
+		t.Error("Expected catalog file not to be loaded as a gopher")
+	}
+}
+
+func TestDirectoryLoader_Errors(t *testing.T) {
+	empty := t.TempDir()
+	if err := NewStoryService(empty).LoadStory(); err == nil {
+		t.Error("Expected error for directory without gophers")
+	}
+
+	duplicate := t.TempDir()
+	writeFile(t, filepath.Join(duplicate, "blue", "intro.json"), `{"title": "A"}`)
+	writeFile(t, filepath.Join(duplicate, "blue", "intro.yaml"), `title: B`)
+	if err := NewStoryService(duplicate).LoadStory(); err == nil {
+		t.Error("Expected error for duplicate arc files")
+	}
+
+	invalid := t.TempDir()
+	writeFile(t, filepath.Join(invalid, "blue", "intro.json"), "{\n  \"title\": 5\n}")
+	err := NewStoryService(invalid).LoadStory()
+	var fileErr *StoryFileError
+	if !errors.As(err, &fileErr) || fileErr.Line != 2 {
+		t.Errorf("Expected positioned error on line 2, got %v", err)
+	}
+}
+
+func TestLoaders_SyntaxErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]int{
+		"bad.yaml": 3,
+		"bad.toml": 2,
+	}
+	writeFile(t, filepath.Join(dir, "bad.yaml"), "blue:\n  intro:\n    title: Intro\n\tstory: []\n")
+	writeFile(t, filepath.Join(dir, "bad.toml"), "[blue.intro]\ntitle = \n")
+
+	for name, line := range files {
+		err := NewStoryService(filepath.Join(dir, name)).LoadStory()
+		var fileErr *StoryFileError
+		if !errors.As(err, &fileErr) {
+			t.Errorf("%s: expected StoryFileError, got %v", name, err)
+			continue
+		}
+		if fileErr.Line != line {
+			t.Errorf("%s: expected error on line %d, got %d (%v)", name, line, fileErr.Line, err)
+		}
+	}
+}
+
+func TestLoaderForPath(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]StoryLoader{
+		"story.json": JSONLoader{},
+		"story.yml":  YAMLLoader{},
+		"story.YAML": YAMLLoader{},
+		"story.toml": TOMLLoader{},
+		"story.twee": TweeLoader{},
+	}
+
+	for name, expected := range tests {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, "")
+
+		loader, err := LoaderForPath(path)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if reflect.TypeOf(loader) != reflect.TypeOf(expected) {
+			t.Errorf("%s: expected %T, got %T", name, expected, loader)
+		}
+	}
+
+	if loader, err := LoaderForPath(dir); err != nil || reflect.TypeOf(loader) != reflect.TypeOf(DirectoryLoader{}) {
+		t.Errorf("Expected DirectoryLoader for directory, got %T (%v)", loader, err)
+	}
+
+	writeFile(t, filepath.Join(dir, "story.txt"), "")
+	if _, err := LoaderForPath(filepath.Join(dir, "story.txt")); err == nil {
+		t.Error("Expected error for unsupported extension")
+	}
+}
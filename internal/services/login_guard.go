@@ -0,0 +1,446 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"GopherTales/internal/models"
+)
+
+// LoginPolicy controls how failed logins for one key are throttled
+type LoginPolicy struct {
+	FreeAttempts    int           // Failures allowed before backoff starts
+	BaseDelay       time.Duration // Wait after the first throttled failure, doubled for each further one
+	MaxDelay        time.Duration // Longest backoff wait
+	LockoutAfter    int           // Failures that lock the key for LockoutDuration, 0 never locks
+	LockoutDuration time.Duration
+	Window          time.Duration // Failures are forgotten this long after the last one
+}
+
+// wait returns how long after its last failure a key is blocked, and
+// whether that block is a lockout rather than backoff
+func (p LoginPolicy) wait(failures int) (time.Duration, bool) {
+	if p.LockoutAfter > 0 && failures >= p.LockoutAfter {
+		return p.LockoutDuration, true
+	}
+	excess := failures - p.FreeAttempts
+	if excess < 0 {
+		return 0, false
+	}
+	if excess > 30 {
+		return p.MaxDelay, false
+	}
+	delay := p.BaseDelay << excess
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	return delay, false
+}
+
+// ttl is how long a counter must be kept to enforce the policy
+func (p LoginPolicy) ttl() time.Duration {
+	if p.LockoutDuration > p.Window {
+		return p.LockoutDuration
+	}
+	return p.Window
+}
+
+// LoginThrottledError is returned when a login is attempted too soon after
+// earlier failures
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool // The account is locked out, not just backing off
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("account temporarily locked after too many failed logins, try again in %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed logins, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginAttemptStore persists failed login counters and the audit trail
+type LoginAttemptStore interface {
+	// Get returns the counter for a key, or nil if it has no recent failures
+	Get(ctx context.Context, key string) (*models.LoginCounter, error)
+	// RecordFailure counts a failure and keeps the counter for ttl. Counters
+	// past their expiry start again from one.
+	RecordFailure(ctx context.Context, key string, now time.Time, ttl time.Duration) (*models.LoginCounter, error)
+	// Reserve counts a failure like RecordFailure, but only if the key still
+	// has exactly seen unexpired failures. It returns nil if another attempt
+	// changed the counter first.
+	Reserve(ctx context.Context, key string, seen int, now time.Time, ttl time.Duration) (*models.LoginCounter, error)
+	// Release takes back one failure counted by Reserve
+	Release(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+	Audit(ctx context.Context, attempt models.LoginAttempt) error
+}
+
+// loginAuditRetention is how long failed login records are kept
+const loginAuditRetention = 90 * 24 * time.Hour
+
+// maxMemoryAuditRecords bounds the in-memory audit trail
+const maxMemoryAuditRecords = 1000
+
+// MemoryLoginAttemptStore keeps login counters in process memory, for a
+// single replica
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	counters map[string]models.LoginCounter
+	audit    []models.LoginAttempt
+}
+
+// NewMemoryLoginAttemptStore creates an empty in-memory login attempt store
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		counters: make(map[string]models.LoginCounter),
+	}
+}
+
+// Get returns a copy of the counter for a key, or nil
+func (m *MemoryLoginAttemptStore) Get(ctx context.Context, key string) (*models.LoginCounter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counter, exists := m.counters[key]
+	if !exists {
+		return nil, nil
+	}
+	return &counter, nil
+}
+
+// RecordFailure counts a failure for a key
+func (m *MemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, ttl time.Duration) (*models.LoginCounter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counter, exists := m.counters[key]
+	if !exists || !now.Before(counter.ExpiresAt) {
+		counter = models.LoginCounter{Key: key}
+	}
+	counter.Failures++
+	counter.LastFailureAt = now
+	counter.ExpiresAt = now.Add(ttl)
+	m.counters[key] = counter
+
+	// Drop expired counters now and then so memory stays bounded
+	if len(m.counters)%100 == 0 {
+		for k, c := range m.counters {
+			if !now.Before(c.ExpiresAt) {
+				delete(m.counters, k)
+			}
+		}
+	}
+	return &counter, nil
+}
+
+// Reserve counts a failure for a key if it still has seen failures
+func (m *MemoryLoginAttemptStore) Reserve(ctx context.Context, key string, seen int, now time.Time, ttl time.Duration) (*models.LoginCounter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counter, exists := m.counters[key]
+	if !exists || !now.Before(counter.ExpiresAt) {
+		counter = models.LoginCounter{Key: key}
+	}
+	if counter.Failures != seen {
+		return nil, nil
+	}
+	counter.Failures++
+	counter.LastFailureAt = now
+	counter.ExpiresAt = now.Add(ttl)
+	m.counters[key] = counter
+	return &counter, nil
+}
+
+// Release takes back one failure for a key
+func (m *MemoryLoginAttemptStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if counter, exists := m.counters[key]; exists && counter.Failures > 0 {
+		counter.Failures--
+		m.counters[key] = counter
+	}
+	return nil
+}
+
+// Reset forgets the failures for a key
+func (m *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.counters, key)
+	return nil
+}
+
+// Audit appends to the audit trail, keeping the most recent records
+func (m *MemoryLoginAttemptStore) Audit(ctx context.Context, attempt models.LoginAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.audit = append(m.audit, attempt)
+	if len(m.audit) > maxMemoryAuditRecords {
+		m.audit = m.audit[len(m.audit)-maxMemoryAuditRecords:]
+	}
+	return nil
+}
+
+// userLookup finds accounts by email
+type userLookup interface {
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+}
+
+// LoginGuard throttles failed logins per IP address and per account. Each
+// key gets a few free attempts, then has to wait exponentially longer
+// between tries; accounts are locked for a while after too many failures
+// and their owner is emailed.
+type LoginGuard struct {
+	store         LoginAttemptStore
+	users         userLookup
+	mailer        Mailer
+	accountPolicy LoginPolicy
+	ipPolicy      LoginPolicy
+	now           func() time.Time
+}
+
+// NewLoginGuard creates a login guard with the default policies: accounts
+// back off after 3 failures and lock for 15 minutes after 10, IP addresses
+// back off after 10 failures and never lock
+func NewLoginGuard(store LoginAttemptStore, users userLookup, mailer Mailer) *LoginGuard {
+	return &LoginGuard{
+		store:  store,
+		users:  users,
+		mailer: mailer,
+		accountPolicy: LoginPolicy{
+			FreeAttempts:    3,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			LockoutAfter:    10,
+			LockoutDuration: 15 * time.Minute,
+			Window:          time.Hour,
+		},
+		ipPolicy: LoginPolicy{
+			FreeAttempts: 10,
+			BaseDelay:    time.Second,
+			MaxDelay:     5 * time.Minute,
+			Window:       time.Hour,
+		},
+		now: time.Now,
+	}
+}
+
+// SetLockout sets how many failures lock an account and for how long.
+// A threshold of 0 disables lockout.
+func (g *LoginGuard) SetLockout(after int, duration time.Duration) {
+	g.accountPolicy.LockoutAfter = after
+	g.accountPolicy.LockoutDuration = duration
+}
+
+// loginKey is a counter key and the policy that throttles it
+type loginKey struct {
+	key    string
+	policy LoginPolicy
+}
+
+func (g *LoginGuard) keys(ip, email string) []loginKey {
+	return []loginKey{
+		{"ip:" + ip, g.ipPolicy},
+		{"account:" + email, g.accountPolicy},
+	}
+}
+
+// throttle reads the counters for keys, returning how many unexpired
+// failures each has and the longest block among them, or nil
+func (g *LoginGuard) throttle(ctx context.Context, keys []loginKey, now time.Time) ([]int, *LoginThrottledError, error) {
+	seen := make([]int, len(keys))
+	var blocked *LoginThrottledError
+	for i, k := range keys {
+		counter, err := g.store.Get(ctx, k.key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if counter == nil || !now.Before(counter.ExpiresAt) {
+			continue
+		}
+		seen[i] = counter.Failures
+
+		wait, locked := k.policy.wait(counter.Failures)
+		retryAfter := counter.LastFailureAt.Add(wait).Sub(now)
+		if retryAfter > 0 && (blocked == nil || retryAfter > blocked.RetryAfter) {
+			blocked = &LoginThrottledError{RetryAfter: retryAfter, Locked: locked}
+		}
+	}
+	return seen, blocked, nil
+}
+
+// auditBlocked records a blocked attempt and returns its error
+func (g *LoginGuard) auditBlocked(ctx context.Context, ip, email string, blocked *LoginThrottledError, now time.Time) error {
+	reason := "throttled"
+	if blocked.Locked {
+		reason = "locked"
+	}
+	g.audit(ctx, ip, email, reason, now)
+	return blocked
+}
+
+// Check returns a *LoginThrottledError if the IP address or account must
+// wait before trying again. Blocked attempts are audited.
+func (g *LoginGuard) Check(ctx context.Context, ip, email string) error {
+	now := g.now()
+	email = NormalizeEmail(email)
+
+	_, blocked, err := g.throttle(ctx, g.keys(ip, email), now)
+	if err != nil {
+		return err
+	}
+	if blocked != nil {
+		return g.auditBlocked(ctx, ip, email, blocked, now)
+	}
+	return nil
+}
+
+// LoginReservation is a login attempt counted by Reserve before the
+// password was checked
+type LoginReservation struct {
+	guard    *LoginGuard
+	ip       string
+	email    string
+	failures int // The account's failures including this attempt
+	now      time.Time
+}
+
+// Reserve checks the IP address and account like Check and, if neither is
+// blocked, counts the attempt as a failure before the password is checked.
+// Each counter only moves on from the value that was checked, so concurrent
+// guesses cannot all pass the same check. Call Failed or Succeeded on the
+// result once the password has been checked.
+func (g *LoginGuard) Reserve(ctx context.Context, ip, email string) (*LoginReservation, error) {
+	now := g.now()
+	email = NormalizeEmail(email)
+	keys := g.keys(ip, email)
+
+	for {
+		seen, blocked, err := g.throttle(ctx, keys, now)
+		if err != nil {
+			return nil, err
+		}
+		if blocked != nil {
+			return nil, g.auditBlocked(ctx, ip, email, blocked, now)
+		}
+
+		ipCounter, err := g.store.Reserve(ctx, keys[0].key, seen[0], now, keys[0].policy.ttl())
+		if err != nil {
+			return nil, err
+		}
+		if ipCounter == nil {
+			continue // Another attempt got there first, check again
+		}
+		accountCounter, err := g.store.Reserve(ctx, keys[1].key, seen[1], now, keys[1].policy.ttl())
+		if err == nil && accountCounter == nil {
+			err = g.store.Release(ctx, keys[0].key)
+			if err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &LoginReservation{guard: g, ip: ip, email: email, failures: accountCounter.Failures, now: now}, nil
+	}
+}
+
+// Failed audits a wrong password, emailing the account owner when it
+// became locked. The failure was already counted by Reserve.
+func (r *LoginReservation) Failed(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	g := r.guard
+	g.audit(ctx, r.ip, r.email, "invalid_credentials", r.now)
+
+	if g.accountPolicy.LockoutAfter > 0 && r.failures == g.accountPolicy.LockoutAfter {
+		g.notifyLockout(ctx, r.email, r.now.Add(g.accountPolicy.LockoutDuration))
+	}
+}
+
+// Release takes back the attempt from the IP address and account when the
+// password could not be checked, such as on a database error, so outages
+// are not counted as failed logins
+func (r *LoginReservation) Release(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+	if err := r.guard.store.Release(ctx, "ip:"+r.ip); err != nil {
+		return err
+	}
+	return r.guard.store.Release(ctx, "account:"+r.email)
+}
+
+// Succeeded gives the IP address back its attempt once the password was
+// right. The account's attempt stays counted until RecordSuccess, so
+// knowing the password does not reset guessing at two-factor codes.
+func (r *LoginReservation) Succeeded(ctx context.Context) error {
+	return r.guard.store.Release(context.WithoutCancel(ctx), "ip:"+r.ip)
+}
+
+// RecordFailure counts a failed login that was not reserved, such as a
+// wrong two-factor code, against the IP address and account, emailing the
+// account owner when it becomes locked. The count is kept even if the
+// client hangs up, so dropping the connection does not dodge it.
+func (g *LoginGuard) RecordFailure(ctx context.Context, ip, email string) error {
+	ctx = context.WithoutCancel(ctx)
+	now := g.now()
+	email = NormalizeEmail(email)
+	g.audit(ctx, ip, email, "invalid_credentials", now)
+
+	if _, err := g.store.RecordFailure(ctx, "ip:"+ip, now, g.ipPolicy.ttl()); err != nil {
+		return err
+	}
+	counter, err := g.store.RecordFailure(ctx, "account:"+email, now, g.accountPolicy.ttl())
+	if err != nil {
+		return err
+	}
+
+	if g.accountPolicy.LockoutAfter > 0 && counter.Failures == g.accountPolicy.LockoutAfter {
+		g.notifyLockout(ctx, email, now.Add(g.accountPolicy.LockoutDuration))
+	}
+	return nil
+}
+
+// RecordSuccess clears the account's failures. The IP address keeps its
+// count, so logging into one account does not reset guessing at others.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email string) error {
+	return g.store.Reset(ctx, "account:"+NormalizeEmail(email))
+}
+
+func (g *LoginGuard) audit(ctx context.Context, ip, email, reason string, now time.Time) {
+	attempt := models.LoginAttempt{
+		Email:     email,
+		IP:        ip,
+		Reason:    reason,
+		Timestamp: now,
+	}
+	if err := g.store.Audit(ctx, attempt); err != nil {
+		log.Printf("Error auditing login attempt: %v", err)
+	}
+}
+
+// notifyLockout emails the owner of a locked account, if it exists
+func (g *LoginGuard) notifyLockout(ctx context.Context, email string, until time.Time) {
+	user, err := g.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+
+	err = g.mailer.Send(Email{
+		To:      user.Email,
+		Subject: "Your GopherTales account was temporarily locked",
+		Body: fmt.Sprintf("Hi %s,\n\nThere were too many failed attempts to log into your GopherTales account, "+
+			"so logins are paused until %s.\n\nIf this wasn't you, consider resetting your password once the lock ends.\n",
+			user.Name, until.UTC().Format("15:04 MST on Jan 2")),
+	})
+	if err != nil {
+		log.Printf("Error sending lockout notice: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// MongoLoginAttemptStore keeps login counters in "login_counters" and the
+// audit trail in "login_attempts", so every replica sees the same counts
+type MongoLoginAttemptStore struct {
+	db       *database.MongoDB
+	counters *mongo.Collection
+	attempts *mongo.Collection
+}
+
+// NewMongoLoginAttemptStore creates a login attempt store on a migrated
+// database
+func NewMongoLoginAttemptStore(db *database.MongoDB) *MongoLoginAttemptStore {
+	return &MongoLoginAttemptStore{
+		db:       db,
+		counters: db.Database.Collection("login_counters"),
+		attempts: db.Database.Collection("login_attempts"),
+	}
+}
+
+// Get returns the counter for a key, or nil
+func (s *MongoLoginAttemptStore) Get(ctx context.Context, key string) (*models.LoginCounter, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	var counter models.LoginCounter
+	err := s.counters.FindOne(ctx, bson.M{"_id": key}).Decode(&counter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &counter, nil
+}
+
+// RecordFailure atomically counts a failure for a key, starting again from
+// one if the stored counter has expired
+func (s *MongoLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, ttl time.Duration) (*models.LoginCounter, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"failures": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$expires_at", now}},
+			bson.M{"$add": bson.A{"$failures", 1}},
+			1,
+		}},
+		"last_failure_at": now,
+		"expires_at":      now.Add(ttl),
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter models.LoginCounter
+	err := s.counters.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&counter)
+	if err != nil {
+		return nil, err
+	}
+	return &counter, nil
+}
+
+// Reserve counts a failure for a key if it still has seen failures. A
+// fresh counter is upserted, so a concurrent insert fails on the key.
+func (s *MongoLoginAttemptStore) Reserve(ctx context.Context, key string, seen int, now time.Time, ttl time.Duration) (*models.LoginCounter, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	filter := bson.M{"_id": key, "failures": seen, "expires_at": bson.M{"$gt": now}}
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"last_failure_at": now, "expires_at": now.Add(ttl)},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if seen == 0 {
+		filter = bson.M{"_id": key, "$or": bson.A{
+			bson.M{"failures": 0},
+			bson.M{"expires_at": bson.M{"$lte": now}},
+		}}
+		update = bson.M{"$set": bson.M{"failures": 1, "last_failure_at": now, "expires_at": now.Add(ttl)}}
+		opts.SetUpsert(true)
+	}
+
+	var counter models.LoginCounter
+	err := s.counters.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	if errors.Is(err, mongo.ErrNoDocuments) || mongo.IsDuplicateKeyError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &counter, nil
+}
+
+// Release takes back one failure for a key
+func (s *MongoLoginAttemptStore) Release(ctx context.Context, key string) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.counters.UpdateOne(ctx, bson.M{"_id": key, "failures": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"failures": -1}})
+	return err
+}
+
+// Reset forgets the failures for a key
+func (s *MongoLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.counters.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+// Audit inserts an audit record
+func (s *MongoLoginAttemptStore) Audit(ctx context.Context, attempt models.LoginAttempt) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.attempts.InsertOne(ctx, attempt)
+	return err
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// SQLiteLoginAttemptStore keeps login counters in "login_counters" and the
+// audit trail in "login_attempts"
+type SQLiteLoginAttemptStore struct {
+	db *database.SQLite
+}
+
+// NewSQLiteLoginAttemptStore creates a login attempt store on a migrated
+// database
+func NewSQLiteLoginAttemptStore(db *database.SQLite) *SQLiteLoginAttemptStore {
+	return &SQLiteLoginAttemptStore{db: db}
+}
+
+// Get returns the counter for a key, or nil
+func (s *SQLiteLoginAttemptStore) Get(ctx context.Context, key string) (*models.LoginCounter, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	counter := models.LoginCounter{Key: key}
+	var lastFailure, expiresAt int64
+	err := s.db.DB.QueryRowContext(ctx, `SELECT failures, last_failure_at, expires_at FROM login_counters WHERE key = ?`, key).
+		Scan(&counter.Failures, &lastFailure, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	counter.LastFailureAt = fromSQLiteTime(lastFailure)
+	counter.ExpiresAt = fromSQLiteTime(expiresAt)
+	return &counter, nil
+}
+
+// RecordFailure atomically counts a failure for a key, starting again from
+// one if the stored counter has expired
+func (s *SQLiteLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, ttl time.Duration) (*models.LoginCounter, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.DB.ExecContext(ctx, `DELETE FROM login_counters WHERE expires_at <= ? AND key <> ?`, toSQLiteTime(now), key); err != nil {
+		return nil, err
+	}
+
+	counter := models.LoginCounter{Key: key}
+	var lastFailure, expiresAt int64
+	err := s.db.DB.QueryRowContext(ctx, `INSERT INTO login_counters (key, failures, last_failure_at, expires_at) VALUES (?, 1, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN expires_at > excluded.last_failure_at THEN failures + 1 ELSE 1 END,
+			last_failure_at = excluded.last_failure_at,
+			expires_at = excluded.expires_at
+		RETURNING failures, last_failure_at, expires_at`,
+		key, toSQLiteTime(now), toSQLiteTime(now.Add(ttl))).Scan(&counter.Failures, &lastFailure, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	counter.LastFailureAt = fromSQLiteTime(lastFailure)
+	counter.ExpiresAt = fromSQLiteTime(expiresAt)
+	return &counter, nil
+}
+
+// Reserve counts a failure for a key if it still has seen failures
+func (s *SQLiteLoginAttemptStore) Reserve(ctx context.Context, key string, seen int, now time.Time, ttl time.Duration) (*models.LoginCounter, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE login_counters SET failures = failures + 1, last_failure_at = ?, expires_at = ?
+		WHERE key = ? AND failures = ? AND expires_at > ?
+		RETURNING failures, last_failure_at, expires_at`
+	args := []interface{}{toSQLiteTime(now), toSQLiteTime(now.Add(ttl)), key, seen, toSQLiteTime(now)}
+	if seen == 0 {
+		query = `INSERT INTO login_counters (key, failures, last_failure_at, expires_at) VALUES (?, 1, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET
+				failures = 1,
+				last_failure_at = excluded.last_failure_at,
+				expires_at = excluded.expires_at
+			WHERE login_counters.failures = 0 OR login_counters.expires_at <= excluded.last_failure_at
+			RETURNING failures, last_failure_at, expires_at`
+		args = []interface{}{key, toSQLiteTime(now), toSQLiteTime(now.Add(ttl))}
+	}
+
+	counter := models.LoginCounter{Key: key}
+	var lastFailure, expiresAt int64
+	err := s.db.DB.QueryRowContext(ctx, query, args...).Scan(&counter.Failures, &lastFailure, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	counter.LastFailureAt = fromSQLiteTime(lastFailure)
+	counter.ExpiresAt = fromSQLiteTime(expiresAt)
+	return &counter, nil
+}
+
+// Release takes back one failure for a key
+func (s *SQLiteLoginAttemptStore) Release(ctx context.Context, key string) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.DB.ExecContext(ctx, `UPDATE login_counters SET failures = failures - 1 WHERE key = ? AND failures > 0`, key)
+	return err
+}
+
+// Reset forgets the failures for a key
+func (s *SQLiteLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.DB.ExecContext(ctx, `DELETE FROM login_counters WHERE key = ?`, key)
+	return err
+}
+
+// Audit inserts an audit record, dropping records past loginAuditRetention
+func (s *SQLiteLoginAttemptStore) Audit(ctx context.Context, attempt models.LoginAttempt) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	cutoff := attempt.Timestamp.Add(-loginAuditRetention)
+	if _, err := s.db.DB.ExecContext(ctx, `DELETE FROM login_attempts WHERE timestamp < ?`, toSQLiteTime(cutoff)); err != nil {
+		return err
+	}
+
+	_, err := s.db.DB.ExecContext(ctx, `INSERT INTO login_attempts (email, ip, reason, timestamp) VALUES (?, ?, ?, ?)`,
+		attempt.Email, attempt.IP, attempt.Reason, toSQLiteTime(attempt.Timestamp))
+	return err
+}
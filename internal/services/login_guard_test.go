@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"GopherTales/internal/models"
+)
+
+// fakeUsers finds accounts in a map keyed by email
+type fakeUsers map[string]*models.User
+
+func (f fakeUsers) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	if user, ok := f[email]; ok {
+		return user, nil
+	}
+	return nil, errors.New("not found")
+}
+
+// recordingMailer keeps sent emails in memory
+type recordingMailer struct {
+	sent []Email
+}
+
+func (m *recordingMailer) Send(email Email) error {
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+func newTestLoginGuard(now *time.Time) (*LoginGuard, *MemoryLoginAttemptStore, *recordingMailer) {
+	store := NewMemoryLoginAttemptStore()
+	mailer := &recordingMailer{}
+	users := fakeUsers{"ada@example.com": {Name: "Ada", Email: "ada@example.com"}}
+
+	guard := NewLoginGuard(store, users, mailer)
+	guard.now = func() time.Time { return *now }
+	return guard, store, mailer
+}
+
+func throttled(t *testing.T, err error) *LoginThrottledError {
+	t.Helper()
+
+	var throttledErr *LoginThrottledError
+	if !errors.As(err, &throttledErr) {
+		t.Fatalf("Expected a LoginThrottledError, got %v", err)
+	}
+	return throttledErr
+}
+
+func TestLoginGuard_Backoff(t *testing.T) {
+	now := time.Now()
+	guard, _, _ := newTestLoginGuard(&now)
+
+	// Three free attempts
+	for i := 0; i < 3; i++ {
+		if err := guard.Check(context.Background(), "10.0.0.1", "ada@example.com"); err != nil {
+			t.Fatalf("Expected attempt %d to be allowed: %v", i+1, err)
+		}
+		guard.RecordFailure(context.Background(), "10.0.0.1", "ada@example.com")
+	}
+
+	// Then the wait doubles with every failure
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		err := throttled(t, guard.Check(context.Background(), "10.0.0.1", "ADA@example.com "))
+		if err.RetryAfter != want || err.Locked {
+			t.Errorf("Expected to back off for %v, got %v (locked %t)", want, err.RetryAfter, err.Locked)
+		}
+
+		now = now.Add(want)
+		if err := guard.Check(context.Background(), "10.0.0.1", "ada@example.com"); err != nil {
+			t.Fatalf("Expected an attempt after %v to be allowed: %v", want, err)
+		}
+		guard.RecordFailure(context.Background(), "10.0.0.1", "ada@example.com")
+	}
+}
+
+func TestLoginGuard_LockoutAndNotice(t *testing.T) {
+	now := time.Now()
+	guard, store, mailer := newTestLoginGuard(&now)
+	guard.SetLockout(5, 15*time.Minute)
+
+	for i := 0; i < 5; i++ {
+		guard.RecordFailure(context.Background(), "10.0.0.1", "ada@example.com")
+	}
+
+	err := throttled(t, guard.Check(context.Background(), "10.0.0.2", "ada@example.com"))
+	if !err.Locked || err.RetryAfter != 15*time.Minute {
+		t.Errorf("Expected a 15 minute lockout from any IP, got %v (locked %t)", err.RetryAfter, err.Locked)
+	}
+
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "ada@example.com" {
+		t.Fatalf("Expected one lockout notice to ada@example.com, got %+v", mailer.sent)
+	}
+
+	// Further failures do not send more notices
+	guard.RecordFailure(context.Background(), "10.0.0.1", "ada@example.com")
+	if len(mailer.sent) != 1 {
+		t.Errorf("Expected a single lockout notice, got %d", len(mailer.sent))
+	}
+
+	// Unknown accounts lock too but nobody is emailed
+	for i := 0; i < 5; i++ {
+		guard.RecordFailure(context.Background(), "10.0.0.3", "nobody@example.com")
+	}
+	if len(mailer.sent) != 1 {
+		t.Errorf("Expected no notice for unknown accounts, got %d emails", len(mailer.sent))
+	}
+
+	if len(store.audit) != 12 {
+		t.Errorf("Expected 12 audit records (11 failures and 1 lockout), got %d", len(store.audit))
+	}
+
+	now = now.Add(15 * time.Minute)
+	if err := guard.Check(context.Background(), "10.0.0.2", "ada@example.com"); err != nil {
+		t.Errorf("Expected the lockout to end: %v", err)
+	}
+}
+
+func TestLoginGuard_SuccessResetsAccountOnly(t *testing.T) {
+	now := time.Now()
+	guard, _, _ := newTestLoginGuard(&now)
+
+	// One IP guessing at many accounts is throttled by its IP counter
+	for i := 0; i < 10; i++ {
+		guard.RecordFailure(context.Background(), "10.0.0.1", "ada@example.com")
+		guard.RecordSuccess(context.Background(), "ada@example.com")
+	}
+
+	if err := guard.Check(context.Background(), "10.0.0.9", "ada@example.com"); err != nil {
+		t.Errorf("Expected the account to be cleared by a successful login: %v", err)
+	}
+	throttled(t, guard.Check(context.Background(), "10.0.0.1", "someone@example.com"))
+}
+
+func TestLoginGuard_FailuresExpire(t *testing.T) {
+	now := time.Now()
+	guard, _, _ := newTestLoginGuard(&now)
+
+	for i := 0; i < 4; i++ {
+		guard.RecordFailure(context.Background(), "10.0.0.1", "ada@example.com")
+	}
+	throttled(t, guard.Check(context.Background(), "10.0.0.1", "ada@example.com"))
+
+	now = now.Add(time.Hour)
+	guard.RecordFailure(context.Background(), "10.0.0.1", "ada@example.com")
+	if err := guard.Check(context.Background(), "10.0.0.1", "ada@example.com"); err != nil {
+		t.Errorf("Expected failures older than the window to be forgotten: %v", err)
+	}
+}
+
+func TestLoginGuard_ReserveConcurrent(t *testing.T) {
+	now := time.Now()
+	guard, _, _ := newTestLoginGuard(&now)
+
+	// Guesses racing past the check still only get the free attempts
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var reserved []*LoginReservation
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempt, err := guard.Reserve(context.Background(), "10.0.0.1", "ada@example.com")
+			if err == nil {
+				mu.Lock()
+				reserved = append(reserved, attempt)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(reserved) != 3 {
+		t.Fatalf("Expected 3 attempts to be reserved, got %d", len(reserved))
+	}
+
+	// A right password gives the IP address its attempt back, but the
+	// account keeps it until the login completes
+	if err := reserved[0].Succeeded(context.Background()); err != nil {
+		t.Fatalf("Failed to release attempt: %v", err)
+	}
+	throttled(t, guard.Check(context.Background(), "10.0.0.2", "ada@example.com"))
+	guard.RecordSuccess(context.Background(), "ada@example.com")
+	if err := guard.Check(context.Background(), "10.0.0.1", "someone@example.com"); err != nil {
+		t.Errorf("Expected the IP address to have attempts left: %v", err)
+	}
+}
+
+func TestLoginGuard_ReservedFailureLocks(t *testing.T) {
+	now := time.Now()
+	guard, store, mailer := newTestLoginGuard(&now)
+	guard.SetLockout(2, 15*time.Minute)
+
+	for i := 0; i < 2; i++ {
+		attempt, err := guard.Reserve(context.Background(), "10.0.0.1", "ada@example.com")
+		if err != nil {
+			t.Fatalf("Expected attempt %d to be allowed: %v", i+1, err)
+		}
+		attempt.Failed(context.Background())
+	}
+
+	if _, err := guard.Reserve(context.Background(), "10.0.0.1", "ada@example.com"); !throttled(t, err).Locked {
+		t.Errorf("Expected the account to be locked")
+	}
+	if len(mailer.sent) != 1 {
+		t.Errorf("Expected one lockout notice, got %d", len(mailer.sent))
+	}
+	if len(store.audit) != 3 {
+		t.Errorf("Expected 3 audit records (2 failures and 1 lockout), got %d", len(store.audit))
+	}
+}
+
+func TestLoginGuard_ReleaseUncheckedAttempt(t *testing.T) {
+	now := time.Now()
+	guard, _, _ := newTestLoginGuard(&now)
+
+	// Attempts whose password could not be checked are not failures
+	for i := 0; i < 5; i++ {
+		attempt, err := guard.Reserve(context.Background(), "10.0.0.1", "ada@example.com")
+		if err != nil {
+			t.Fatalf("Expected attempt %d to be allowed: %v", i+1, err)
+		}
+		if err := attempt.Release(context.Background()); err != nil {
+			t.Fatalf("Failed to release attempt: %v", err)
+		}
+	}
+	if err := guard.Check(context.Background(), "10.0.0.1", "ada@example.com"); err != nil {
+		t.Errorf("Expected released attempts not to count: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer_Send(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	mailer := NewFileMailer(dir, "GopherTales <no-reply@example.com>")
+
+	err := mailer.Send(Email{
+		To:      "reader@example.com\r\nBcc: victim@example.com",
+		Subject: "Hello",
+		Body:    "Line one\nLine two",
+	})
+	if err != nil {
+		t.Fatalf("Failed to send email: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one email in the outbox, got %d (%v)", len(files), err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatalf("Failed to read email: %v", err)
+	}
+	message := string(data)
+
+	for _, want := range []string{"From: GopherTales <no-reply@example.com>\r\n", "Subject: Hello\r\n", "Line one\r\nLine two"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Expected email to contain %q, got:\n%s", want, message)
+		}
+	}
+	if strings.Contains(message, "\r\nBcc:") {
+		t.Errorf("Expected line breaks in headers to be stripped, got:\n%s", message)
+	}
+}
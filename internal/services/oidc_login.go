@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+// oidcLoginTTL is how long a user has to finish signing in at the provider
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	// ErrOIDCLoginState is returned when the callback does not match a
+	// sign-in started in this browser, or came too late
+	ErrOIDCLoginState = errors.New("sign-in expired or was started in another browser, please try again")
+	// ErrOIDCEmailNotVerified is returned when the provider does not vouch
+	// for the email of an account we have not seen before
+	ErrOIDCEmailNotVerified = errors.New("your identity provider has not confirmed your email address")
+	// ErrOIDCAccountUnverified is returned instead of linking to a password
+	// account whose email was never verified, as whoever registered it may
+	// not own the address
+	ErrOIDCAccountUnverified = errors.New("an account with this email exists but its email is not verified, log in with your password and verify it first")
+)
+
+// oidcUsers finds, links and creates accounts for external identities
+type oidcUsers interface {
+	GetUserByIdentity(ctx context.Context, issuer, subject string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	LinkIdentity(ctx context.Context, userID primitive.ObjectID, identity models.ExternalIdentity) error
+	CreateExternalUser(ctx context.Context, name, email string, identity models.ExternalIdentity) (*models.User, error)
+}
+
+// oidcLoginFlow is the state of one sign-in, kept in a signed cookie
+// between the redirect to the provider and the callback
+type oidcLoginFlow struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"` // PKCE code verifier
+	Expires  int64  `json:"e"`
+}
+
+// OIDCLoginService signs users in with an OpenID Connect provider, linking
+// the identity to the account with the same verified email or creating one
+type OIDCLoginService struct {
+	provider *OIDCProvider
+	users    oidcUsers
+	secret   []byte
+	now      func() time.Time
+}
+
+// NewOIDCLoginService creates a sign-in service. The secret signs the flow
+// cookie and may be the session secret.
+func NewOIDCLoginService(provider *OIDCProvider, users oidcUsers, secret []byte) *OIDCLoginService {
+	return &OIDCLoginService{
+		provider: provider,
+		users:    users,
+		secret:   secret,
+		now:      time.Now,
+	}
+}
+
+// Begin starts a sign-in. It returns the provider URL to redirect to and a
+// value for a cookie that must come back with the callback.
+func (s *OIDCLoginService) Begin(ctx context.Context) (authURL, flowCookie string, err error) {
+	var flow oidcLoginFlow
+	for _, field := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		if *field, err = newRandomToken(); err != nil {
+			return "", "", err
+		}
+	}
+	flow.Expires = s.now().Add(oidcLoginTTL).Unix()
+
+	authURL, err = s.provider.AuthCodeURL(ctx, flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	payload, err := json.Marshal(flow)
+	if err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return authURL, encoded + "." + s.sign(encoded), nil
+}
+
+// Finish completes a sign-in from the provider's callback and returns the
+// signed-in user
+func (s *OIDCLoginService) Finish(ctx context.Context, flowCookie, state, code string) (*models.User, error) {
+	flow, err := s.readFlow(flowCookie)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(flow.State)) != 1 {
+		return nil, ErrOIDCLoginState
+	}
+
+	claims, err := s.provider.Exchange(ctx, code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolveUser(ctx, claims)
+}
+
+// readFlow checks the flow cookie's signature and expiry
+func (s *OIDCLoginService) readFlow(value string) (*oidcLoginFlow, error) {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return nil, ErrOIDCLoginState
+	}
+
+	var flow oidcLoginFlow
+	if err := decodeJWTPart(encoded, &flow); err != nil {
+		return nil, ErrOIDCLoginState
+	}
+	if !s.now().Before(time.Unix(flow.Expires, 0)) {
+		return nil, ErrOIDCLoginState
+	}
+	return &flow, nil
+}
+
+// sign returns the base64url HMAC-SHA256 of a flow cookie payload
+func (s *OIDCLoginService) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("oidc-flow:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// resolveUser returns the account linked to the identity, linking it to the
+// account with the same email or creating an account on first sign-in
+func (s *OIDCLoginService) resolveUser(ctx context.Context, claims *IDTokenClaims) (*models.User, error) {
+	user, err := s.users.GetUserByIdentity(ctx, claims.Issuer, claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+
+	email := NormalizeEmail(claims.Email)
+	var errs fieldErrors
+	validateEmail(&errs, email)
+	if !claims.EmailVerified || len(errs) > 0 {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	identity := models.ExternalIdentity{
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		LinkedAt: s.now(),
+	}
+
+	user, err = s.users.GetUserByEmail(ctx, email)
+	switch {
+	case err == nil:
+		if !user.EmailVerified {
+			return nil, ErrOIDCAccountUnverified
+		}
+		if err := s.users.LinkIdentity(ctx, user.ID, identity); err != nil {
+			return nil, err
+		}
+		user.Identities = append(user.Identities, identity)
+		return user, nil
+	case errors.Is(err, ErrUserNotFound):
+		return s.users.CreateExternalUser(ctx, externalDisplayName(claims.Name, email), email, identity)
+	default:
+		return nil, err
+	}
+}
+
+// externalDisplayName normalizes the provider's name for the user, falling
+// back to the local part of their email when it is missing or unusable
+func externalDisplayName(name, email string) string {
+	name = NormalizeName(name)
+	if length := utf8.RuneCountInString(name); length >= minNameLength && length <= maxNameLength {
+		return name
+	}
+
+	local, _, _ := strings.Cut(email, "@")
+	if utf8.RuneCountInString(local) > maxNameLength {
+		local = string([]rune(local)[:maxNameLength])
+	}
+	if utf8.RuneCountInString(local) < minNameLength {
+		return "Reader"
+	}
+	return local
+}
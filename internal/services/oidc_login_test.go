@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+// fakeOIDCUsers keeps accounts in memory, keyed by email. Lookups return
+// copies, as a database would.
+type fakeOIDCUsers struct {
+	users map[string]*models.User
+}
+
+func (f *fakeOIDCUsers) GetUserByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	for _, user := range f.users {
+		for _, identity := range user.Identities {
+			if identity.Issuer == issuer && identity.Subject == subject {
+				found := *user
+				return &found, nil
+			}
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (f *fakeOIDCUsers) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	if user, exists := f.users[email]; exists {
+		found := *user
+		return &found, nil
+	}
+	return nil, ErrUserNotFound
+}
+
+func (f *fakeOIDCUsers) LinkIdentity(ctx context.Context, userID primitive.ObjectID, identity models.ExternalIdentity) error {
+	for _, user := range f.users {
+		if user.ID == userID {
+			user.Identities = append(user.Identities, identity)
+		}
+	}
+	return nil
+}
+
+func (f *fakeOIDCUsers) CreateExternalUser(ctx context.Context, name, email string, identity models.ExternalIdentity) (*models.User, error) {
+	user := &models.User{
+		ID:            primitive.NewObjectID(),
+		Name:          name,
+		Email:         email,
+		EmailVerified: true,
+		Identities:    []models.ExternalIdentity{identity},
+	}
+	f.users[email] = user
+	return user, nil
+}
+
+// signIn runs a full sign-in against the provider and returns its result
+func signIn(t *testing.T, idp *testIdP, service *OIDCLoginService) (*models.User, error) {
+	t.Helper()
+
+	authURL, flow, err := service.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin sign-in: %v", err)
+	}
+	code, state := idp.authorize(t, authURL)
+	return service.Finish(context.Background(), flow, state, code)
+}
+
+func TestOIDCLoginService_CreatesAndReusesAccount(t *testing.T) {
+	idp := newTestIdP(t)
+	users := &fakeOIDCUsers{users: make(map[string]*models.User)}
+	service := NewOIDCLoginService(idp.provider(), users, []byte("secret"))
+
+	user, err := signIn(t, idp, service)
+	if err != nil {
+		t.Fatalf("Sign-in failed: %v", err)
+	}
+	if user.Email != "ada@example.com" || user.Name != "Ada Lovelace" || !user.EmailVerified {
+		t.Errorf("Unexpected new user %+v", user)
+	}
+
+	// The provider's email changing does not create a second account
+	idp.claims["email"] = "ada@new.example.com"
+	again, err := signIn(t, idp, service)
+	if err != nil {
+		t.Fatalf("Second sign-in failed: %v", err)
+	}
+	if again.ID != user.ID || len(users.users) != 1 {
+		t.Errorf("Expected the same account, got %+v", again)
+	}
+}
+
+func TestOIDCLoginService_LinksByVerifiedEmail(t *testing.T) {
+	idp := newTestIdP(t)
+
+	tests := []struct {
+		name          string
+		existing      *models.User
+		emailVerified interface{}
+		want          error
+	}{
+		{
+			name:          "links verified account",
+			existing:      &models.User{ID: primitive.NewObjectID(), Email: "ada@example.com", EmailVerified: true},
+			emailVerified: true,
+		},
+		{
+			name:          "refuses unverified account",
+			existing:      &models.User{ID: primitive.NewObjectID(), Email: "ada@example.com"},
+			emailVerified: true,
+			want:          ErrOIDCAccountUnverified,
+		},
+		{
+			name:          "refuses email the provider has not verified",
+			existing:      &models.User{ID: primitive.NewObjectID(), Email: "ada@example.com", EmailVerified: true},
+			emailVerified: false,
+			want:          ErrOIDCEmailNotVerified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeOIDCUsers{users: map[string]*models.User{tt.existing.Email: tt.existing}}
+			service := NewOIDCLoginService(idp.provider(), users, []byte("secret"))
+			idp.claims["email_verified"] = tt.emailVerified
+
+			user, err := signIn(t, idp, service)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, err)
+			}
+			if tt.want != nil {
+				if len(tt.existing.Identities) != 0 {
+					t.Errorf("Expected no identity to be linked")
+				}
+				return
+			}
+			if user.ID != tt.existing.ID || len(tt.existing.Identities) != 1 || tt.existing.Identities[0].Subject != "user-123" {
+				t.Errorf("Expected identity linked to the existing account, got %+v", user)
+			}
+		})
+	}
+}
+
+func TestOIDCLoginService_RejectsBadFlow(t *testing.T) {
+	idp := newTestIdP(t)
+	users := &fakeOIDCUsers{users: make(map[string]*models.User)}
+	now := time.Now()
+	service := NewOIDCLoginService(idp.provider(), users, []byte("secret"))
+	service.now = func() time.Time { return now }
+
+	authURL, flow, err := service.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin sign-in: %v", err)
+	}
+	code, state := idp.authorize(t, authURL)
+
+	other := NewOIDCLoginService(idp.provider(), users, []byte("other secret"))
+	if _, err := other.Finish(context.Background(), flow, state, code); !errors.Is(err, ErrOIDCLoginState) {
+		t.Errorf("Expected cookie signed with another secret to fail, got %v", err)
+	}
+	if _, err := service.Finish(context.Background(), flow+"x", state, code); !errors.Is(err, ErrOIDCLoginState) {
+		t.Errorf("Expected tampered cookie to fail, got %v", err)
+	}
+	if _, err := service.Finish(context.Background(), flow, "other-state", code); !errors.Is(err, ErrOIDCLoginState) {
+		t.Errorf("Expected mismatched state to fail, got %v", err)
+	}
+
+	now = now.Add(oidcLoginTTL)
+	if _, err := service.Finish(context.Background(), flow, state, code); !errors.Is(err, ErrOIDCLoginState) {
+		t.Errorf("Expected expired flow to fail, got %v", err)
+	}
+}
+
+func TestExternalDisplayName(t *testing.T) {
+	tests := []struct {
+		name, email, want string
+	}{
+		{"  Ada   Lovelace ", "ada@example.com", "Ada Lovelace"},
+		{"", "grace.hopper@example.com", "grace.hopper"},
+		{"A", "alan@example.com", "alan"},
+		{"", "x@example.com", "Reader"},
+	}
+
+	for _, tt := range tests {
+		if got := externalDisplayName(tt.name, tt.email); got != tt.want {
+			t.Errorf("externalDisplayName(%q, %q): expected %q, got %q", tt.name, tt.email, tt.want, got)
+		}
+	}
+}
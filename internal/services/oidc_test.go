@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID     = "gophertales"
+	testClientSecret = "s3cret"
+	testRedirectURL  = "http://localhost:8000/auth/oidc/callback"
+)
+
+// testIdP is a minimal OpenID Connect provider: discovery, a JWK set with an
+// RSA and an EC key, and a token endpoint that checks PKCE
+type testIdP struct {
+	server *httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]testGrant // Authorization codes
+	claims map[string]interface{}
+	issuer string // Issuer in the discovery document, defaults to the server URL
+}
+
+// testGrant is what the provider remembers about an authorization code
+type testGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+
+	idp := &testIdP{
+		rsaKey: rsaKey,
+		ecKey:  ecKey,
+		grants: make(map[string]testGrant),
+		claims: map[string]interface{}{
+			"sub":            "user-123",
+			"email":          "Ada@Example.com",
+			"email_verified": true,
+			"name":           "Ada Lovelace",
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := idp.issuer
+		if issuer == "" {
+			issuer = idp.server.URL
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA", "kid": "rsa-1", "use": "sig",
+					"n": b64(rsaKey.N.Bytes()),
+					"e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+				},
+				{
+					"kty": "EC", "kid": "ec-1", "use": "sig", "crv": "P-256",
+					"x": b64(ecKey.X.FillBytes(make([]byte, 32))),
+					"y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+				},
+			},
+		})
+	})
+	mux.HandleFunc("/token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// authorize plays the user approving the sign-in at the provider and
+// returns the authorization code it would redirect back with
+func (idp *testIdP) authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Failed to parse auth URL: %v", err)
+	}
+	q := parsed.Query()
+	if q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURL || q.Get("response_type") != "code" {
+		t.Fatalf("Unexpected authorization request %s", authURL)
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("Expected a PKCE S256 challenge, got %s", authURL)
+	}
+
+	code, _ = newRandomToken()
+	idp.mu.Lock()
+	idp.grants[code] = testGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	idp.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (idp *testIdP) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != testClientID || secret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	idp.mu.Lock()
+	grant, exists := idp.grants[r.PostFormValue("code")]
+	delete(idp.grants, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	if !exists || r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != testRedirectURL ||
+		pkceChallenge(r.PostFormValue("code_verifier")) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := idp.standardClaims(grant.nonce)
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     idp.sign("RS256", "rsa-1", claims),
+	})
+}
+
+// standardClaims returns valid ID token claims for this provider
+func (idp *testIdP) standardClaims(nonce string) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   idp.server.URL,
+		"aud":   testClientID,
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"iat":   now.Unix(),
+		"nonce": nonce,
+	}
+	idp.mu.Lock()
+	for k, v := range idp.claims {
+		claims[k] = v
+	}
+	idp.mu.Unlock()
+	return claims
+}
+
+// sign encodes and signs a JWT with one of the provider's keys
+func (idp *testIdP) sign(alg, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, idp.rsaKey, crypto.SHA256, digest[:])
+	case "ES256":
+		r, s, _ := ecdsa.Sign(rand.Reader, idp.ecKey, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (idp *testIdP) provider() *OIDCProvider {
+	return NewOIDCProvider(idp.server.URL, testClientID, testClientSecret, testRedirectURL)
+}
+
+func TestOIDCProvider_CodeFlow(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := idp.provider()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("Failed to build auth URL: %v", err)
+	}
+	code, state := idp.authorize(t, authURL)
+	if state != "state-1" {
+		t.Errorf("Expected state-1, got %s", state)
+	}
+
+	claims, err := provider.Exchange(context.Background(), code, "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if claims.Subject != "user-123" || claims.Email != "Ada@Example.com" || !claims.EmailVerified || claims.Name != "Ada Lovelace" {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+	if claims.Issuer != idp.server.URL {
+		t.Errorf("Expected issuer %s, got %s", idp.server.URL, claims.Issuer)
+	}
+
+	// Codes are single use
+	if _, err := provider.Exchange(context.Background(), code, "verifier-1", "nonce-1"); err == nil {
+		t.Error("Expected a reused code to fail")
+	}
+}
+
+func TestOIDCProvider_ExchangeRejectsWrongVerifier(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := idp.provider()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "right-verifier")
+	if err != nil {
+		t.Fatalf("Failed to build auth URL: %v", err)
+	}
+	code, _ := idp.authorize(t, authURL)
+
+	if _, err := provider.Exchange(context.Background(), code, "wrong-verifier", "nonce"); err == nil {
+		t.Error("Expected exchange with the wrong PKCE verifier to fail")
+	}
+}
+
+func TestOIDCProvider_VerifyIDToken(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := idp.provider()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	with := func(changes map[string]interface{}) map[string]interface{} {
+		claims := idp.standardClaims("nonce")
+		for k, v := range changes {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+		valid bool
+	}{
+		{"RS256", func() string { return idp.sign("RS256", "rsa-1", with(nil)) }, true},
+		{"ES256", func() string { return idp.sign("ES256", "ec-1", with(nil)) }, true},
+		{"audience list with azp", func() string {
+			return idp.sign("RS256", "rsa-1", with(map[string]interface{}{"aud": []string{testClientID, "other"}, "azp": testClientID}))
+		}, true},
+		{"email_verified as string", func() string {
+			return idp.sign("RS256", "rsa-1", with(map[string]interface{}{"email_verified": "true"}))
+		}, true},
+		{"wrong issuer", func() string {
+			return idp.sign("RS256", "rsa-1", with(map[string]interface{}{"iss": "https://evil.example.com"}))
+		}, false},
+		{"wrong audience", func() string {
+			return idp.sign("RS256", "rsa-1", with(map[string]interface{}{"aud": "someone-else"}))
+		}, false},
+		{"audience list without azp", func() string {
+			return idp.sign("RS256", "rsa-1", with(map[string]interface{}{"aud": []string{testClientID, "other"}}))
+		}, false},
+		{"expired", func() string {
+			return idp.sign("RS256", "rsa-1", with(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}))
+		}, false},
+		{"no expiry", func() string { return idp.sign("RS256", "rsa-1", with(map[string]interface{}{"exp": nil})) }, false},
+		{"issued in the future", func() string {
+			return idp.sign("RS256", "rsa-1", with(map[string]interface{}{"iat": time.Now().Add(time.Hour).Unix()}))
+		}, false},
+		{"wrong nonce", func() string {
+			return idp.sign("RS256", "rsa-1", with(map[string]interface{}{"nonce": "replayed"}))
+		}, false},
+		{"no subject", func() string { return idp.sign("RS256", "rsa-1", with(map[string]interface{}{"sub": nil})) }, false},
+		{"unknown key", func() string { return idp.sign("RS256", "rsa-2", with(nil)) }, false},
+		{"algorithm does not match key", func() string { return idp.sign("ES256", "rsa-1", with(nil)) }, false},
+		{"alg none", func() string {
+			token := idp.sign("RS256", "rsa-1", with(nil))
+			header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa-1"}`))
+			return header + token[strings.Index(token, "."):]
+		}, false},
+		{"signed by another key", func() string {
+			real := idp.rsaKey
+			idp.rsaKey = otherKey
+			defer func() { idp.rsaKey = real }()
+			return idp.sign("RS256", "rsa-1", with(nil))
+		}, false},
+		{"not a JWT", func() string { return "not.a-jwt" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := provider.verifyIDToken(context.Background(), tt.token(), "nonce")
+			if tt.valid {
+				if err != nil {
+					t.Errorf("Expected token to be valid, got %v", err)
+				} else if claims.Subject != "user-123" || !claims.EmailVerified {
+					t.Errorf("Unexpected claims %+v", claims)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("Expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOIDCProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	idp.issuer = "https://evil.example.com"
+
+	// The configured issuer must match the one the provider reports
+	if _, err := idp.provider().AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil {
+		t.Error("Expected discovery to fail")
+	}
+}
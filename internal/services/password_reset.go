@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"GopherTales/internal/models"
+)
+
+const (
+	// passwordResetTTL is how long a reset link stays valid
+	passwordResetTTL = time.Hour
+	// passwordResetResendInterval is the minimum time between reset emails
+	passwordResetResendInterval = 2 * time.Minute
+)
+
+// PasswordResetService recovers accounts through emailed one-time links
+type PasswordResetService struct {
+	users    *UserService
+	tokens   TokenStore
+	sessions SessionStore
+	mailer   Mailer
+	baseURL  string
+	now      func() time.Time
+}
+
+// NewPasswordResetService creates a password reset service. Reset links
+// point at baseURL + "/reset-password".
+func NewPasswordResetService(users *UserService, tokens TokenStore, sessions SessionStore, mailer Mailer, baseURL string) *PasswordResetService {
+	return &PasswordResetService{
+		users:    users,
+		tokens:   tokens,
+		sessions: sessions,
+		mailer:   mailer,
+		baseURL:  baseURL,
+		now:      time.Now,
+	}
+}
+
+// RequestReset emails a reset link to the account with this email. Unknown
+// emails and requests within passwordResetResendInterval of the last email
+// are ignored without an error so accounts cannot be enumerated.
+func (s *PasswordResetService) RequestReset(ctx context.Context, email string) error {
+	user, err := s.users.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		log.Printf("Password reset requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	marked, err := s.users.MarkResetSent(ctx, user.ID, now, passwordResetResendInterval)
+	if err != nil {
+		return err
+	}
+	if !marked {
+		log.Printf("Password reset requested again too soon for user %s", user.ID.Hex())
+		return nil
+	}
+
+	// Only the newest link works
+	if err := s.tokens.DeleteByUser(ctx, user.ID, models.TokenPasswordReset); err != nil {
+		return err
+	}
+
+	token, err := issueToken(ctx, s.tokens, user.ID, models.TokenPasswordReset, now, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.baseURL + "/reset-password?token=" + url.QueryEscape(token)
+	return s.mailer.Send(Email{
+		To:      user.Email,
+		Subject: "Reset your GopherTales password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your GopherTales account.\n"+
+			"Open this link within the next hour to choose a new one:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.\n", user.Name, link),
+	})
+}
+
+// ResetPassword sets a new password using a reset token. The token can only
+// be used once, and every existing session of the user is revoked. A password
+// that breaks the policy returns a *ValidationError and keeps the token valid.
+func (s *PasswordResetService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if err := ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
+	consumed, err := s.tokens.Consume(ctx, hashToken(token), models.TokenPasswordReset, s.now())
+	if err != nil {
+		return err
+	}
+
+	if err := s.users.SetPassword(ctx, consumed.UserID, newPassword); err != nil {
+		return err
+	}
+
+	if _, err := s.sessions.DeleteByUser(ctx, consumed.UserID); err != nil {
+		return fmt.Errorf("password changed but sessions were not revoked: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPasswordResetService_RequestResetThrottled(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, users *UserService) {
+		if _, err := users.Register(context.Background(), "Ada", "ada@example.com", "gopher123"); err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+
+		now := time.Now()
+		mailer := &recordingMailer{}
+		service := NewPasswordResetService(users, NewMemoryTokenStore(), NewMemorySessionStore(), mailer, "http://localhost:8000")
+		service.now = func() time.Time { return now }
+
+		for _, step := range []struct {
+			after time.Duration
+			email string
+			want  int
+		}{
+			{0, "ada@example.com", 1},
+			{30 * time.Second, "ADA@example.com", 1}, // Too soon, ignored
+			{2 * time.Minute, "ada@example.com", 2},
+			{0, "nobody@example.com", 2}, // Unknown, ignored
+		} {
+			now = now.Add(step.after)
+			if err := service.RequestReset(context.Background(), step.email); err != nil {
+				t.Fatalf("Expected no error for %s, got %v", step.email, err)
+			}
+			if len(mailer.sent) != step.want {
+				t.Errorf("Expected %d emails after asking for %s, got %d", step.want, step.email, len(mailer.sent))
+			}
+		}
+	})
+}
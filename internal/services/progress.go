@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+
+	"GopherTales/internal/models"
+)
+
+// IsEnding reports whether an arc is an ending in the story as written. Arcs
+// whose options are all hidden by requirements do not count.
+func (s *StoryService) IsEnding(gopher, arcName string) bool {
+	_, gophers, _ := s.snapshot()
+	arc, exists := gophers[gopher][arcName]
+	return exists && len(arc.Options) == 0
+}
+
+// SummarizeProgress measures a reader's progress through a gopher's story
+// against the current story graph. Arcs and endings that were removed or
+// can no longer be reached from intro are not counted.
+func (s *StoryService) SummarizeProgress(gopher string, progress models.GopherProgress) (models.ProgressSummary, error) {
+	_, gophers, catalog := s.snapshot()
+	arcs, exists := gophers[gopher]
+	if !exists {
+		return models.ProgressSummary{}, fmt.Errorf("gopher '%s' not found", gopher)
+	}
+
+	summary := models.ProgressSummary{
+		Gopher:     gopher,
+		Name:       gopher,
+		Choices:    len(progress.Path),
+		LastArc:    progress.LastArc(),
+		LastPlayed: progress.UpdatedAt,
+	}
+	if profile, ok := gopherProfile(gophers, catalog, gopher); ok {
+		summary.Name = profile.Name
+	}
+
+	reachable := newArcGraph(arcs).distancesFrom("intro")
+	summary.ReachableArcs = len(reachable)
+	for name := range reachable {
+		if len(arcs[name].Options) == 0 {
+			summary.TotalEndings++
+		}
+	}
+
+	for _, name := range dedupe(progress.VisitedArcs) {
+		if _, ok := reachable[name]; ok {
+			summary.ArcsVisited++
+		}
+	}
+	for _, name := range dedupe(progress.EndingsReached) {
+		if _, ok := reachable[name]; ok && len(arcs[name].Options) == 0 {
+			summary.EndingsFound++
+		}
+	}
+
+	// Old accounts only recorded that some ending was reached
+	if progress.LegacyCompleted && summary.EndingsFound == 0 && summary.TotalEndings > 0 {
+		summary.EndingsFound = 1
+	}
+
+	summary.ArcPercent = percent(summary.ArcsVisited, summary.ReachableArcs)
+	summary.EndingPercent = percent(summary.EndingsFound, summary.TotalEndings)
+	summary.StoryCompleted = summary.TotalEndings > 0 && summary.EndingsFound == summary.TotalEndings
+	return summary, nil
+}
+
+// SummarizeAllProgress summarises a reader's progress for every gopher they
+// have started, in catalog order. Gophers no longer in the story are skipped.
+func (s *StoryService) SummarizeAllProgress(progress map[string]models.GopherProgress) []models.ProgressSummary {
+	summaries := make([]models.ProgressSummary, 0, len(progress))
+	for _, profile := range s.GetCatalog() {
+		p, started := progress[profile.ID]
+		if !started {
+			continue
+		}
+		summary, err := s.SummarizeProgress(profile.ID, p)
+		if err != nil {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// percent returns part as a whole percentage of total, 0 when total is 0
+func percent(part, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return part * 100 / total
+}
+
+// dedupe returns the distinct values in order of first appearance
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
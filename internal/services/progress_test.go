@@ -0,0 +1,95 @@
+package services
+
+import (
+	"testing"
+
+	"GopherTales/internal/models"
+)
+
+func TestStoryService_SummarizeProgress(t *testing.T) {
+	path := writeTempStory(t, `{
+		"catalog": [{"id": "blue", "name": "Blue Gopher"}],
+		"blue": {
+			"intro": {"title": "Intro", "story": [], "options": [
+				{"text": "Left", "arc": "left"},
+				{"text": "Right", "arc": "right"}
+			]},
+			"left": {"title": "Left", "story": [], "options": [{"text": "Home", "arc": "home"}]},
+			"right": {"title": "Right", "story": [], "options": [{"text": "Cave", "arc": "cave"}]},
+			"home": {"title": "Home", "story": [], "options": []},
+			"cave": {"title": "Cave", "story": [], "options": []},
+			"orphan": {"title": "Orphan", "story": [], "options": []}
+		}
+	}`)
+
+	service := NewStoryService(path)
+	if err := service.LoadStory(); err != nil {
+		t.Fatalf("Failed to load story: %v", err)
+	}
+
+	progress := models.GopherProgress{
+		VisitedArcs:    []string{"intro", "left", "home", "removed", "orphan"},
+		EndingsReached: []string{"home", "orphan"},
+		Path: []models.PathStep{
+			{Arc: "intro"}, {Arc: "left"}, {Arc: "home"}, {Arc: "intro"}, {Arc: "left"},
+		},
+	}
+
+	summary, err := service.SummarizeProgress("blue", progress)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := models.ProgressSummary{
+		Gopher:        "blue",
+		Name:          "Blue Gopher",
+		ArcsVisited:   3, // removed and unreachable arcs do not count
+		ReachableArcs: 5,
+		EndingsFound:  1,
+		TotalEndings:  2,
+		ArcPercent:    60,
+		EndingPercent: 50,
+		Choices:       5,
+		LastArc:       "left",
+	}
+	if summary != expected {
+		t.Errorf("Unexpected summary:\n got  %+v\n want %+v", summary, expected)
+	}
+
+	progress.EndingsReached = append(progress.EndingsReached, "cave")
+	summary, _ = service.SummarizeProgress("blue", progress)
+	if !summary.StoryCompleted || summary.EndingPercent != 100 {
+		t.Errorf("Expected story to be completed, got %+v", summary)
+	}
+
+	legacy, _ := service.SummarizeProgress("blue", models.GopherProgress{LegacyCompleted: true})
+	if legacy.EndingsFound != 1 || legacy.EndingPercent != 50 {
+		t.Errorf("Expected a legacy completion to count as one ending, got %+v", legacy)
+	}
+
+	if _, err := service.SummarizeProgress("pink", progress); err == nil {
+		t.Error("Expected error for unknown gopher")
+	}
+
+	all := service.SummarizeAllProgress(map[string]models.GopherProgress{
+		"blue": progress,
+		"gone": progress,
+	})
+	if len(all) != 1 || all[0].Gopher != "blue" {
+		t.Errorf("Expected only blue to be summarised, got %+v", all)
+	}
+}
+
+func TestStoryService_IsEnding(t *testing.T) {
+	service := NewStoryService("../../gopher_six.json")
+	if err := service.LoadStory(); err != nil {
+		t.Fatalf("Failed to load story: %v", err)
+	}
+
+	if service.IsEnding("blue", "intro") {
+		t.Error("Expected intro not to be an ending")
+	}
+	if service.IsEnding("blue", "missing") {
+		t.Error("Expected missing arc not to be an ending")
+	}
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+// SessionCookieName is the cookie that carries the signed session token
+const SessionCookieName = "session"
+
+// sessionTouchInterval limits how often sliding expiry writes to the store;
+// requests closer together than this reuse the stored expiry
+const sessionTouchInterval = time.Minute
+
+var (
+	// ErrSessionNotFound is returned when a session does not exist or has expired
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrInvalidSessionCookie is returned when a cookie is malformed or its signature does not match
+	ErrInvalidSessionCookie = errors.New("invalid session cookie")
+)
+
+// SessionStore persists login sessions by ID
+type SessionStore interface {
+	Create(ctx context.Context, session *models.Session) error
+	Get(ctx context.Context, id string) (*models.Session, error)
+	Touch(ctx context.Context, id string, lastSeen, expiresAt time.Time) error
+	Delete(ctx context.Context, id string) error
+	DeleteByUser(ctx context.Context, userID primitive.ObjectID) (int, error)
+}
+
+// minSessionPrune is how many sessions the memory store holds before it
+// first looks for expired ones
+const minSessionPrune = 1000
+
+// MemorySessionStore keeps sessions in process memory. Expired sessions are
+// dropped whenever the store has doubled in size since the last sweep, so
+// abandoned sessions do not pile up.
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]models.Session
+	pruneAt  int // Size that triggers the next sweep
+}
+
+// NewMemorySessionStore creates an empty in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]models.Session),
+		pruneAt:  minSessionPrune,
+	}
+}
+
+// Create stores a copy of the session
+func (m *MemorySessionStore) Create(ctx context.Context, session *models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[session.ID] = *session
+
+	if len(m.sessions) >= m.pruneAt {
+		for id, existing := range m.sessions {
+			if existing.Expired(session.CreatedAt) {
+				delete(m.sessions, id)
+			}
+		}
+		m.pruneAt = 2 * len(m.sessions)
+		if m.pruneAt < minSessionPrune {
+			m.pruneAt = minSessionPrune
+		}
+	}
+	return nil
+}
+
+// Get returns a copy of the session, or ErrSessionNotFound
+func (m *MemorySessionStore) Get(ctx context.Context, id string) (*models.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, exists := m.sessions[id]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// Touch moves a session's last-seen time and expiry forward
+func (m *MemorySessionStore) Touch(ctx context.Context, id string, lastSeen, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, exists := m.sessions[id]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	session.LastSeenAt = lastSeen
+	session.ExpiresAt = expiresAt
+	m.sessions[id] = session
+	return nil
+}
+
+// Delete removes a session. Deleting a missing session is not an error.
+func (m *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+// DeleteByUser removes every session belonging to a user
+func (m *MemorySessionStore) DeleteByUser(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := 0
+	for id, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// SessionManager issues, verifies and revokes login sessions. Cookies carry a
+// random token signed with HMAC-SHA256; the store is keyed by a hash of the
+// token. Each request that uses a session pushes its expiry ttl further out.
+type SessionManager struct {
+	store  SessionStore
+	secret []byte
+	ttl    time.Duration
+	secure bool
+	now    func() time.Time
+}
+
+// NewSessionManager creates a session manager. Cookies are marked Secure
+// unless SetSecure(false) is called for plain-HTTP development.
+func NewSessionManager(store SessionStore, secret []byte, ttl time.Duration) *SessionManager {
+	return &SessionManager{
+		store:  store,
+		secret: secret,
+		ttl:    ttl,
+		secure: true,
+		now:    time.Now,
+	}
+}
+
+// SetSecure sets whether session cookies are only sent over HTTPS
+func (m *SessionManager) SetSecure(secure bool) {
+	m.secure = secure
+}
+
+// Start creates a session for a user and sets its cookie
+func (m *SessionManager) Start(ctx context.Context, w http.ResponseWriter, userID primitive.ObjectID) (*models.Session, error) {
+	token, err := newRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	session := &models.Session{
+		ID:         hashToken(token),
+		UserID:     userID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(m.ttl),
+	}
+	if err := m.store.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	m.setCookie(w, token+"."+m.sign(token), session.ExpiresAt)
+	return session, nil
+}
+
+// Resolve returns the session the request's cookie refers to, extending its
+// expiry. A missing, tampered or expired cookie yields an error.
+func (m *SessionManager) Resolve(w http.ResponseWriter, r *http.Request) (*models.Session, error) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+	token, err := m.verify(cookie.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := r.Context()
+	session, err := m.store.Get(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	if session.Expired(now) {
+		if err := m.store.Delete(ctx, session.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrSessionNotFound
+	}
+
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		expiresAt := now.Add(m.ttl)
+		if err := m.store.Touch(ctx, session.ID, now, expiresAt); err != nil {
+			return nil, err
+		}
+		session.LastSeenAt = now
+		session.ExpiresAt = expiresAt
+		m.setCookie(w, cookie.Value, expiresAt)
+	}
+
+	return session, nil
+}
+
+// End revokes the request's session, if any, and clears its cookie
+func (m *SessionManager) End(w http.ResponseWriter, r *http.Request) error {
+	defer m.clearCookie(w)
+
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return nil
+	}
+	token, err := m.verify(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	return m.store.Delete(r.Context(), hashToken(token))
+}
+
+// EndAll revokes every session belonging to a user, on every device, and
+// clears the cookie on this one. It returns the number of sessions revoked.
+func (m *SessionManager) EndAll(ctx context.Context, w http.ResponseWriter, userID primitive.ObjectID) (int, error) {
+	m.clearCookie(w)
+	return m.store.DeleteByUser(ctx, userID)
+}
+
+// sign returns the base64url HMAC-SHA256 of a token
+func (m *SessionManager) sign(token string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks a "<token>.<signature>" cookie value and returns the token
+func (m *SessionManager) verify(value string) (string, error) {
+	token, signature, ok := strings.Cut(value, ".")
+	if !ok || token == "" {
+		return "", ErrInvalidSessionCookie
+	}
+	if !hmac.Equal([]byte(signature), []byte(m.sign(token))) {
+		return "", ErrInvalidSessionCookie
+	}
+	return token, nil
+}
+
+func (m *SessionManager) setCookie(w http.ResponseWriter, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    value,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	})
+}
+
+func (m *SessionManager) clearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	})
+}
+
+// newRandomToken returns 32 random bytes, base64url encoded
+func newRandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken returns the store ID for a session or one-time token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
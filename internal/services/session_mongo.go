@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// MongoSessionStore keeps sessions in the "sessions" collection. A TTL index
+// on expires_at lets MongoDB delete sessions once they expire.
+type MongoSessionStore struct {
+	db         *database.MongoDB
+	collection *mongo.Collection
+}
+
+// NewMongoSessionStore creates a session store on a migrated database
+func NewMongoSessionStore(db *database.MongoDB) *MongoSessionStore {
+	return &MongoSessionStore{db: db, collection: db.Database.Collection("sessions")}
+}
+
+// Create inserts a new session
+func (s *MongoSessionStore) Create(ctx context.Context, session *models.Session) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.collection.InsertOne(ctx, session)
+	return err
+}
+
+// Get returns a session, or ErrSessionNotFound
+func (s *MongoSessionStore) Get(ctx context.Context, id string) (*models.Session, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	var session models.Session
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Touch moves a session's last-seen time and expiry forward
+func (s *MongoSessionStore) Touch(ctx context.Context, id string, lastSeen, expiresAt time.Time) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"last_seen_at": lastSeen,
+		"expires_at":   expiresAt,
+	}}
+
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Delete removes a session. Deleting a missing session is not an error.
+func (s *MongoSessionStore) Delete(ctx context.Context, id string) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// DeleteByUser removes every session belonging to a user
+func (s *MongoSessionStore) DeleteByUser(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := s.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.DeletedCount), nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// SQLiteSessionStore keeps sessions in the "sessions" table. Expired
+// sessions are deleted whenever a new one is created.
+type SQLiteSessionStore struct {
+	db *database.SQLite
+}
+
+// NewSQLiteSessionStore creates a session store on a migrated database
+func NewSQLiteSessionStore(db *database.SQLite) *SQLiteSessionStore {
+	return &SQLiteSessionStore{db: db}
+}
+
+// Create inserts a new session
+func (s *SQLiteSessionStore) Create(ctx context.Context, session *models.Session) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.DB.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= ?`, toSQLiteTime(time.Now())); err != nil {
+		return err
+	}
+
+	_, err := s.db.DB.ExecContext(ctx, `INSERT INTO sessions (id, user_id, created_at, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		session.ID, session.UserID.Hex(), toSQLiteTime(session.CreatedAt),
+		toSQLiteTime(session.LastSeenAt), toSQLiteTime(session.ExpiresAt))
+	return err
+}
+
+// Get returns a session, or ErrSessionNotFound
+func (s *SQLiteSessionStore) Get(ctx context.Context, id string) (*models.Session, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	var (
+		session                        models.Session
+		userID                         string
+		createdAt, lastSeen, expiresAt int64
+	)
+	err := s.db.DB.QueryRowContext(ctx, `SELECT id, user_id, created_at, last_seen_at, expires_at FROM sessions WHERE id = ?`, id).
+		Scan(&session.ID, &userID, &createdAt, &lastSeen, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID, err = primitive.ObjectIDFromHex(userID); err != nil {
+		return nil, err
+	}
+	session.CreatedAt = fromSQLiteTime(createdAt)
+	session.LastSeenAt = fromSQLiteTime(lastSeen)
+	session.ExpiresAt = fromSQLiteTime(expiresAt)
+	return &session, nil
+}
+
+// Touch moves a session's last-seen time and expiry forward
+func (s *SQLiteSessionStore) Touch(ctx context.Context, id string, lastSeen, expiresAt time.Time) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.DB.ExecContext(ctx, `UPDATE sessions SET last_seen_at = ?, expires_at = ? WHERE id = ?`,
+		toSQLiteTime(lastSeen), toSQLiteTime(expiresAt), id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Delete removes a session. Deleting a missing session is not an error.
+func (s *SQLiteSessionStore) Delete(ctx context.Context, id string) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.DB.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, id)
+	return err
+}
+
+// DeleteByUser removes every session belonging to a user
+func (s *SQLiteSessionStore) DeleteByUser(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.DB.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, userID.Hex())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+// sessionCookie returns the session cookie set on a response
+func sessionCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == SessionCookieName {
+			return cookie
+		}
+	}
+	t.Fatalf("Expected a %s cookie to be set", SessionCookieName)
+	return nil
+}
+
+func requestWithCookie(cookie *http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	return req
+}
+
+func newTestSessionManager(now *time.Time) (*SessionManager, *MemorySessionStore) {
+	store := NewMemorySessionStore()
+	manager := NewSessionManager(store, []byte("test-secret"), time.Hour)
+	manager.now = func() time.Time { return *now }
+	return manager, store
+}
+
+func TestSessionManager_StartAndResolve(t *testing.T) {
+	now := time.Now()
+	manager, store := newTestSessionManager(&now)
+	userID := primitive.NewObjectID()
+
+	rec := httptest.NewRecorder()
+	session, err := manager.Start(context.Background(), rec, userID)
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+
+	cookie := sessionCookie(t, rec)
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Expected an HttpOnly, Secure, SameSite=Lax cookie, got %+v", cookie)
+	}
+	if strings.Contains(cookie.Value, session.ID) {
+		t.Error("Expected the cookie not to contain the stored session ID")
+	}
+	if _, err := store.Get(context.Background(), session.ID); err != nil {
+		t.Errorf("Expected the session to be stored: %v", err)
+	}
+
+	resolved, err := manager.Resolve(httptest.NewRecorder(), requestWithCookie(cookie))
+	if err != nil {
+		t.Fatalf("Failed to resolve session: %v", err)
+	}
+	if resolved.UserID != userID {
+		t.Errorf("Expected user %s, got %s", userID.Hex(), resolved.UserID.Hex())
+	}
+}
+
+func TestSessionManager_RejectsTamperedCookies(t *testing.T) {
+	now := time.Now()
+	manager, _ := newTestSessionManager(&now)
+
+	rec := httptest.NewRecorder()
+	if _, err := manager.Start(context.Background(), rec, primitive.NewObjectID()); err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	token, _, _ := strings.Cut(sessionCookie(t, rec).Value, ".")
+
+	other := NewSessionManager(NewMemorySessionStore(), []byte("other-secret"), time.Hour)
+	forged := token + "." + other.sign(token)
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"missing signature", token},
+		{"wrong signature", forged},
+		{"raw user ID", primitive.NewObjectID().Hex()},
+		{"empty token", "." + manager.sign("")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := requestWithCookie(&http.Cookie{Name: SessionCookieName, Value: tt.value})
+			_, err := manager.Resolve(httptest.NewRecorder(), req)
+			if !errors.Is(err, ErrInvalidSessionCookie) {
+				t.Errorf("Expected ErrInvalidSessionCookie, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSessionManager_SlidingExpiry(t *testing.T) {
+	now := time.Now()
+	manager, _ := newTestSessionManager(&now)
+
+	rec := httptest.NewRecorder()
+	if _, err := manager.Start(context.Background(), rec, primitive.NewObjectID()); err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	cookie := sessionCookie(t, rec)
+
+	// Used every 45 minutes, a one hour session stays alive
+	for i := 0; i < 4; i++ {
+		now = now.Add(45 * time.Minute)
+		rec := httptest.NewRecorder()
+		session, err := manager.Resolve(rec, requestWithCookie(cookie))
+		if err != nil {
+			t.Fatalf("Expected session to still be valid after %d uses: %v", i+1, err)
+		}
+		if want := now.Add(time.Hour); !session.ExpiresAt.Equal(want) {
+			t.Errorf("Expected expiry %v, got %v", want, session.ExpiresAt)
+		}
+		if refreshed := sessionCookie(t, rec); !refreshed.Expires.After(now) {
+			t.Errorf("Expected the cookie expiry to be refreshed, got %v", refreshed.Expires)
+		}
+	}
+
+	// Left idle for longer than the TTL, it expires
+	now = now.Add(61 * time.Minute)
+	if _, err := manager.Resolve(httptest.NewRecorder(), requestWithCookie(cookie)); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected expired session to be rejected, got %v", err)
+	}
+}
+
+// failingDeleteStore is a session store whose deletes fail
+type failingDeleteStore struct {
+	*MemorySessionStore
+}
+
+func (f failingDeleteStore) Delete(ctx context.Context, id string) error {
+	return errors.New("store unavailable")
+}
+
+func TestSessionManager_ExpiredDeleteError(t *testing.T) {
+	now := time.Now()
+	store := failingDeleteStore{NewMemorySessionStore()}
+	manager := NewSessionManager(store, []byte("test-secret"), time.Hour)
+	manager.now = func() time.Time { return now }
+
+	rec := httptest.NewRecorder()
+	if _, err := manager.Start(context.Background(), rec, primitive.NewObjectID()); err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+
+	// A failed cleanup is reported instead of passing as a missing session
+	now = now.Add(2 * time.Hour)
+	_, err := manager.Resolve(httptest.NewRecorder(), requestWithCookie(sessionCookie(t, rec)))
+	if err == nil || errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected the delete error, got %v", err)
+	}
+}
+
+func TestSessionManager_End(t *testing.T) {
+	now := time.Now()
+	manager, store := newTestSessionManager(&now)
+
+	rec := httptest.NewRecorder()
+	session, err := manager.Start(context.Background(), rec, primitive.NewObjectID())
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	cookie := sessionCookie(t, rec)
+
+	rec = httptest.NewRecorder()
+	if err := manager.End(rec, requestWithCookie(cookie)); err != nil {
+		t.Fatalf("Failed to end session: %v", err)
+	}
+	if cleared := sessionCookie(t, rec); cleared.Value != "" || cleared.MaxAge >= 0 {
+		t.Errorf("Expected the cookie to be cleared, got %+v", cleared)
+	}
+	if _, err := store.Get(context.Background(), session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected the session to be revoked, got %v", err)
+	}
+
+	// A copy of the old cookie no longer works
+	if _, err := manager.Resolve(httptest.NewRecorder(), requestWithCookie(cookie)); err == nil {
+		t.Error("Expected a revoked session to be rejected")
+	}
+}
+
+func TestSessionManager_EndAll(t *testing.T) {
+	now := time.Now()
+	manager, _ := newTestSessionManager(&now)
+	userID := primitive.NewObjectID()
+
+	var cookies []*http.Cookie
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		if _, err := manager.Start(context.Background(), rec, userID); err != nil {
+			t.Fatalf("Failed to start session: %v", err)
+		}
+		cookies = append(cookies, sessionCookie(t, rec))
+	}
+
+	otherRec := httptest.NewRecorder()
+	if _, err := manager.Start(context.Background(), otherRec, primitive.NewObjectID()); err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+
+	revoked, err := manager.EndAll(context.Background(), httptest.NewRecorder(), userID)
+	if err != nil {
+		t.Fatalf("Failed to end sessions: %v", err)
+	}
+	if revoked != 3 {
+		t.Errorf("Expected 3 sessions revoked, got %d", revoked)
+	}
+
+	for _, cookie := range cookies {
+		if _, err := manager.Resolve(httptest.NewRecorder(), requestWithCookie(cookie)); err == nil {
+			t.Error("Expected every session of the user to be revoked")
+		}
+	}
+	if _, err := manager.Resolve(httptest.NewRecorder(), requestWithCookie(sessionCookie(t, otherRec))); err != nil {
+		t.Errorf("Expected other users' sessions to survive: %v", err)
+	}
+}
+
+func TestMemorySessionStore_PrunesExpired(t *testing.T) {
+	store := NewMemorySessionStore()
+	now := time.Now()
+
+	for i := 0; i < minSessionPrune; i++ {
+		session := &models.Session{ID: fmt.Sprint("old-", i), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+		if err := store.Create(context.Background(), session); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	// Once they have expired, filling the store again sweeps them away
+	now = now.Add(2 * time.Hour)
+	for i := 0; i < minSessionPrune; i++ {
+		session := &models.Session{ID: fmt.Sprint("new-", i), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+		if err := store.Create(context.Background(), session); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	if len(store.sessions) > minSessionPrune {
+		t.Errorf("Expected expired sessions to be dropped, %d are kept", len(store.sessions))
+	}
+	if _, err := store.Get(context.Background(), "new-0"); err != nil {
+		t.Errorf("Expected live sessions to be kept: %v", err)
+	}
+}
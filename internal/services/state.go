@@ -0,0 +1,107 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"GopherTales/internal/models"
+)
+
+// PlaythroughTTL is how long story state is kept after a playthrough was
+// last saved
+const PlaythroughTTL = 30 * 24 * time.Hour
+
+// memoryStateLimit caps the playthroughs a MemoryStateStore keeps, so
+// readers without cookies cannot grow it without bound
+const memoryStateLimit = 100000
+
+// StateStore persists story state for each playthrough and gopher. Every
+// replica must see the same state, since a reader's requests are not routed
+// to the same one.
+type StateStore interface {
+	// Get returns the stored state, or a fresh state if none exists or it
+	// has expired
+	Get(ctx context.Context, playthroughID, gopher string) (*models.StoryState, error)
+	// Save stores a copy of the state until expiresAt
+	Save(ctx context.Context, playthroughID, gopher string, state *models.StoryState, expiresAt time.Time) error
+}
+
+// MemoryStateStore keeps story state in process memory. When full it drops
+// the playthroughs saved least recently.
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	limit  int
+	order  *list.List // *memoryState, most recently saved first
+	states map[string]*list.Element
+	now    func() time.Time
+}
+
+type memoryState struct {
+	key       string
+	state     *models.StoryState
+	expiresAt time.Time
+}
+
+// NewMemoryStateStore creates an empty in-memory state store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		limit:  memoryStateLimit,
+		order:  list.New(),
+		states: make(map[string]*list.Element),
+		now:    time.Now,
+	}
+}
+
+// Get returns a copy of the stored state, or a fresh state if none exists
+func (m *MemoryStateStore) Get(ctx context.Context, playthroughID, gopher string) (*models.StoryState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, exists := m.states[stateKey(playthroughID, gopher)]
+	if !exists {
+		return models.NewStoryState(), nil
+	}
+	entry := element.Value.(*memoryState)
+	if !m.now().Before(entry.expiresAt) {
+		m.remove(element)
+		return models.NewStoryState(), nil
+	}
+	return entry.state.Clone(), nil
+}
+
+// Save stores a copy of the state, dropping expired playthroughs and, past
+// the limit, the least recently saved ones
+func (m *MemoryStateStore) Save(ctx context.Context, playthroughID, gopher string, state *models.StoryState, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := stateKey(playthroughID, gopher)
+	if element, exists := m.states[key]; exists {
+		entry := element.Value.(*memoryState)
+		entry.state = state.Clone()
+		entry.expiresAt = expiresAt
+		m.order.MoveToFront(element)
+	} else {
+		m.states[key] = m.order.PushFront(&memoryState{key: key, state: state.Clone(), expiresAt: expiresAt})
+	}
+
+	now := m.now()
+	for oldest := m.order.Back(); oldest != nil; oldest = m.order.Back() {
+		if m.order.Len() <= m.limit && now.Before(oldest.Value.(*memoryState).expiresAt) {
+			break
+		}
+		m.remove(oldest)
+	}
+	return nil
+}
+
+func (m *MemoryStateStore) remove(element *list.Element) {
+	m.order.Remove(element)
+	delete(m.states, element.Value.(*memoryState).key)
+}
+
+func stateKey(playthroughID, gopher string) string {
+	return playthroughID + ":" + gopher
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// MongoStateStore keeps story state in the "story_states" collection. A TTL
+// index on expires_at lets MongoDB delete abandoned playthroughs.
+type MongoStateStore struct {
+	db         *database.MongoDB
+	collection *mongo.Collection
+}
+
+// storedState is a document in the "story_states" collection
+type storedState struct {
+	ID        string         `bson:"_id"`
+	Vars      map[string]any `bson:"vars"`
+	LastArc   string         `bson:"last_arc"`
+	ExpiresAt time.Time      `bson:"expires_at"`
+}
+
+// NewMongoStateStore creates a state store on a migrated database
+func NewMongoStateStore(db *database.MongoDB) *MongoStateStore {
+	return &MongoStateStore{db: db, collection: db.Database.Collection("story_states")}
+}
+
+// Get returns the stored state, or a fresh state if none exists
+func (s *MongoStateStore) Get(ctx context.Context, playthroughID, gopher string) (*models.StoryState, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	// The TTL monitor only runs once a minute
+	var stored storedState
+	err := s.collection.FindOne(ctx, bson.M{
+		"_id":        stateKey(playthroughID, gopher),
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&stored)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.NewStoryState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := models.NewStoryState()
+	for k, v := range stored.Vars {
+		state.Vars[k] = v
+	}
+	state.LastArc = stored.LastArc
+	return state, nil
+}
+
+// Save stores the state until expiresAt
+func (s *MongoStateStore) Save(ctx context.Context, playthroughID, gopher string, state *models.StoryState, expiresAt time.Time) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	key := stateKey(playthroughID, gopher)
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": key}, storedState{
+		ID:        key,
+		Vars:      state.Vars,
+		LastArc:   state.LastArc,
+		ExpiresAt: expiresAt,
+	}, options.Replace().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// SQLiteStateStore keeps story state in the "story_states" table. Expired
+// playthroughs are deleted whenever one is saved.
+type SQLiteStateStore struct {
+	db *database.SQLite
+}
+
+// NewSQLiteStateStore creates a state store on a migrated database
+func NewSQLiteStateStore(db *database.SQLite) *SQLiteStateStore {
+	return &SQLiteStateStore{db: db}
+}
+
+// Get returns the stored state, or a fresh state if none exists
+func (s *SQLiteStateStore) Get(ctx context.Context, playthroughID, gopher string) (*models.StoryState, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	var vars []byte
+	state := models.NewStoryState()
+	err := s.db.DB.QueryRowContext(ctx, `SELECT vars, last_arc FROM story_states WHERE id = ? AND expires_at > ?`,
+		stateKey(playthroughID, gopher), toSQLiteTime(time.Now())).Scan(&vars, &state.LastArc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(vars, &state.Vars); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Save stores the state until expiresAt
+func (s *SQLiteStateStore) Save(ctx context.Context, playthroughID, gopher string, state *models.StoryState, expiresAt time.Time) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	vars, err := json.Marshal(state.Vars)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.DB.ExecContext(ctx, `DELETE FROM story_states WHERE expires_at <= ?`, toSQLiteTime(time.Now())); err != nil {
+		return err
+	}
+	_, err = s.db.DB.ExecContext(ctx, `INSERT INTO story_states (id, vars, last_arc, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET vars = excluded.vars, last_arc = excluded.last_arc, expires_at = excluded.expires_at`,
+		stateKey(playthroughID, gopher), string(vars), state.LastArc, toSQLiteTime(expiresAt))
+	return err
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+
+	"GopherTales/internal/database"
+)
+
+// Storage is the set of stores the server keeps its data in
+type Storage struct {
+	Users         UserRepository
+	Progress      ProgressRepository
+	Bookmarks     BookmarkRepository
+	Sessions      SessionStore
+	Tokens        TokenStore
+	LoginAttempts LoginAttemptStore
+	States        StateStore
+
+	// Ping checks the database can be reached, nil when there is none
+	Ping func(ctx context.Context) error
+}
+
+// NewMemoryStorage keeps everything in process memory. Nothing survives a
+// restart and replicas do not share data, so it suits tests, demos and
+// local development.
+func NewMemoryStorage() *Storage {
+	users := NewMemoryUserRepository()
+	return &Storage{
+		Users:         users,
+		Progress:      users,
+		Bookmarks:     users,
+		Sessions:      NewMemorySessionStore(),
+		Tokens:        NewMemoryTokenStore(),
+		LoginAttempts: NewMemoryLoginAttemptStore(),
+		States:        NewMemoryStateStore(),
+	}
+}
+
+// NewMongoStorage keeps everything in MongoDB. The database must be
+// migrated first, which creates the indexes the stores rely on.
+func NewMongoStorage(db *database.MongoDB) *Storage {
+	users := NewMongoUserRepository(db)
+	return &Storage{
+		Users:         users,
+		Progress:      users,
+		Bookmarks:     users,
+		Sessions:      NewMongoSessionStore(db),
+		Tokens:        NewMongoTokenStore(db),
+		LoginAttempts: NewMongoLoginAttemptStore(db),
+		States:        NewMongoStateStore(db),
+		Ping:          db.Ping,
+	}
+}
+
+// NewSQLiteStorage keeps everything in a SQLite database, for a single
+// replica without a database server
+func NewSQLiteStorage(db *database.SQLite) *Storage {
+	users := NewSQLiteUserRepository(db)
+	return &Storage{
+		Users:         users,
+		Progress:      users,
+		Bookmarks:     users,
+		Sessions:      NewSQLiteSessionStore(db),
+		Tokens:        NewSQLiteTokenStore(db),
+		LoginAttempts: NewSQLiteLoginAttemptStore(db),
+		States:        NewSQLiteStateStore(db),
+		Ping:          db.Ping,
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// newTestSQLite opens a fresh migrated database in a temporary directory
+func newTestSQLite(t *testing.T) *database.SQLite {
+	t.Helper()
+
+	db, err := database.NewSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteTokenStore(t *testing.T) {
+	store := NewSQLiteTokenStore(newTestSQLite(t))
+	userID := primitive.NewObjectID()
+	now := time.Now()
+
+	plain, err := issueToken(context.Background(), store, userID, models.TokenPasswordReset, now, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	id := hashToken(plain)
+
+	if _, err := store.Consume(context.Background(), id, models.TokenEmailVerification, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token for another purpose to be rejected, got %v", err)
+	}
+	if _, err := store.Consume(context.Background(), id, models.TokenPasswordReset, now.Add(time.Hour)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+
+	token, err := store.Consume(context.Background(), id, models.TokenPasswordReset, now)
+	if err != nil {
+		t.Fatalf("Expected the token to be consumed: %v", err)
+	}
+	if token.UserID != userID || token.UsedAt == nil || !token.ExpiresAt.Equal(now.Add(time.Hour).Truncate(time.Millisecond)) {
+		t.Errorf("Expected a used token for the user, got %+v", token)
+	}
+	if _, err := store.Consume(context.Background(), id, models.TokenPasswordReset, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token to be single-use, got %v", err)
+	}
+
+	other, _ := issueToken(context.Background(), store, userID, models.TokenPasswordReset, now, time.Hour)
+	if err := store.DeleteByUser(context.Background(), userID, models.TokenPasswordReset); err != nil {
+		t.Fatalf("Failed to delete tokens: %v", err)
+	}
+	if _, err := store.Consume(context.Background(), hashToken(other), models.TokenPasswordReset, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the user's token to be deleted, got %v", err)
+	}
+}
+
+func TestSQLiteSessionStore(t *testing.T) {
+	store := NewSQLiteSessionStore(newTestSQLite(t))
+	userID := primitive.NewObjectID()
+	now := time.Now().Truncate(time.Millisecond)
+
+	for _, id := range []string{"a", "b"} {
+		session := &models.Session{ID: id, UserID: userID, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+		if err := store.Create(context.Background(), session); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	if err := store.Touch(context.Background(), "a", now.Add(time.Minute), now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("Failed to touch session: %v", err)
+	}
+	session, err := store.Get(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if session.UserID != userID || !session.ExpiresAt.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("Unexpected session %+v", session)
+	}
+	if err := store.Touch(context.Background(), "missing", now, now); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	if n, err := store.DeleteByUser(context.Background(), userID); err != nil || n != 2 {
+		t.Errorf("Expected 2 sessions deleted, got %d, %v", n, err)
+	}
+	if _, err := store.Get(context.Background(), "b"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSQLiteLoginAttemptStore(t *testing.T) {
+	store := NewSQLiteLoginAttemptStore(newTestSQLite(t))
+	now := time.Now()
+
+	for want := 1; want <= 3; want++ {
+		counter, err := store.RecordFailure(context.Background(), "ip:1.2.3.4", now, time.Minute)
+		if err != nil {
+			t.Fatalf("Failed to record failure: %v", err)
+		}
+		if counter.Failures != want {
+			t.Errorf("Expected %d failures, got %d", want, counter.Failures)
+		}
+	}
+
+	// A failure after the counter expired starts again from one
+	counter, err := store.RecordFailure(context.Background(), "ip:1.2.3.4", now.Add(time.Minute), time.Minute)
+	if err != nil || counter.Failures != 1 {
+		t.Errorf("Expected the counter to restart, got %+v, %v", counter, err)
+	}
+
+	// Reserving only counts from the value that was seen
+	if counter, err := store.Reserve(context.Background(), "ip:1.2.3.4", 0, now.Add(time.Minute), time.Minute); counter != nil || err != nil {
+		t.Errorf("Expected a stale reservation to be refused, got %+v, %v", counter, err)
+	}
+	counter, err = store.Reserve(context.Background(), "ip:1.2.3.4", 1, now.Add(time.Minute), time.Minute)
+	if err != nil || counter == nil || counter.Failures != 2 {
+		t.Errorf("Expected the reservation to count a second failure, got %+v, %v", counter, err)
+	}
+	if err := store.Release(context.Background(), "ip:1.2.3.4"); err != nil {
+		t.Fatalf("Failed to release: %v", err)
+	}
+	if counter, err := store.Get(context.Background(), "ip:1.2.3.4"); err != nil || counter.Failures != 1 {
+		t.Errorf("Expected the release to take back a failure, got %+v, %v", counter, err)
+	}
+	if counter, err := store.Reserve(context.Background(), "ip:9.9.9.9", 0, now, time.Minute); err != nil || counter == nil || counter.Failures != 1 {
+		t.Errorf("Expected a new key to be reserved, got %+v, %v", counter, err)
+	}
+
+	if err := store.Reset(context.Background(), "ip:1.2.3.4"); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	if counter, err := store.Get(context.Background(), "ip:1.2.3.4"); counter != nil || err != nil {
+		t.Errorf("Expected no counter after reset, got %+v, %v", counter, err)
+	}
+
+	attempt := models.LoginAttempt{Email: "ada@example.com", IP: "1.2.3.4", Reason: "invalid_credentials", Timestamp: now}
+	if err := store.Audit(context.Background(), attempt); err != nil {
+		t.Errorf("Failed to audit: %v", err)
+	}
+}
+
+func TestSQLiteStorage_ContextErrors(t *testing.T) {
+	db := newTestSQLite(t)
+	db.SetOperationTimeout(time.Minute)
+	users := NewSQLiteUserRepository(db)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	if _, err := users.GetByEmail(cancelled, "ada@example.com"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if err := users.Create(expired, &models.User{Email: "ada@example.com"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := users.GetByEmail(context.Background(), "ada@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected the timed out insert not to be stored, got %v", err)
+	}
+}
@@ -2,18 +2,26 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"log"
 	"strings"
+	"sync"
 
 	"GopherTales/internal/models"
 )
 
-// StoryService handles story-related business logic
+// StoryService handles story-related business logic. The loaded story is
+// replaced as a whole on reload, so readers take a snapshot under the lock
+// and never see a half-swapped story.
 type StoryService struct {
+	mu            sync.RWMutex
 	story         *models.Story
 	gopherStories map[string]map[string]models.Arc
+	catalog       []models.Gopher
+	validation    error // Result of validating the loaded story, see Validate
 	dataFile      string
+	staticDir     string
 }
 
 // NewStoryService creates a new story service
@@ -22,72 +30,238 @@ func NewStoryService(dataFile string) *StoryService {
 		dataFile:      dataFile,
 		story:         &models.Story{Arcs: make(map[string]models.Arc)},
 		gopherStories: make(map[string]map[string]models.Arc),
+		validation:    ErrStoryNotLoaded,
 	}
 }
 
-// LoadStory loads the story data from the JSON file
+// LoadStory loads the story data from the configured file or directory,
+// choosing a loader by file extension
 func (s *StoryService) LoadStory() error {
-	data, err := os.ReadFile(s.dataFile)
+	loader, err := LoaderForPath(s.dataFile)
 	if err != nil {
-		return fmt.Errorf("failed to read story file %s: %w", s.dataFile, err)
-	}
-
-	// Try to load as gopher-based structure first
-	var gopherData map[string]map[string]models.Arc
-	if err := json.Unmarshal(data, &gopherData); err == nil {
-		// Check if this is actually gopher data (has nested structure)
-		if s.isGopherStructure(gopherData) {
-			s.gopherStories = gopherData
-
-			// Create a default story from first gopher's intro for classic mode
-			if len(gopherData) > 0 {
-				for _, arcs := range gopherData {
-					if introArc, exists := arcs["intro"]; exists {
-						introArc.Image = s.getImageFromArc("intro")
-						s.story.Arcs = map[string]models.Arc{"intro": introArc}
-						break
-					}
+		return err
+	}
+
+	source, err := loader.Load(s.dataFile)
+	if err != nil {
+		return err
+	}
+	if err := checkSetValues(source); err != nil {
+		return err
+	}
+
+	if s.staticDir != "" {
+		var issues []models.LintIssue
+		if source.Gophers != nil {
+			for _, gopher := range sortedKeys(source.Gophers) {
+				issues = append(issues, lintAssets(s.staticDir, gopher, source.Gophers[gopher])...)
+			}
+		} else {
+			issues = lintAssets(s.staticDir, "", source.Arcs)
+		}
+		if len(issues) > 0 {
+			return &StoryValidationError{Issues: issues}
+		}
+	}
+
+	story := &models.Story{Arcs: make(map[string]models.Arc)}
+	gophers := make(map[string]map[string]models.Arc)
+
+	if source.Gophers != nil {
+		gophers = source.Gophers
+
+		// Create a default story from first gopher's intro for classic mode
+		for _, arcs := range source.Gophers {
+			if introArc, exists := arcs["intro"]; exists {
+				if introArc.Image == "" {
+					introArc.Image = s.getImageFromArc("intro")
 				}
+				story.Arcs = map[string]models.Arc{"intro": introArc}
+				break
 			}
-			return nil
 		}
+	} else {
+		// Add default images to arcs that do not declare one
+		arcs := source.Arcs
+		for name, arc := range arcs {
+			if arc.Image == "" {
+				arc.Image = s.getImageFromArc(name)
+				arcs[name] = arc
+			}
+		}
+		story.Arcs = arcs
 	}
 
-	// Load as classic structure
-	var arcs map[string]models.Arc
-	if err := json.Unmarshal(data, &arcs); err != nil {
-		return fmt.Errorf("failed to unmarshal story data: %w", err)
+	validation := s.validate(story, gophers, source.Catalog)
+
+	s.mu.Lock()
+	s.story = story
+	s.gopherStories = gophers
+	s.catalog = source.Catalog
+	s.validation = validation
+	s.mu.Unlock()
+	return nil
+}
+
+// ErrArcNotReachable is returned when a reader asks for an arc they cannot
+// get to from their current arc, such as one behind an unmet requirement
+var ErrArcNotReachable = errors.New("arc cannot be reached from the current arc")
+
+// ErrStoryNotLoaded is returned when there are no arcs to serve
+var ErrStoryNotLoaded = errors.New("no story is loaded")
+
+// StoryValidationError is returned when a story loads but has errors, such as
+// missing assets or, once checked by Validate, lint errors
+type StoryValidationError struct {
+	Issues []models.LintIssue
+}
+
+func (e *StoryValidationError) Error() string {
+	if len(e.Issues) == 1 {
+		return "story has 1 error: " + e.Issues[0].Message
+	}
+	return fmt.Sprintf("story has %d errors, first: %s", len(e.Issues), e.Issues[0].Message)
+}
+
+// Reload re-reads the story and swaps it in only if it loads and has no lint
+// errors. On failure the current story keeps being served.
+func (s *StoryService) Reload() error {
+	candidate := NewStoryService(s.dataFile)
+	candidate.staticDir = s.staticDir
+	if err := candidate.LoadStory(); err != nil {
+		return err
 	}
 
-	// Add images to arcs
-	for name, arc := range arcs {
-		arc.Image = s.getImageFromArc(name)
-		arcs[name] = arc
+	if err := candidate.Validate(); err != nil {
+		return err
 	}
 
-	s.story.Arcs = arcs
+	candidate.mu.RLock()
+	defer candidate.mu.RUnlock()
+
+	s.mu.Lock()
+	s.story = candidate.story
+	s.gopherStories = candidate.gopherStories
+	s.catalog = candidate.catalog
+	s.validation = candidate.validation
+	s.mu.Unlock()
 	return nil
 }
 
-// isGopherStructure checks if the data has the gopher-based nested structure
-func (s *StoryService) isGopherStructure(data map[string]map[string]models.Arc) bool {
-	// Check if we have color names as top-level keys
-	gopherColors := []string{"blue", "cyan", "brown", "green", "pink", "purple"}
-	for _, color := range gopherColors {
-		if _, exists := data[color]; exists {
-			return true
+// Validate reports whether the loaded story can be served, failing when no
+// arcs are loaded or the story has lint errors such as broken links. The
+// story is checked once per load, so this is cheap enough for every
+// readiness probe.
+func (s *StoryService) Validate() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.validation
+}
+
+// validate checks one snapshot of a story for Validate
+func (s *StoryService) validate(story *models.Story, gophers map[string]map[string]models.Arc, catalog []models.Gopher) error {
+	if len(gophers) == 0 && len(story.Arcs) == 0 {
+		return ErrStoryNotLoaded
+	}
+
+	var errs []models.LintIssue
+	for _, issue := range s.lint(story, gophers, catalog) {
+		if issue.Severity == LintError {
+			errs = append(errs, issue)
 		}
 	}
-	return false
+	if len(errs) > 0 {
+		return &StoryValidationError{Issues: errs}
+	}
+	return nil
+}
+
+// DataFile returns the story file or directory the service loads from
+func (s *StoryService) DataFile() string {
+	return s.dataFile
+}
+
+// snapshot returns the currently loaded story and its catalog, read
+// together so they always come from the same load. Loaded stories are never
+// modified in place, so the result is safe to read without the lock.
+func (s *StoryService) snapshot() (*models.Story, map[string]map[string]models.Arc, []models.Gopher) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.story, s.gopherStories, s.catalog
+}
+
+// StoryFileError reports where in a story file decoding failed
+type StoryFileError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *StoryFileError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+}
+
+func (e *StoryFileError) Unwrap() error {
+	return e.Err
+}
+
+// newStoryFileError wraps a JSON decoding error with its line and column.
+// Without positions (data converted from another format) only the file is recorded.
+func newStoryFileError(file string, data []byte, err error, positions bool) *StoryFileError {
+	if !positions {
+		return &StoryFileError{File: file, Err: err}
+	}
+
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return &StoryFileError{File: file, Err: err}
+	}
+
+	line, column := lineColumn(data, offset)
+	return &StoryFileError{File: file, Line: line, Column: column, Err: err}
+}
+
+// lineColumn converts the offset reported by encoding/json, which points just
+// past the offending byte, into a 1-based line and column
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if offset > 0 {
+		offset--
+	}
+
+	line, column := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
 }
 
 // GetArc retrieves an arc by name with proper error handling
 func (s *StoryService) GetArc(arcName string) (models.Arc, string, error) {
-	if len(s.story.Arcs) == 0 {
+	story, _, _ := s.snapshot()
+	if len(story.Arcs) == 0 {
 		return models.Arc{}, "", fmt.Errorf("story not loaded")
 	}
 
-	arc, finalName := s.story.GetArc(arcName)
+	arc, finalName := story.GetArc(arcName)
 	if arc.Title == "" && finalName != "" {
 		return models.Arc{}, finalName, fmt.Errorf("arc '%s' not found", arcName)
 	}
@@ -95,13 +269,19 @@ func (s *StoryService) GetArc(arcName string) (models.Arc, string, error) {
 	return arc, finalName, nil
 }
 
-// GetGopherArc retrieves an arc for a specific gopher
-func (s *StoryService) GetGopherArc(gopher, arcName string) (models.Arc, string, error) {
-	if len(s.gopherStories) == 0 {
+// GetGopherArc retrieves an arc for a specific gopher. When state is non-nil
+// the reader must be able to enter the arc from where they are, see
+// canEnter, and the arc's entry effects are applied to it. Options whose
+// requirements are not met by the resulting state are left out. A nil state
+// looks the arc up without a reader, filtering options as for one who has
+// not set any variables yet.
+func (s *StoryService) GetGopherArc(gopher, arcName string, state *models.StoryState) (models.Arc, string, error) {
+	_, gophers, catalog := s.snapshot()
+	if len(gophers) == 0 {
 		return models.Arc{}, "", fmt.Errorf("gopher stories not loaded")
 	}
 
-	gopherArcs, exists := s.gopherStories[gopher]
+	gopherArcs, exists := gophers[gopher]
 	if !exists {
 		return models.Arc{}, "", fmt.Errorf("gopher '%s' not found", gopher)
 	}
@@ -115,23 +295,116 @@ func (s *StoryService) GetGopherArc(gopher, arcName string) (models.Arc, string,
 		return models.Arc{}, arcName, fmt.Errorf("arc '%s' not found for gopher '%s'", arcName, gopher)
 	}
 
-	arc.Image = s.getImageFromGopherArc(gopher, arcName)
+	if state == nil {
+		state = models.NewStoryState()
+	} else if !s.canEnter(gopherArcs, arcName, state) {
+		return models.Arc{}, arcName, ErrArcNotReachable
+	}
+	applyArcEffects(state, arc, arcName)
+
+	arc.Options = s.availableOptions(arc.Options, state)
+	if arc.Image == "" {
+		if profile, ok := gopherProfile(gophers, catalog, gopher); ok {
+			arc.Image = profile.Portrait
+		}
+	}
 	return arc, arcName, nil
 }
 
+// canEnter reports whether a reader may open an arc: intro, the arc they
+// are on, an arc an available option of that arc leads to, or an arc that
+// can be reached from intro without meeting any requirement. The last keeps
+// bookmarks and links from other devices working without letting readers
+// skip a requirement.
+func (s *StoryService) canEnter(arcs map[string]models.Arc, arcName string, state *models.StoryState) bool {
+	if arcName == "intro" || arcName == state.LastArc {
+		return true
+	}
+	if current, exists := arcs[state.LastArc]; exists {
+		for _, option := range s.availableOptions(current.Options, state) {
+			if option.Arc == arcName {
+				return true
+			}
+		}
+	}
+	return ungatedArcs(arcs)[arcName]
+}
+
+// ungatedArcs returns the arcs reachable from intro through options without
+// requirements
+func ungatedArcs(arcs map[string]models.Arc) map[string]bool {
+	reached := map[string]bool{"intro": true}
+	queue := []string{"intro"}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, option := range arcs[name].Options {
+			if option.Requires != "" || reached[option.Arc] {
+				continue
+			}
+			if _, exists := arcs[option.Arc]; exists {
+				reached[option.Arc] = true
+				queue = append(queue, option.Arc)
+			}
+		}
+	}
+	return reached
+}
+
+// applyArcEffects updates state with the variables an arc sets on entry.
+// Entering intro starts a new playthrough, and re-entering the arc that was
+// just applied (e.g. a page refresh) does not apply its effects twice.
+func applyArcEffects(state *models.StoryState, arc models.Arc, arcName string) {
+	if state.LastArc == arcName {
+		return
+	}
+	if arcName == "intro" || state.Vars == nil {
+		state.Vars = make(map[string]any)
+	}
+
+	for name, value := range arc.Set {
+		state.Vars[name] = normalizeValue(value)
+	}
+	for name, delta := range arc.Increment {
+		current, _ := normalizeValue(state.Vars[name]).(float64)
+		state.Vars[name] = current + delta
+	}
+
+	state.LastArc = arcName
+}
+
+// availableOptions filters options down to those whose requirements hold
+func (s *StoryService) availableOptions(options []models.Option, state *models.StoryState) []models.Option {
+	available := make([]models.Option, 0, len(options))
+	for _, option := range options {
+		ok, err := EvalCondition(option.Requires, state.Vars)
+		if err != nil {
+			log.Printf("Invalid requirement %q on option to '%s': %v", option.Requires, option.Arc, err)
+			continue
+		}
+		if ok {
+			available = append(available, option)
+		}
+	}
+	return available
+}
+
 // GetStoryData returns the complete story data
 func (s *StoryService) GetStoryData() *models.Story {
-	return s.story
+	story, _, _ := s.snapshot()
+	return story
 }
 
 // ValidateArc checks if an arc name is valid
 func (s *StoryService) ValidateArc(arcName string) bool {
-	return s.story.HasArc(arcName) || arcName == "" // empty defaults to intro
+	story, _, _ := s.snapshot()
+	return story.HasArc(arcName) || arcName == "" // empty defaults to intro
 }
 
 // GetAvailableArcs returns all available arc names
 func (s *StoryService) GetAvailableArcs() []string {
-	return s.story.GetArcNames()
+	story, _, _ := s.snapshot()
+	return story.GetArcNames()
 }
 
 // getImageFromArc maps arc names to their corresponding images
@@ -140,28 +413,78 @@ func (s *StoryService) getImageFromArc(arcName string) string {
 	return "home_gopher.png"
 }
 
-// getImageFromGopherArc maps gopher colors and arc names to images
-func (s *StoryService) getImageFromGopherArc(gopher, arcName string) string {
-	// Use placeholder images for now
-	gopherImages := map[string]string{
-		"blue":   "gopher_blue.png",
-		"cyan":   "gopher_cyan.png",
-		"brown":  "gopher_brown.png",
-		"green":  "gopher_green.png",
-		"pink":   "gopher_pink.png",
-		"purple": "gopher_purple.png",
+// Defaults for gophers that have a story but no catalog entry
+const (
+	defaultPortrait   = "home_gopher.png"
+	defaultThemeColor = "#97BC62"
+)
+
+// GetCatalog returns the catalog entry for every gopher that has a story, in
+// catalog order. Gophers missing from the catalog follow in alphabetical
+// order with a generated entry.
+func (s *StoryService) GetCatalog() []models.Gopher {
+	_, gophers, entries := s.snapshot()
+
+	catalog := make([]models.Gopher, 0, len(gophers))
+	listed := make(map[string]bool, len(entries))
+	for _, profile := range entries {
+		if _, exists := gophers[profile.ID]; exists && !listed[profile.ID] {
+			listed[profile.ID] = true
+			catalog = append(catalog, withGopherDefaults(profile))
+		}
+	}
+	for _, gopher := range sortedKeys(gophers) {
+		if !listed[gopher] {
+			catalog = append(catalog, withGopherDefaults(models.Gopher{ID: gopher}))
+		}
+	}
+	return catalog
+}
+
+// GetGopher returns the catalog entry for a gopher that has a story
+func (s *StoryService) GetGopher(id string) (models.Gopher, bool) {
+	_, gophers, catalog := s.snapshot()
+	return gopherProfile(gophers, catalog, id)
+}
+
+// gopherProfile finds a gopher's catalog entry in one snapshot of the story
+func gopherProfile(gophers map[string]map[string]models.Arc, catalog []models.Gopher, id string) (models.Gopher, bool) {
+	if _, exists := gophers[id]; !exists {
+		return models.Gopher{}, false
 	}
+	for _, profile := range catalog {
+		if profile.ID == id {
+			return withGopherDefaults(profile), true
+		}
+	}
+	return withGopherDefaults(models.Gopher{ID: id}), true
+}
 
-	if image, exists := gopherImages[gopher]; exists {
-		return image
+// withGopherDefaults fills in the fields a catalog entry left empty
+func withGopherDefaults(profile models.Gopher) models.Gopher {
+	if profile.Name == "" && profile.ID != "" {
+		profile.Name = strings.ToUpper(profile.ID[:1]) + profile.ID[1:] + " Gopher"
 	}
-	return "home_gopher.png"
+	if profile.Portrait == "" {
+		profile.Portrait = defaultPortrait
+	}
+	if profile.ThemeColor == "" {
+		profile.ThemeColor = defaultThemeColor
+	}
+	return profile
+}
+
+// GetGopherArcs returns every arc for a gopher exactly as loaded
+func (s *StoryService) GetGopherArcs(gopher string) map[string]models.Arc {
+	_, gophers, _ := s.snapshot()
+	return gophers[gopher]
 }
 
 // GetAvailableGophers returns all available gopher colors
 func (s *StoryService) GetAvailableGophers() []string {
-	gophers := make([]string, 0, len(s.gopherStories))
-	for gopher := range s.gopherStories {
+	_, stories, _ := s.snapshot()
+	gophers := make([]string, 0, len(stories))
+	for gopher := range stories {
 		gophers = append(gophers, gopher)
 	}
 	return gophers
@@ -169,10 +492,11 @@ func (s *StoryService) GetAvailableGophers() []string {
 
 // GetGopherStats returns detailed statistics for each gopher with caching
 func (s *StoryService) GetGopherStats() map[string]map[string]any {
+	_, gophers, _ := s.snapshot()
 
 	stats := make(map[string]map[string]any)
 
-	for gopher, arcs := range s.gopherStories {
+	for gopher, arcs := range gophers {
 		arcCount := len(arcs)
 		totalWords := 0
 		totalOptions := 0
@@ -201,17 +525,23 @@ func (s *StoryService) GetGopherStats() map[string]map[string]any {
 	return stats
 }
 
-// ValidateStoryIntegrity checks for broken story links
+// ValidateStoryIntegrity checks for broken story links and invalid option requirements
 func (s *StoryService) ValidateStoryIntegrity() map[string][]string {
+	_, gophers, _ := s.snapshot()
 	issues := make(map[string][]string)
 
-	for gopher, arcs := range s.gopherStories {
+	for gopher, arcs := range gophers {
 		for arcName, arc := range arcs {
+			key := fmt.Sprintf("%s:%s", gopher, arcName)
 			for _, option := range arc.Options {
 				if _, exists := arcs[option.Arc]; !exists {
-					key := fmt.Sprintf("%s:%s", gopher, arcName)
 					issues[key] = append(issues[key], fmt.Sprintf("Broken link to arc '%s'", option.Arc))
 				}
+				if option.Requires != "" {
+					if _, err := ParseCondition(option.Requires); err != nil {
+						issues[key] = append(issues[key], fmt.Sprintf("Invalid requirement '%s': %v", option.Requires, err))
+					}
+				}
 			}
 		}
 	}
@@ -221,13 +551,14 @@ func (s *StoryService) ValidateStoryIntegrity() map[string][]string {
 
 // GetStoryStats returns statistics about the story
 func (s *StoryService) GetStoryStats() map[string]any {
+	story, gophers, _ := s.snapshot()
 	// Count gopher stories if available
-	if len(s.gopherStories) > 0 {
+	if len(gophers) > 0 {
 		totalArcs := 0
 		totalOptions := 0
 		totalStoryParagraphs := 0
 
-		for _, arcs := range s.gopherStories {
+		for _, arcs := range gophers {
 			totalArcs += len(arcs)
 			for _, arc := range arcs {
 				totalOptions += len(arc.Options)
@@ -240,12 +571,12 @@ func (s *StoryService) GetStoryStats() map[string]any {
 			"total_options":          totalOptions,
 			"total_story_paragraphs": totalStoryParagraphs,
 			"loaded":                 true,
-			"gopher_count":           len(s.gopherStories),
+			"gopher_count":           len(gophers),
 		}
 	}
 
 	// Fallback to classic story stats
-	if len(s.story.Arcs) == 0 {
+	if len(story.Arcs) == 0 {
 		return map[string]any{
 			"total_arcs": 0,
 			"loaded":     false,
@@ -255,16 +586,16 @@ func (s *StoryService) GetStoryStats() map[string]any {
 	totalOptions := 0
 	totalStoryParagraphs := 0
 
-	for _, arc := range s.story.Arcs {
+	for _, arc := range story.Arcs {
 		totalOptions += len(arc.Options)
 		totalStoryParagraphs += len(arc.Story)
 	}
 
 	return map[string]any{
-		"total_arcs":             len(s.story.Arcs),
+		"total_arcs":             len(story.Arcs),
 		"total_options":          totalOptions,
 		"total_story_paragraphs": totalStoryParagraphs,
 		"loaded":                 true,
-		"arcs":                   s.GetAvailableArcs(),
+		"arcs":                   story.GetArcNames(),
 	}
 }
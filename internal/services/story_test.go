@@ -1,8 +1,12 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"GopherTales/internal/models"
 )
@@ -286,3 +290,244 @@ func TestStoryService_LoadStory_InvalidJSON(t *testing.T) {
 		t.Error("Expected error when loading invalid JSON")
 	}
 }
+
+func TestStoryService_GetGopherArc_State(t *testing.T) {
+	service := NewStoryService("nonexistent.json")
+	service.gopherStories = map[string]map[string]models.Arc{
+		"purple": {
+			"intro": {
+				Title: "Intro",
+				Options: []models.Option{
+					{Text: "Find the map", Arc: "map"},
+				},
+			},
+			"map": {
+				Title:     "Map",
+				Set:       map[string]any{"has_map": true},
+				Increment: map[string]float64{"courage": 1},
+				Options: []models.Option{
+					{Text: "Secret path", Arc: "temple", Requires: "has_map == true"},
+					{Text: "Brave path", Arc: "temple", Requires: "courage >= 2"},
+					{Text: "Plain path", Arc: "temple"},
+				},
+			},
+			"temple": {Title: "Temple"},
+		},
+	}
+
+	state := models.NewStoryState()
+	state.Vars["courage"] = float64(5)
+
+	// Entering intro starts a fresh playthrough
+	if _, _, err := service.GetGopherArc("purple", "intro", state); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(state.Vars) != 0 {
+		t.Errorf("Expected intro to reset state, got %v", state.Vars)
+	}
+
+	arc, _, err := service.GetGopherArc("purple", "map", state)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if state.Vars["has_map"] != true {
+		t.Errorf("Expected has_map to be set, got %v", state.Vars["has_map"])
+	}
+	if state.Vars["courage"] != float64(1) {
+		t.Errorf("Expected courage to be 1, got %v", state.Vars["courage"])
+	}
+	if len(arc.Options) != 2 {
+		t.Errorf("Expected 2 available options, got %d", len(arc.Options))
+	}
+
+	// Re-entering the same arc must not increment twice
+	if _, _, err := service.GetGopherArc("purple", "map", state); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if state.Vars["courage"] != float64(1) {
+		t.Errorf("Expected courage to stay 1 after refresh, got %v", state.Vars["courage"])
+	}
+
+	// The underlying story must not be modified by filtering
+	if len(service.gopherStories["purple"]["map"].Options) != 3 {
+		t.Error("Expected stored arc options to be unchanged")
+	}
+
+	// A nil state behaves like a new reader, so only entry effects count
+	arc, _, err = service.GetGopherArc("purple", "map", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(arc.Options) != 2 {
+		t.Errorf("Expected 2 available options for nil state, got %d", len(arc.Options))
+	}
+}
+
+func TestStoryService_GetGopherArc_Navigation(t *testing.T) {
+	service := NewStoryService("nonexistent.json")
+	service.gopherStories = map[string]map[string]models.Arc{
+		"purple": {
+			"intro": {Title: "Intro", Options: []models.Option{
+				{Text: "Forest", Arc: "forest"},
+				{Text: "Vault", Arc: "vault", Requires: "has_key"},
+			}},
+			"forest": {Title: "Forest", Set: map[string]any{"has_key": true}, Options: []models.Option{
+				{Text: "Back", Arc: "intro"},
+			}},
+			"vault": {Title: "Vault"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		lastArc string
+		vars    map[string]any
+		arc     string
+		allowed bool
+	}{
+		{"intro is always open", "vault", nil, "intro", true},
+		{"refresh", "vault", nil, "vault", true},
+		{"arc without requirements", "", nil, "forest", true},
+		{"requirement met", "intro", map[string]any{"has_key": true}, "vault", true},
+		{"requirement not met", "intro", nil, "vault", false},
+		{"jump to gated arc", "forest", map[string]any{"has_key": true}, "vault", false},
+		{"new reader jumps to gated arc", "", nil, "vault", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := models.NewStoryState()
+			state.LastArc = tt.lastArc
+			for k, v := range tt.vars {
+				state.Vars[k] = v
+			}
+
+			_, _, err := service.GetGopherArc("purple", tt.arc, state)
+			if tt.allowed && err != nil {
+				t.Errorf("Expected %s to be allowed, got %v", tt.arc, err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrArcNotReachable) {
+				t.Errorf("Expected ErrArcNotReachable, got %v", err)
+			}
+		})
+	}
+
+	// Without a reader the arc is only looked up
+	if _, _, err := service.GetGopherArc("purple", "vault", nil); err != nil {
+		t.Errorf("Expected lookup without state to succeed, got %v", err)
+	}
+}
+
+func TestStateStores(t *testing.T) {
+	stores := map[string]StateStore{
+		"memory": NewMemoryStateStore(),
+		"sqlite": NewSQLiteStateStore(newTestSQLite(t)),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			expiresAt := time.Now().Add(time.Hour)
+
+			state, err := store.Get(ctx, "p1", "blue")
+			if err != nil || len(state.Vars) != 0 {
+				t.Errorf("Expected empty state, got %v, %v", state, err)
+			}
+
+			state.Vars["has_map"] = true
+			state.Vars["coins"] = 3.0
+			state.LastArc = "intro"
+			if err := store.Save(ctx, "p1", "blue", state, expiresAt); err != nil {
+				t.Fatalf("Failed to save state: %v", err)
+			}
+
+			// Mutating after save must not affect the stored copy
+			state.Vars["has_map"] = false
+
+			got, _ := store.Get(ctx, "p1", "blue")
+			if got.Vars["has_map"] != true || got.Vars["coins"] != 3.0 || got.LastArc != "intro" {
+				t.Errorf("Expected stored state, got %+v", got)
+			}
+			if got, _ := store.Get(ctx, "p1", "pink"); got.Vars["has_map"] != nil {
+				t.Errorf("Expected states to be isolated per gopher, got %v", got.Vars)
+			}
+
+			if err := store.Save(ctx, "p2", "blue", state, time.Now().Add(-time.Second)); err != nil {
+				t.Fatalf("Failed to save state: %v", err)
+			}
+			if got, _ := store.Get(ctx, "p2", "blue"); len(got.Vars) != 0 {
+				t.Errorf("Expected expired state to be dropped, got %v", got.Vars)
+			}
+		})
+	}
+}
+
+func TestMemoryStateStore_Limit(t *testing.T) {
+	store := NewMemoryStateStore()
+	store.limit = 2
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	for _, id := range []string{"p1", "p2", "p1", "p3"} {
+		state := models.NewStoryState()
+		state.Vars["id"] = id
+		store.Save(ctx, id, "blue", state, expiresAt)
+	}
+
+	// p2 was saved least recently
+	for id, kept := range map[string]bool{"p1": true, "p2": false, "p3": true} {
+		if got, _ := store.Get(ctx, id, "blue"); (got.Vars["id"] == id) != kept {
+			t.Errorf("Expected %s kept %v, got %v", id, kept, got.Vars)
+		}
+	}
+	if len(store.states) != 2 || store.order.Len() != 2 {
+		t.Errorf("Expected 2 playthroughs, got %d", len(store.states))
+	}
+}
+
+func TestStoryService_GetCatalog(t *testing.T) {
+	path := writeTempStory(t, `{
+		"catalog": [
+			{"id": "teal", "name": "Teal Gopher", "portrait": "gopher_teal.png", "theme_color": "#008080", "tagline": "Tide Watcher"},
+			{"id": "ghost", "name": "Ghost Gopher"},
+			{"id": "amber", "name": "Amber Gopher", "portrait": "gopher_amber.png"}
+		],
+		"amber": {"intro": {"title": "Amber", "story": [], "options": []}},
+		"teal": {"intro": {"title": "Teal", "story": [], "options": []}},
+		"zinc": {"intro": {"title": "Zinc", "story": [], "options": []}}
+	}`)
+
+	service := NewStoryService(path)
+	if err := service.LoadStory(); err != nil {
+		t.Fatalf("Failed to load story: %v", err)
+	}
+
+	catalog := service.GetCatalog()
+	var ids []string
+	for _, gopher := range catalog {
+		ids = append(ids, gopher.ID)
+	}
+	if expected := []string{"teal", "amber", "zinc"}; !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("Expected catalog order %v, got %v", expected, ids)
+	}
+
+	if catalog[0].Tagline != "Tide Watcher" || catalog[0].ThemeColor != "#008080" {
+		t.Errorf("Expected catalog fields to be kept, got %+v", catalog[0])
+	}
+	if catalog[1].ThemeColor != defaultThemeColor {
+		t.Errorf("Expected default theme colour, got '%s'", catalog[1].ThemeColor)
+	}
+	if catalog[2].Name != "Zinc Gopher" || catalog[2].Portrait != defaultPortrait {
+		t.Errorf("Expected generated entry for uncatalogued gopher, got %+v", catalog[2])
+	}
+
+	arc, _, err := service.GetGopherArc("teal", "intro", nil)
+	if err != nil {
+		t.Fatalf("Failed to get teal intro: %v", err)
+	}
+	if arc.Image != "gopher_teal.png" {
+		t.Errorf("Expected arc image from catalog portrait, got '%s'", arc.Image)
+	}
+
+	if _, ok := service.GetGopher("ghost"); ok {
+		t.Error("Expected gopher without a story to be left out")
+	}
+}
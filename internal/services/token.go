@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+// ErrInvalidToken is returned when a one-time token does not exist, has
+// expired, was already used or was issued for a different purpose
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// TokenStore persists one-time tokens by ID
+type TokenStore interface {
+	Create(ctx context.Context, token *models.OneTimeToken) error
+	// Consume marks an unused, unexpired token of the given purpose as used
+	// and returns it. It must succeed at most once per token.
+	Consume(ctx context.Context, id string, purpose models.TokenPurpose, now time.Time) (*models.OneTimeToken, error)
+	// DeleteByUser removes a user's tokens of the given purpose
+	DeleteByUser(ctx context.Context, userID primitive.ObjectID, purpose models.TokenPurpose) error
+}
+
+// MemoryTokenStore keeps one-time tokens in process memory. Tokens are
+// removed once used, and expired ones whenever a new token is created.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]models.OneTimeToken
+}
+
+// NewMemoryTokenStore creates an empty in-memory token store
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: make(map[string]models.OneTimeToken),
+	}
+}
+
+// Create stores a copy of the token, dropping tokens that have expired
+func (m *MemoryTokenStore) Create(ctx context.Context, token *models.OneTimeToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, existing := range m.tokens {
+		if !token.CreatedAt.Before(existing.ExpiresAt) {
+			delete(m.tokens, id)
+		}
+	}
+	m.tokens[token.ID] = *token
+	return nil
+}
+
+// Consume removes a token and returns it marked as used, or ErrInvalidToken
+func (m *MemoryTokenStore) Consume(ctx context.Context, id string, purpose models.TokenPurpose, now time.Time) (*models.OneTimeToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, exists := m.tokens[id]
+	if !exists || token.Purpose != purpose || token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	delete(m.tokens, id)
+	token.UsedAt = &now
+	return &token, nil
+}
+
+// DeleteByUser removes a user's tokens of the given purpose
+func (m *MemoryTokenStore) DeleteByUser(ctx context.Context, userID primitive.ObjectID, purpose models.TokenPurpose) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, token := range m.tokens {
+		if token.UserID == userID && token.Purpose == purpose {
+			delete(m.tokens, id)
+		}
+	}
+	return nil
+}
+
+// issueToken creates and stores a one-time token for a user, returning the
+// plain token to send to them
+func issueToken(ctx context.Context, store TokenStore, userID primitive.ObjectID, purpose models.TokenPurpose, now time.Time, ttl time.Duration) (string, error) {
+	plain, err := newRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := &models.OneTimeToken{
+		ID:        hashToken(plain),
+		UserID:    userID,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := store.Create(ctx, token); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
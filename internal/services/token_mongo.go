@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// MongoTokenStore keeps one-time tokens in the "tokens" collection. A TTL
+// index on expires_at lets MongoDB delete tokens once they expire.
+type MongoTokenStore struct {
+	db         *database.MongoDB
+	collection *mongo.Collection
+}
+
+// NewMongoTokenStore creates a token store on a migrated database
+func NewMongoTokenStore(db *database.MongoDB) *MongoTokenStore {
+	return &MongoTokenStore{db: db, collection: db.Database.Collection("tokens")}
+}
+
+// Create inserts a new token
+func (s *MongoTokenStore) Create(ctx context.Context, token *models.OneTimeToken) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.collection.InsertOne(ctx, token)
+	return err
+}
+
+// Consume atomically marks a token as used and returns it, or ErrInvalidToken
+func (s *MongoTokenStore) Consume(ctx context.Context, id string, purpose models.TokenPurpose, now time.Time) (*models.OneTimeToken, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":        id,
+		"purpose":    purpose,
+		"used_at":    bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"used_at": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var token models.OneTimeToken
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// DeleteByUser removes a user's tokens of the given purpose
+func (s *MongoTokenStore) DeleteByUser(ctx context.Context, userID primitive.ObjectID, purpose models.TokenPurpose) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.collection.DeleteMany(ctx, bson.M{"user_id": userID, "purpose": purpose})
+	return err
+}
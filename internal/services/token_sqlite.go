@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// SQLiteTokenStore keeps one-time tokens in the "tokens" table. Expired
+// tokens are deleted whenever a new one is created.
+type SQLiteTokenStore struct {
+	db *database.SQLite
+}
+
+// NewSQLiteTokenStore creates a token store on a migrated database
+func NewSQLiteTokenStore(db *database.SQLite) *SQLiteTokenStore {
+	return &SQLiteTokenStore{db: db}
+}
+
+// Create inserts a new token
+func (s *SQLiteTokenStore) Create(ctx context.Context, token *models.OneTimeToken) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.DB.ExecContext(ctx, `DELETE FROM tokens WHERE expires_at <= ?`, toSQLiteTime(time.Now())); err != nil {
+		return err
+	}
+
+	var usedAt sql.NullInt64
+	if token.UsedAt != nil {
+		usedAt = toSQLiteNullTime(*token.UsedAt)
+	}
+	_, err := s.db.DB.ExecContext(ctx, `INSERT INTO tokens (id, user_id, purpose, created_at, expires_at, used_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		token.ID, token.UserID.Hex(), token.Purpose, toSQLiteTime(token.CreatedAt), toSQLiteTime(token.ExpiresAt), usedAt)
+	return err
+}
+
+// Consume atomically marks a token as used and returns it, or ErrInvalidToken
+func (s *SQLiteTokenStore) Consume(ctx context.Context, id string, purpose models.TokenPurpose, now time.Time) (*models.OneTimeToken, error) {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	var (
+		userID               string
+		createdAt, expiresAt int64
+	)
+	err := s.db.DB.QueryRowContext(ctx, `UPDATE tokens SET used_at = ?
+		WHERE id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?
+		RETURNING user_id, created_at, expires_at`,
+		toSQLiteTime(now), id, purpose, toSQLiteTime(now)).Scan(&userID, &createdAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token := &models.OneTimeToken{
+		ID:        id,
+		Purpose:   purpose,
+		CreatedAt: fromSQLiteTime(createdAt),
+		ExpiresAt: fromSQLiteTime(expiresAt),
+		UsedAt:    &now,
+	}
+	if token.UserID, err = primitive.ObjectIDFromHex(userID); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// DeleteByUser removes a user's tokens of the given purpose
+func (s *SQLiteTokenStore) DeleteByUser(ctx context.Context, userID primitive.ObjectID, purpose models.TokenPurpose) error {
+	ctx, cancel := s.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.DB.ExecContext(ctx, `DELETE FROM tokens WHERE user_id = ? AND purpose = ?`, userID.Hex(), purpose)
+	return err
+}
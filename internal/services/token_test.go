@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+func TestMemoryTokenStore_Consume(t *testing.T) {
+	store := NewMemoryTokenStore()
+	userID := primitive.NewObjectID()
+	now := time.Now()
+
+	plain, err := issueToken(context.Background(), store, userID, models.TokenPasswordReset, now, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	id := hashToken(plain)
+	if _, stored := store.tokens[plain]; stored {
+		t.Error("Expected only the token hash to be stored")
+	}
+
+	if _, err := store.Consume(context.Background(), id, "other_purpose", now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token for another purpose to be rejected, got %v", err)
+	}
+
+	token, err := store.Consume(context.Background(), id, models.TokenPasswordReset, now)
+	if err != nil {
+		t.Fatalf("Expected the token to be consumed: %v", err)
+	}
+	if token.UserID != userID || token.UsedAt == nil {
+		t.Errorf("Expected a used token for the user, got %+v", token)
+	}
+
+	if _, err := store.Consume(context.Background(), id, models.TokenPasswordReset, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token to be single-use, got %v", err)
+	}
+	if len(store.tokens) != 0 {
+		t.Errorf("Expected the used token to be removed, %d left", len(store.tokens))
+	}
+}
+
+func TestMemoryTokenStore_Expiry(t *testing.T) {
+	store := NewMemoryTokenStore()
+	now := time.Now()
+
+	plain, err := issueToken(context.Background(), store, primitive.NewObjectID(), models.TokenPasswordReset, now, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+
+	if _, err := store.Consume(context.Background(), hashToken(plain), models.TokenPasswordReset, now.Add(time.Hour)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+
+	// Issuing another token drops the expired one
+	if _, err := issueToken(context.Background(), store, primitive.NewObjectID(), models.TokenPasswordReset, now.Add(time.Hour), time.Hour); err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	if _, exists := store.tokens[hashToken(plain)]; exists || len(store.tokens) != 1 {
+		t.Errorf("Expected only the new token to be kept, got %d tokens", len(store.tokens))
+	}
+}
+
+func TestMemoryTokenStore_DeleteByUser(t *testing.T) {
+	store := NewMemoryTokenStore()
+	userID := primitive.NewObjectID()
+	now := time.Now()
+
+	old, _ := issueToken(context.Background(), store, userID, models.TokenPasswordReset, now, time.Hour)
+	other, _ := issueToken(context.Background(), store, primitive.NewObjectID(), models.TokenPasswordReset, now, time.Hour)
+
+	if err := store.DeleteByUser(context.Background(), userID, models.TokenPasswordReset); err != nil {
+		t.Fatalf("Failed to delete tokens: %v", err)
+	}
+
+	if _, err := store.Consume(context.Background(), hashToken(old), models.TokenPasswordReset, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the user's token to be deleted, got %v", err)
+	}
+	if _, err := store.Consume(context.Background(), hashToken(other), models.TokenPasswordReset, now); err != nil {
+		t.Errorf("Expected other users' tokens to survive: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key from RFC 6238, "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := totpCode(rfc6238Secret, totpStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("At %d expected %s, got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := totpStep(now)
+	previous, _ := totpCode(rfc6238Secret, step-1)
+	tooOld, _ := totpCode(rfc6238Secret, step-2)
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		want     bool
+	}{
+		{"current code", "081804", 0, true},
+		{"code with spaces", " 081 804 ", 0, true},
+		{"previous step allowed for drift", previous, 0, true},
+		{"two steps old", tooOld, 0, false},
+		{"wrong code", "123456", 0, false},
+		{"wrong length", "81804", 0, false},
+		{"already used", "081804", step, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := verifyTOTP(rfc6238Secret, tt.code, now, tt.lastStep); ok != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, ok)
+			}
+		})
+	}
+}
+
+func TestOTPAuthURI(t *testing.T) {
+	uri := otpauthURI("GopherTales", "ada@example.com", "ABCDEF")
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("Failed to parse URI: %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("Expected otpauth://totp, got %s://%s", parsed.Scheme, parsed.Host)
+	}
+	if parsed.Path != "/GopherTales:ada@example.com" {
+		t.Errorf("Expected label GopherTales:ada@example.com, got %s", parsed.Path)
+	}
+	if got := parsed.Query().Get("secret"); got != "ABCDEF" {
+		t.Errorf("Expected secret ABCDEF, got %s", got)
+	}
+	if got := parsed.Query().Get("issuer"); got != "GopherTales" {
+		t.Errorf("Expected issuer GopherTales, got %s", got)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("Expected %d codes and hashes, got %d and %d", recoveryCodeCount, len(codes), len(hashes))
+	}
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if seen[code] {
+			t.Errorf("Duplicate recovery code %s", code)
+		}
+		seen[code] = true
+
+		if hashes[i] == code {
+			t.Errorf("Expected recovery code to be hashed")
+		}
+		// Users may type codes in capitals and without the dash
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+		if hashRecoveryCode(typed) != hashes[i] {
+			t.Errorf("Expected %s to match the hash of %s", typed, code)
+		}
+	}
+}
@@ -0,0 +1,547 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"GopherTales/internal/models"
+)
+
+// Twee 3 is the plain-text source format used by Twine. Passages map to arcs
+// as follows:
+//
+//	:: blue/intro [gopher:blue] {"position":"0,0","size":"100,100"}
+//	# The Call of the Sky
+//	<<set $has_map to true>>
+//	<<set $courage += 1>>
+//
+//	First paragraph.
+//
+//	Second paragraph.
+//
+//	[[Leap into a balloon basket!->blue/balloon-jump]]
+//	<<if $has_map == true>>[[Follow the map->blue/sky-temple]]<</if>>
+//
+// The gopher comes from a "gopher:<key>" tag or, failing that, a "gopher"
+// field in StoryData. A "<gopher>/" prefix on passage names and link targets
+// is stripped to give the arc name. A leading "# " line is the arc title.
+// Arc assets are tags too: "image:<file>", "background:<file>", "audio:<file>".
+
+// tweeGopherTag is the tag prefix that assigns a passage to a gopher
+const tweeGopherTag = "gopher:"
+
+// Tag prefixes for arc assets
+const (
+	tweeImageTag      = "image:"
+	tweeBackgroundTag = "background:"
+	tweeAudioTag      = "audio:"
+)
+
+var (
+	tweeLinkPattern      = regexp.MustCompile(`\[\[(.+?)\]\]`)
+	tweeConditionPattern = regexp.MustCompile(`^<<if\s+(.+?)>>\s*(\[\[.+?\]\])\s*<</if>>$`)
+	tweeSetPattern       = regexp.MustCompile(`^<<set\s+\$([A-Za-z_][\w-]*)\s*(to|=|\+=|-=)\s*(.+?)\s*>>$`)
+	tweeVariablePattern  = regexp.MustCompile(`\$([A-Za-z_])`)
+)
+
+// tweePassage is a raw passage from a Twee source file
+type tweePassage struct {
+	name string
+	tags []string
+	body []string
+	line int
+}
+
+// ParseTwee converts Twee 3 source into gopher arc maps
+func ParseTwee(data []byte) (map[string]map[string]models.Arc, error) {
+	passages, err := splitTweePassages(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var storyData struct {
+		Start  string `json:"start"`
+		Gopher string `json:"gopher"`
+	}
+	for _, p := range passages {
+		if p.name == "StoryData" {
+			if err := json.Unmarshal([]byte(strings.Join(p.body, "\n")), &storyData); err != nil {
+				return nil, fmt.Errorf("line %d: invalid StoryData: %w", p.line, err)
+			}
+		}
+	}
+
+	stories := make(map[string]map[string]models.Arc)
+	startGopher, startArc := "", ""
+
+	for _, p := range passages {
+		if isTweeSpecialPassage(p) {
+			continue
+		}
+
+		gopher := storyData.Gopher
+		for _, tag := range p.tags {
+			if strings.HasPrefix(tag, tweeGopherTag) {
+				gopher = strings.TrimPrefix(tag, tweeGopherTag)
+			}
+		}
+		if gopher == "" {
+			return nil, fmt.Errorf("line %d: passage %q has no gopher; add a %s<name> tag or a \"gopher\" field to StoryData", p.line, p.name, tweeGopherTag)
+		}
+
+		arcName := strings.TrimPrefix(p.name, gopher+"/")
+		arc, err := parseTweeBody(gopher, arcName, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range p.tags {
+			switch {
+			case strings.HasPrefix(tag, tweeImageTag):
+				arc.Image = strings.TrimPrefix(tag, tweeImageTag)
+			case strings.HasPrefix(tag, tweeBackgroundTag):
+				arc.Background = strings.TrimPrefix(tag, tweeBackgroundTag)
+			case strings.HasPrefix(tag, tweeAudioTag):
+				arc.Audio = strings.TrimPrefix(tag, tweeAudioTag)
+			}
+		}
+
+		if stories[gopher] == nil {
+			stories[gopher] = make(map[string]models.Arc)
+		}
+		if _, exists := stories[gopher][arcName]; exists {
+			return nil, fmt.Errorf("line %d: duplicate arc '%s' for gopher '%s'", p.line, arcName, gopher)
+		}
+		stories[gopher][arcName] = arc
+
+		if p.name == storyData.Start {
+			startGopher, startArc = gopher, arcName
+		}
+	}
+
+	// The StoryData start passage becomes the gopher's intro
+	if startArc != "" && startArc != "intro" {
+		if err := renameArc(stories[startGopher], startArc, "intro"); err != nil {
+			return nil, fmt.Errorf("start passage %q: %w", storyData.Start, err)
+		}
+	}
+
+	return stories, nil
+}
+
+// splitTweePassages breaks Twee source into passages on "::" header lines
+func splitTweePassages(data []byte) ([]tweePassage, error) {
+	var passages []tweePassage
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	for i, line := range lines {
+		if strings.HasPrefix(line, "::") {
+			p, err := parseTweeHeader(line[2:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			p.line = i + 1
+			passages = append(passages, p)
+			continue
+		}
+
+		if len(passages) == 0 {
+			if strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("line %d: text before the first passage header", i+1)
+			}
+			continue
+		}
+
+		// Passage text may escape a leading "::" with a backslash
+		if strings.HasPrefix(line, `\::`) {
+			line = line[1:]
+		}
+		current := &passages[len(passages)-1]
+		current.body = append(current.body, line)
+	}
+
+	return passages, nil
+}
+
+// parseTweeHeader parses `Name [tags] {metadata}` after the leading "::"
+func parseTweeHeader(header string) (tweePassage, error) {
+	var p tweePassage
+	var name strings.Builder
+
+	runes := []rune(strings.TrimSpace(header))
+	i := 0
+	for ; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\\' && i+1 < len(runes) {
+			i++
+			name.WriteRune(runes[i])
+			continue
+		}
+		if r == '[' || r == '{' {
+			break
+		}
+		name.WriteRune(r)
+	}
+	p.name = strings.TrimSpace(name.String())
+	if p.name == "" {
+		return p, fmt.Errorf("passage header has no name")
+	}
+
+	rest := strings.TrimSpace(string(runes[i:]))
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return p, fmt.Errorf("unterminated tag list in passage %q", p.name)
+		}
+		p.tags = strings.Fields(rest[1:end])
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+	if rest != "" && !strings.HasPrefix(rest, "{") {
+		return p, fmt.Errorf("unexpected %q after passage %q", rest, p.name)
+	}
+	// Metadata (position, size) has no meaning for arcs and is ignored
+
+	return p, nil
+}
+
+func isTweeSpecialPassage(p tweePassage) bool {
+	if p.name == "StoryTitle" || p.name == "StoryData" {
+		return true
+	}
+	for _, tag := range p.tags {
+		if tag == "script" || tag == "stylesheet" || tag == "Twine.private" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTweeBody turns passage text into an arc
+func parseTweeBody(gopher, arcName string, p tweePassage) (models.Arc, error) {
+	arc := models.Arc{
+		Title:   arcName,
+		Story:   []string{},
+		Options: []models.Option{},
+	}
+
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			arc.Story = append(arc.Story, strings.Join(paragraph, " "))
+			paragraph = nil
+		}
+	}
+
+	firstLine := true
+	for offset, raw := range p.body {
+		line := strings.TrimSpace(raw)
+		lineNo := p.line + offset + 1
+
+		if line == "" {
+			flush()
+			continue
+		}
+
+		if firstLine && strings.HasPrefix(line, "# ") {
+			arc.Title = strings.TrimSpace(line[2:])
+			firstLine = false
+			continue
+		}
+		firstLine = false
+
+		if m := tweeSetPattern.FindStringSubmatch(line); m != nil {
+			if err := applyTweeSet(&arc, m[1], m[2], m[3]); err != nil {
+				return arc, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			continue
+		}
+
+		if m := tweeConditionPattern.FindStringSubmatch(line); m != nil {
+			option := parseTweeLink(gopher, m[2][2:len(m[2])-2])
+			option.Requires = fromSugarCubeExpr(m[1])
+			if _, err := ParseCondition(option.Requires); err != nil {
+				return arc, fmt.Errorf("line %d: invalid condition %q: %w", lineNo, m[1], err)
+			}
+			arc.Options = append(arc.Options, option)
+			continue
+		}
+
+		// Links become options; any surrounding prose stays in the paragraph
+		text := tweeLinkPattern.ReplaceAllStringFunc(line, func(link string) string {
+			option := parseTweeLink(gopher, link[2:len(link)-2])
+			arc.Options = append(arc.Options, option)
+			return option.Text
+		})
+		if strings.TrimSpace(tweeLinkPattern.ReplaceAllString(line, "")) != "" {
+			paragraph = append(paragraph, text)
+		}
+	}
+	flush()
+
+	return arc, nil
+}
+
+// parseTweeLink handles [[Target]], [[Text->Target]], [[Target<-Text]] and [[Text|Target]]
+func parseTweeLink(gopher, link string) models.Option {
+	text, target := link, link
+	switch {
+	case strings.Contains(link, "->"):
+		i := strings.LastIndex(link, "->")
+		text, target = link[:i], link[i+2:]
+	case strings.Contains(link, "<-"):
+		i := strings.Index(link, "<-")
+		target, text = link[:i], link[i+2:]
+	case strings.Contains(link, "|"):
+		i := strings.LastIndex(link, "|")
+		text, target = link[:i], link[i+1:]
+	}
+
+	target = strings.TrimPrefix(strings.TrimSpace(target), gopher+"/")
+	return models.Option{Text: strings.TrimSpace(text), Arc: target}
+}
+
+// applyTweeSet records a <<set>> macro as an arc entry effect
+func applyTweeSet(arc *models.Arc, name, op, raw string) error {
+	value, err := parseTweeValue(raw)
+	if err != nil {
+		return err
+	}
+
+	if op == "to" || op == "=" {
+		if arc.Set == nil {
+			arc.Set = make(map[string]any)
+		}
+		arc.Set[name] = value
+		return nil
+	}
+
+	delta, ok := value.(float64)
+	if !ok {
+		return fmt.Errorf("cannot add non-number %q to $%s", raw, name)
+	}
+	if op == "-=" {
+		delta = -delta
+	}
+	if arc.Increment == nil {
+		arc.Increment = make(map[string]float64)
+	}
+	arc.Increment[name] += delta
+	return nil
+}
+
+func parseTweeValue(raw string) (any, error) {
+	switch raw {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if n, err := strconv.ParseFloat(raw, 64); err == nil {
+		return n, nil
+	}
+	if len(raw) >= 2 && (raw[0] == '"' || raw[0] == '\'') && raw[len(raw)-1] == raw[0] {
+		return raw[1 : len(raw)-1], nil
+	}
+	return nil, fmt.Errorf("unsupported value %q", raw)
+}
+
+// renameArc renames an arc and rewrites the options that point at it. It
+// fails rather than replace an existing arc with the new name.
+func renameArc(arcs map[string]models.Arc, from, to string) error {
+	if _, exists := arcs[to]; exists {
+		return fmt.Errorf("cannot rename arc '%s' to '%s', which already exists", from, to)
+	}
+
+	arc := arcs[from]
+	delete(arcs, from)
+	arcs[to] = arc
+
+	for name, a := range arcs {
+		for i, option := range a.Options {
+			if option.Arc == from {
+				a.Options[i].Arc = to
+			}
+		}
+		arcs[name] = a
+	}
+	return nil
+}
+
+// FormatTwee renders gopher arc maps as Twee 3 source that ParseTwee reads
+// back unchanged. A single gopher is recorded in StoryData; several gophers
+// are kept apart with "<gopher>/" passage prefixes and gopher tags.
+func FormatTwee(title string, stories map[string]map[string]models.Arc) []byte {
+	gophers := make([]string, 0, len(stories))
+	for gopher := range stories {
+		gophers = append(gophers, gopher)
+	}
+	sort.Strings(gophers)
+
+	single := len(gophers) == 1
+	passageName := func(gopher, arc string) string {
+		if single {
+			return arc
+		}
+		return gopher + "/" + arc
+	}
+
+	storyData := map[string]any{
+		"ifid":           newIFID(),
+		"format":         "SugarCube",
+		"format-version": "2.36.1",
+	}
+	if len(gophers) > 0 {
+		storyData["start"] = passageName(gophers[0], "intro")
+	}
+	if single {
+		storyData["gopher"] = gophers[0]
+	}
+	storyDataJSON, _ := json.MarshalIndent(storyData, "", "  ")
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, ":: StoryTitle\n%s\n\n", title)
+	fmt.Fprintf(&b, ":: StoryData\n%s\n\n", storyDataJSON)
+
+	for row, gopher := range gophers {
+		arcs := stories[gopher]
+		names := make([]string, 0, len(arcs))
+		for name := range arcs {
+			names = append(names, name)
+		}
+		sortArcsIntroFirst(names)
+
+		for col, name := range names {
+			arc := arcs[name]
+
+			header := escapeTweeName(passageName(gopher, name))
+			var tags []string
+			if !single {
+				tags = append(tags, tweeGopherTag+gopher)
+			}
+			for _, tag := range []struct{ prefix, file string }{
+				{tweeImageTag, arc.Image},
+				{tweeBackgroundTag, arc.Background},
+				{tweeAudioTag, arc.Audio},
+			} {
+				if tag.file != "" {
+					tags = append(tags, tag.prefix+tag.file)
+				}
+			}
+			if len(tags) > 0 {
+				header += " [" + strings.Join(tags, " ") + "]"
+			}
+			fmt.Fprintf(&b, ":: %s {\"position\":\"%d,%d\",\"size\":\"100,100\"}\n", header, col*150, row*150)
+			fmt.Fprintf(&b, "# %s\n", arc.Title)
+
+			for _, key := range sortedKeys(arc.Set) {
+				fmt.Fprintf(&b, "<<set $%s to %s>>\n", key, formatTweeValue(arc.Set[key]))
+			}
+			for _, key := range sortedKeys(arc.Increment) {
+				delta := arc.Increment[key]
+				if delta < 0 {
+					fmt.Fprintf(&b, "<<set $%s -= %s>>\n", key, strconv.FormatFloat(-delta, 'f', -1, 64))
+				} else {
+					fmt.Fprintf(&b, "<<set $%s += %s>>\n", key, strconv.FormatFloat(delta, 'f', -1, 64))
+				}
+			}
+
+			for _, paragraph := range arc.Story {
+				if strings.HasPrefix(paragraph, "::") {
+					paragraph = `\` + paragraph
+				}
+				fmt.Fprintf(&b, "\n%s\n", paragraph)
+			}
+
+			if len(arc.Options) > 0 {
+				b.WriteString("\n")
+			}
+			for _, option := range arc.Options {
+				link := fmt.Sprintf("[[%s->%s]]", option.Text, passageName(gopher, option.Arc))
+				if option.Requires != "" {
+					link = fmt.Sprintf("<<if %s>>%s<</if>>", toSugarCubeExpr(option.Requires), link)
+				}
+				b.WriteString(link + "\n")
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return b.Bytes()
+}
+
+// sortArcsIntroFirst sorts arc names alphabetically with intro first
+func sortArcsIntroFirst(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "intro" || names[j] == "intro" {
+			return names[i] == "intro" && names[j] != "intro"
+		}
+		return names[i] < names[j]
+	})
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatTweeValue(v any) string {
+	switch val := normalizeValue(v).(type) {
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case string:
+		return strconv.Quote(val)
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(v))
+}
+
+// escapeTweeName escapes characters with meaning in a passage header
+func escapeTweeName(name string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, `{`, `\{`, `}`, `\}`)
+	return replacer.Replace(name)
+}
+
+// toSugarCubeExpr prefixes condition variables with "$" as SugarCube expects
+func toSugarCubeExpr(expr string) string {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return expr
+	}
+
+	runes := []rune(expr)
+	var b strings.Builder
+	last := 0
+	for _, tok := range tokens {
+		if tok.kind == tokIdent && tok.text != "true" && tok.text != "false" {
+			b.WriteString(string(runes[last:tok.pos]))
+			b.WriteString("$")
+			last = tok.pos
+		}
+	}
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+// fromSugarCubeExpr strips the "$" variable prefix used by SugarCube
+func fromSugarCubeExpr(expr string) string {
+	return tweeVariablePattern.ReplaceAllString(strings.TrimSpace(expr), "$1")
+}
+
+// newIFID generates the random UUID that identifies a Twine story
+func newIFID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	buf[6] = (buf[6] & 0x0f) | 0x40
+	buf[8] = (buf[8] & 0x3f) | 0x80
+	return strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16]))
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"GopherTales/internal/models"
+)
+
+func loadGopherSix(t *testing.T) map[string]map[string]models.Arc {
+	t.Helper()
+
+	source, err := JSONLoader{}.Load("../../gopher_six.json")
+	if err != nil {
+		t.Fatalf("Failed to load gopher_six.json: %v", err)
+	}
+	return source.Gophers
+}
+
+func TestTwee_RoundTripGopherSix(t *testing.T) {
+	original := loadGopherSix(t)
+
+	twee := FormatTwee("GopherTales", original)
+	parsed, err := ParseTwee(twee)
+	if err != nil {
+		t.Fatalf("Failed to parse exported Twee: %v", err)
+	}
+
+	if !reflect.DeepEqual(parsed, original) {
+		for gopher, arcs := range original {
+			for name, arc := range arcs {
+				if !reflect.DeepEqual(parsed[gopher][name], arc) {
+					t.Errorf("Arc %s/%s differs after round trip:\n got  %+v\n want %+v", gopher, name, parsed[gopher][name], arc)
+				}
+			}
+		}
+		t.Fatal("Round trip did not preserve gopher_six.json")
+	}
+}
+
+func TestTwee_RoundTripSingleGopher(t *testing.T) {
+	original := map[string]map[string]models.Arc{
+		"purple": loadGopherSix(t)["purple"],
+	}
+	intro := original["purple"]["intro"]
+	intro.Image = "attic.png"
+	intro.Background = "night-sky.jpg"
+	intro.Audio = "audio/music-box.mp3"
+	original["purple"]["intro"] = intro
+
+	twee := FormatTwee("Purple", original)
+	if !strings.Contains(string(twee), `"gopher": "purple"`) {
+		t.Error("Expected single-gopher export to record the gopher in StoryData")
+	}
+	if strings.Contains(string(twee), "purple/") {
+		t.Error("Expected single-gopher export to use bare passage names")
+	}
+	if !strings.Contains(string(twee), "[image:attic.png background:night-sky.jpg audio:audio/music-box.mp3]") {
+		t.Error("Expected arc assets to be exported as passage tags")
+	}
+	if !strings.Contains(string(twee), "<<if $has_map == true>>") {
+		t.Error("Expected requirement to be exported as a SugarCube condition")
+	}
+
+	parsed, err := ParseTwee(twee)
+	if err != nil {
+		t.Fatalf("Failed to parse exported Twee: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, original) {
+		t.Error("Round trip did not preserve the purple story")
+	}
+}
+
+func TestParseTwee(t *testing.T) {
+	source := `:: StoryTitle
+Test Story
+
+:: StoryData
+{
+  "ifid": "D674C58C-DEFA-4F70-B7A2-27742230C0FC",
+  "start": "Beginning",
+  "gopher": "blue"
+}
+
+:: Beginning [intro-tag] {"position":"100,100","size":"100,100"}
+# The Start
+<<set $has_map to true>>
+<<set $courage += 2>>
+The sun rises
+over the hills.
+
+You may [[wander->Middle]] or stay.
+[[Go home|End]]
+[[End<-Give up]]
+<<if $courage >= 2>>[[Be brave->Middle]]<</if>>
+
+:: Middle
+[[End]]
+
+:: End [gopher:pink]
+Pink's ending.
+
+:: Styles [stylesheet]
+body { color: red; }
+`
+
+	stories, err := ParseTwee([]byte(source))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	blue := stories["blue"]
+	intro, exists := blue["intro"]
+	if !exists {
+		t.Fatalf("Expected start passage to become intro, got arcs %v", blue)
+	}
+	if intro.Title != "The Start" {
+		t.Errorf("Expected title 'The Start', got %q", intro.Title)
+	}
+	expectedStory := []string{"The sun rises over the hills.", "You may wander or stay."}
+	if !reflect.DeepEqual(intro.Story, expectedStory) {
+		t.Errorf("Unexpected story paragraphs: %q", intro.Story)
+	}
+	expectedOptions := []models.Option{
+		{Text: "wander", Arc: "Middle"},
+		{Text: "Go home", Arc: "End"},
+		{Text: "Give up", Arc: "End"},
+		{Text: "Be brave", Arc: "Middle", Requires: "courage >= 2"},
+	}
+	if !reflect.DeepEqual(intro.Options, expectedOptions) {
+		t.Errorf("Unexpected options: %+v", intro.Options)
+	}
+	if intro.Set["has_map"] != true || intro.Increment["courage"] != 2 {
+		t.Errorf("Unexpected effects: set=%v increment=%v", intro.Set, intro.Increment)
+	}
+
+	middle := blue["Middle"]
+	if middle.Title != "Middle" {
+		t.Errorf("Expected passage name as default title, got %q", middle.Title)
+	}
+	if len(middle.Options) != 1 || middle.Options[0].Text != "End" || middle.Options[0].Arc != "End" {
+		t.Errorf("Unexpected simple link: %+v", middle.Options)
+	}
+
+	if _, exists := stories["pink"]["End"]; !exists {
+		t.Error("Expected gopher tag to override StoryData gopher")
+	}
+	if len(stories) != 2 {
+		t.Errorf("Expected 2 gophers (stylesheet skipped), got %d", len(stories))
+	}
+}
+
+func TestParseTwee_Errors(t *testing.T) {
+	tests := map[string]string{
+		"no gopher":        ":: Start\nHello\n",
+		"text before":      "stray text\n:: Start [gopher:blue]\n",
+		"duplicate":        ":: intro [gopher:blue]\n:: blue/intro [gopher:blue]\n",
+		"bad condition":    ":: intro [gopher:blue]\n<<if $a ==>>[[Go->b]]<</if>>\n",
+		"bad storydata":    ":: StoryData\n{not json\n",
+		"unterminated tag": ":: intro [gopher:blue\n",
+		"start and intro":  ":: StoryData\n{\"start\": \"Begin\", \"gopher\": \"blue\"}\n:: Begin\n[[intro]]\n:: intro\nHi\n",
+	}
+
+	for name, source := range tests {
+		if _, err := ParseTwee([]byte(source)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+const (
+	// totpIssuer names the account in authenticator apps
+	totpIssuer = "GopherTales"
+	// twoFactorLoginTTL is how long a user has to enter their code after
+	// their password was accepted
+	twoFactorLoginTTL = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidTOTPCode is returned for a wrong, expired or reused code
+	ErrInvalidTOTPCode = errors.New("invalid authentication code")
+	// ErrTOTPNotEnabled is returned when a code is checked for a user without 2FA
+	ErrTOTPNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrNoTOTPEnrolment is returned when confirming without starting enrolment
+	ErrNoTOTPEnrolment = errors.New("no two-factor enrolment in progress")
+)
+
+// twoFactorUsers stores the two-factor state of accounts
+type twoFactorUsers interface {
+	GetUserByID(ctx context.Context, userID primitive.ObjectID) (*models.User, error)
+	SetPendingTOTPSecret(ctx context.Context, userID primitive.ObjectID, secret string) error
+	EnableTOTP(ctx context.Context, userID primitive.ObjectID, secret string, recoveryCodes []string, step int64) error
+	DisableTOTP(ctx context.Context, userID primitive.ObjectID) error
+	AdvanceTOTPStep(ctx context.Context, userID primitive.ObjectID, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeHash string) (bool, error)
+}
+
+// TwoFactorService manages TOTP two-factor authentication: enrolment,
+// recovery codes and the second step of logging in
+type TwoFactorService struct {
+	users  twoFactorUsers
+	tokens TokenStore
+	now    func() time.Time
+}
+
+// NewTwoFactorService creates a two-factor authentication service
+func NewTwoFactorService(users twoFactorUsers, tokens TokenStore) *TwoFactorService {
+	return &TwoFactorService{
+		users:  users,
+		tokens: tokens,
+		now:    time.Now,
+	}
+}
+
+// BeginEnrolment generates a new TOTP secret for the user and returns it
+// with its otpauth:// URI. The secret is only used once ConfirmEnrolment
+// accepts a code from it. Users who already have 2FA must give a current
+// code (or recovery code) to re-enrol.
+func (s *TwoFactorService) BeginEnrolment(ctx context.Context, user *models.User, code string) (secret, uri string, err error) {
+	if user.TOTPEnabled {
+		if err := s.Verify(ctx, user, code); err != nil {
+			return "", "", err
+		}
+	}
+
+	secret, err = generateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.users.SetPendingTOTPSecret(ctx, user.ID, secret); err != nil {
+		return "", "", err
+	}
+	user.TOTPPendingSecret = secret
+	return secret, otpauthURI(totpIssuer, user.Email, secret), nil
+}
+
+// EnrolmentQRCode renders the otpauth:// URI of the user's pending secret
+// as a PNG
+func (s *TwoFactorService) EnrolmentQRCode(user *models.User) ([]byte, error) {
+	if user.TOTPPendingSecret == "" {
+		return nil, ErrNoTOTPEnrolment
+	}
+	return qrcode.Encode(otpauthURI(totpIssuer, user.Email, user.TOTPPendingSecret), qrcode.Medium, 256)
+}
+
+// ConfirmEnrolment enables 2FA with the pending secret if code matches it,
+// and returns new recovery codes. They are only stored hashed, so this is
+// the one time they can be shown.
+func (s *TwoFactorService) ConfirmEnrolment(ctx context.Context, user *models.User, code string) ([]string, error) {
+	if user.TOTPPendingSecret == "" {
+		return nil, ErrNoTOTPEnrolment
+	}
+
+	step, ok := verifyTOTP(user.TOTPPendingSecret, code, s.now(), 0)
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.EnableTOTP(ctx, user.ID, user.TOTPPendingSecret, hashes, step); err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPSecret = user.TOTPPendingSecret
+	user.TOTPPendingSecret = ""
+	user.TOTPLastStep = step
+	user.RecoveryCodes = hashes
+	return codes, nil
+}
+
+// Disable turns off 2FA after checking a current code or recovery code
+func (s *TwoFactorService) Disable(ctx context.Context, user *models.User, code string) error {
+	if err := s.Verify(ctx, user, code); err != nil {
+		return err
+	}
+	if err := s.users.DisableTOTP(ctx, user.ID); err != nil {
+		return err
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPPendingSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	return nil
+}
+
+// Verify checks a TOTP code or a recovery code for the user. TOTP codes are
+// accepted once each; recovery codes are used up.
+func (s *TwoFactorService) Verify(ctx context.Context, user *models.User, code string) error {
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+
+	if step, ok := verifyTOTP(user.TOTPSecret, code, s.now(), user.TOTPLastStep); ok {
+		advanced, err := s.users.AdvanceTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !advanced {
+			return ErrInvalidTOTPCode
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	if code == "" {
+		return ErrInvalidTOTPCode
+	}
+	used, err := s.users.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+// BeginLogin issues a short-lived token standing for a login whose password
+// was accepted but whose second factor is still to be checked
+func (s *TwoFactorService) BeginLogin(ctx context.Context, user *models.User) (string, error) {
+	return issueToken(ctx, s.tokens, user.ID, models.TokenTwoFactorLogin, s.now(), twoFactorLoginTTL)
+}
+
+// CompleteLogin checks the code for a login started with BeginLogin and
+// returns its user. Each token allows one attempt, so a wrong code means
+// starting again with the password. The user is also returned with
+// ErrInvalidTOTPCode so the failure can be counted against the account.
+func (s *TwoFactorService) CompleteLogin(ctx context.Context, token, code string) (*models.User, error) {
+	consumed, err := s.tokens.Consume(ctx, hashToken(token), models.TokenTwoFactorLogin, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetUserByID(ctx, consumed.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Verify(ctx, user, code); err != nil {
+		return user, err
+	}
+	return user, nil
+}
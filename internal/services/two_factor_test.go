@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+// fakeTwoFactorUsers keeps one user's two-factor state in memory
+type fakeTwoFactorUsers struct {
+	user models.User
+}
+
+func (f *fakeTwoFactorUsers) GetUserByID(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	user := f.user
+	return &user, nil
+}
+
+func (f *fakeTwoFactorUsers) SetPendingTOTPSecret(ctx context.Context, userID primitive.ObjectID, secret string) error {
+	f.user.TOTPPendingSecret = secret
+	return nil
+}
+
+func (f *fakeTwoFactorUsers) EnableTOTP(ctx context.Context, userID primitive.ObjectID, secret string, recoveryCodes []string, step int64) error {
+	f.user.TOTPEnabled = true
+	f.user.TOTPSecret = secret
+	f.user.TOTPPendingSecret = ""
+	f.user.TOTPLastStep = step
+	f.user.RecoveryCodes = recoveryCodes
+	return nil
+}
+
+func (f *fakeTwoFactorUsers) DisableTOTP(ctx context.Context, userID primitive.ObjectID) error {
+	f.user.TOTPEnabled = false
+	f.user.TOTPSecret = ""
+	f.user.RecoveryCodes = nil
+	return nil
+}
+
+func (f *fakeTwoFactorUsers) AdvanceTOTPStep(ctx context.Context, userID primitive.ObjectID, step int64) (bool, error) {
+	if f.user.TOTPLastStep >= step {
+		return false, nil
+	}
+	f.user.TOTPLastStep = step
+	return true, nil
+}
+
+func (f *fakeTwoFactorUsers) UseRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeHash string) (bool, error) {
+	for i, hash := range f.user.RecoveryCodes {
+		if hash == codeHash {
+			f.user.RecoveryCodes = append(f.user.RecoveryCodes[:i], f.user.RecoveryCodes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// enrolledTwoFactor returns a service whose user has confirmed enrolment,
+// the user, their recovery codes and a clock the test can move
+func enrolledTwoFactor(t *testing.T) (*TwoFactorService, *fakeTwoFactorUsers, []string, *time.Time) {
+	t.Helper()
+
+	now := time.Unix(1700000000, 0)
+	users := &fakeTwoFactorUsers{user: models.User{ID: primitive.NewObjectID(), Email: "ada@example.com"}}
+	service := NewTwoFactorService(users, NewMemoryTokenStore())
+	service.now = func() time.Time { return now }
+
+	user, _ := users.GetUserByID(context.Background(), users.user.ID)
+	secret, _, err := service.BeginEnrolment(context.Background(), user, "")
+	if err != nil {
+		t.Fatalf("Failed to begin enrolment: %v", err)
+	}
+	code, _ := totpCode(secret, totpStep(now))
+	recoveryCodes, err := service.ConfirmEnrolment(context.Background(), user, code)
+	if err != nil {
+		t.Fatalf("Failed to confirm enrolment: %v", err)
+	}
+	return service, users, recoveryCodes, &now
+}
+
+func TestTwoFactorService_Enrolment(t *testing.T) {
+	service, users, recoveryCodes, _ := enrolledTwoFactor(t)
+
+	if !users.user.TOTPEnabled || users.user.TOTPSecret == "" || users.user.TOTPPendingSecret != "" {
+		t.Errorf("Expected 2FA to be enabled with the confirmed secret, got %+v", users.user)
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Errorf("Expected %d recovery codes, got %d", recoveryCodeCount, len(recoveryCodes))
+	}
+
+	// Confirming needs an enrolment in progress
+	user, _ := users.GetUserByID(context.Background(), users.user.ID)
+	if _, err := service.ConfirmEnrolment(context.Background(), user, "000000"); !errors.Is(err, ErrNoTOTPEnrolment) {
+		t.Errorf("Expected ErrNoTOTPEnrolment, got %v", err)
+	}
+	if _, err := service.EnrolmentQRCode(user); !errors.Is(err, ErrNoTOTPEnrolment) {
+		t.Errorf("Expected ErrNoTOTPEnrolment, got %v", err)
+	}
+
+	// Re-enrolling needs a current code
+	if _, _, err := service.BeginEnrolment(context.Background(), user, "000000"); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("Expected ErrInvalidTOTPCode, got %v", err)
+	}
+}
+
+func TestTwoFactorService_ConfirmRejectsWrongCode(t *testing.T) {
+	users := &fakeTwoFactorUsers{user: models.User{ID: primitive.NewObjectID(), Email: "ada@example.com"}}
+	service := NewTwoFactorService(users, NewMemoryTokenStore())
+
+	user, _ := users.GetUserByID(context.Background(), users.user.ID)
+	if _, _, err := service.BeginEnrolment(context.Background(), user, ""); err != nil {
+		t.Fatalf("Failed to begin enrolment: %v", err)
+	}
+
+	png, err := service.EnrolmentQRCode(user)
+	if err != nil {
+		t.Fatalf("Failed to render QR code: %v", err)
+	}
+	if len(png) < 8 || string(png[1:4]) != "PNG" {
+		t.Errorf("Expected a PNG image")
+	}
+
+	if _, err := service.ConfirmEnrolment(context.Background(), user, "not a code"); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("Expected ErrInvalidTOTPCode, got %v", err)
+	}
+	if users.user.TOTPEnabled {
+		t.Error("Expected 2FA to stay disabled after a wrong code")
+	}
+}
+
+func TestTwoFactorService_VerifyCodesOnce(t *testing.T) {
+	service, users, recoveryCodes, now := enrolledTwoFactor(t)
+
+	// The code used to confirm enrolment cannot be used again
+	user, _ := users.GetUserByID(context.Background(), users.user.ID)
+	confirmed, _ := totpCode(user.TOTPSecret, totpStep(*now))
+	if err := service.Verify(context.Background(), user, confirmed); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("Expected replayed code to fail, got %v", err)
+	}
+
+	*now = now.Add(totpPeriod * time.Second)
+	next, _ := totpCode(user.TOTPSecret, totpStep(*now))
+	if err := service.Verify(context.Background(), user, next); err != nil {
+		t.Errorf("Expected next code to pass, got %v", err)
+	}
+
+	if err := service.Verify(context.Background(), user, recoveryCodes[0]); err != nil {
+		t.Errorf("Expected recovery code to pass, got %v", err)
+	}
+	if err := service.Verify(context.Background(), user, recoveryCodes[0]); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("Expected used recovery code to fail, got %v", err)
+	}
+	if len(users.user.RecoveryCodes) != recoveryCodeCount-1 {
+		t.Errorf("Expected %d recovery codes left, got %d", recoveryCodeCount-1, len(users.user.RecoveryCodes))
+	}
+}
+
+func TestTwoFactorService_Login(t *testing.T) {
+	service, users, recoveryCodes, _ := enrolledTwoFactor(t)
+	user, _ := users.GetUserByID(context.Background(), users.user.ID)
+
+	token, err := service.BeginLogin(context.Background(), user)
+	if err != nil {
+		t.Fatalf("Failed to begin login: %v", err)
+	}
+	got, err := service.CompleteLogin(context.Background(), token, "000000")
+	if !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("Expected ErrInvalidTOTPCode, got %v", err)
+	}
+	if got == nil || got.Email != user.Email {
+		t.Errorf("Expected the user with a wrong code, got %v", got)
+	}
+
+	// The token allowed one attempt
+	if _, err := service.CompleteLogin(context.Background(), token, recoveryCodes[0]); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+
+	token, _ = service.BeginLogin(context.Background(), user)
+	if _, err := service.CompleteLogin(context.Background(), token, recoveryCodes[0]); err != nil {
+		t.Errorf("Expected login with recovery code to pass, got %v", err)
+	}
+}
+
+func TestTwoFactorService_Disable(t *testing.T) {
+	service, users, recoveryCodes, _ := enrolledTwoFactor(t)
+	user, _ := users.GetUserByID(context.Background(), users.user.ID)
+
+	if err := service.Disable(context.Background(), user, "000000"); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("Expected ErrInvalidTOTPCode, got %v", err)
+	}
+	if err := service.Disable(context.Background(), user, recoveryCodes[1]); err != nil {
+		t.Fatalf("Expected disable to pass, got %v", err)
+	}
+	if users.user.TOTPEnabled || users.user.TOTPSecret != "" {
+		t.Errorf("Expected 2FA to be off, got %+v", users.user)
+	}
+	if err := service.Verify(context.Background(), user, recoveryCodes[2]); !errors.Is(err, ErrTOTPNotEnabled) {
+		t.Errorf("Expected ErrTOTPNotEnabled, got %v", err)
+	}
+}
@@ -3,32 +3,73 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 
-	"GopherTales/internal/database"
 	"GopherTales/internal/models"
 )
 
 type UserService struct {
-	db *database.MongoDB
+	users                UserRepository
+	progress             ProgressRepository
+	bookmarks            BookmarkRepository
+	requireVerifiedEmail bool
 }
 
-func NewUserService(db *database.MongoDB) *UserService {
-	return &UserService{db: db}
+func NewUserService(users UserRepository, progress ProgressRepository, bookmarks BookmarkRepository) *UserService {
+	return &UserService{
+		users:     users,
+		progress:  progress,
+		bookmarks: bookmarks,
+	}
+}
+
+// SetRequireVerifiedEmail sets whether users must verify their email before
+// bookmarks and reading progress are saved
+func (s *UserService) SetRequireVerifiedEmail(require bool) {
+	s.requireVerifiedEmail = require
+}
+
+// CanSaveProgress reports whether bookmarks and reading progress are saved
+// for the user
+func (s *UserService) CanSaveProgress(user *models.User) bool {
+	return user.EmailVerified || !s.requireVerifiedEmail
+}
+
+var (
+	// ErrUserNotFound is returned when no account matches a lookup
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidRole is returned for a role that does not exist
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrInvalidCredentials is returned by Login for an unknown email or a
+	// wrong password
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// newEmailTakenError returns the validation error for registering an existing
+// email. Each call builds a new one so callers cannot change a shared value.
+func newEmailTakenError() *ValidationError {
+	return &ValidationError{Fields: []models.FieldError{
+		{Field: "email", Code: "taken", Message: "An account with this email already exists"},
+	}}
 }
 
-func (s *UserService) Register(name, email, password string) (*models.User, error) {
-	ctx := context.Background()
+// Register creates an account. Name and email are normalized first; invalid
+// input or an existing email returns a *ValidationError.
+func (s *UserService) Register(ctx context.Context, name, email, password string) (*models.User, error) {
+	name = NormalizeName(name)
+	email = NormalizeEmail(email)
+	if err := ValidateRegistration(name, email, password); err != nil {
+		return nil, err
+	}
 
 	// Check if user exists
-	var existing models.User
-	err := s.db.Database.Collection("users").FindOne(ctx, bson.M{"email": email}).Decode(&existing)
-	if err == nil {
-		return nil, errors.New("user already exists")
+	if _, err := s.users.GetByEmail(ctx, email); err == nil {
+		return nil, newEmailTakenError()
 	}
 
 	// Hash password
@@ -42,71 +83,174 @@ func (s *UserService) Register(name, email, password string) (*models.User, erro
 		Name:         name,
 		Email:        email,
 		PasswordHash: string(hashedPassword),
+		Role:         models.RoleReader,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
-		Progress:     make(map[string]int),
+		Progress:     make(map[string]models.GopherProgress),
 		Bookmarks:    []models.Bookmark{},
 	}
 
-	result, err := s.db.Database.Collection("users").InsertOne(ctx, user)
+	err = s.users.Create(ctx, user)
+	if errors.Is(err, ErrUserExists) {
+		return nil, newEmailTakenError()
+	}
 	if err != nil {
 		return nil, err
 	}
-
-	user.ID = result.InsertedID.(primitive.ObjectID)
 	return user, nil
 }
 
-func (s *UserService) Login(email, password string) (*models.User, error) {
-	ctx := context.Background()
-
-	var user models.User
-	err := s.db.Database.Collection("users").FindOne(ctx, bson.M{"email": email}).Decode(&user)
+func (s *UserService) Login(ctx context.Context, email, password string) (*models.User, error) {
+	user, err := s.users.GetByEmail(ctx, NormalizeEmail(email))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, err
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-func (s *UserService) UpdateProgress(userID primitive.ObjectID, gopher string, progress int) error {
-	ctx := context.Background()
-
-	update := bson.M{
-		"$set": bson.M{
-			"progress." + gopher: progress,
-			"updated_at":         time.Now(),
-		},
+// RecordVisit adds an arc to a reader's progress through a gopher's story.
+// Visited arcs and endings are kept as sets; the choice history keeps every
+// visit, trimmed to the most recent maxPathLength.
+func (s *UserService) RecordVisit(ctx context.Context, userID primitive.ObjectID, gopher, arcName string, isEnding bool) error {
+	if gopher == "" || strings.ContainsAny(gopher, ".$") {
+		return fmt.Errorf("invalid gopher '%s'", gopher)
 	}
+	return s.progress.RecordVisit(ctx, userID, gopher, arcName, isEnding, time.Now())
+}
 
-	_, err := s.db.Database.Collection("users").UpdateOne(ctx, bson.M{"_id": userID}, update)
-	return err
+func (s *UserService) AddBookmark(ctx context.Context, userID primitive.ObjectID, bookmark models.Bookmark) error {
+	return s.bookmarks.AddBookmark(ctx, userID, bookmark)
 }
 
-func (s *UserService) AddBookmark(userID primitive.ObjectID, bookmark models.Bookmark) error {
-	ctx := context.Background()
+func (s *UserService) GetUserByID(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	return s.users.GetByID(ctx, userID)
+}
 
-	update := bson.M{
-		"$push": bson.M{"bookmarks": bookmark},
-		"$set":  bson.M{"updated_at": time.Now()},
-	}
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.users.GetByEmail(ctx, NormalizeEmail(email))
+}
+
+// GetUserByIdentity returns the account linked to an external identity
+func (s *UserService) GetUserByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	return s.users.GetByIdentity(ctx, issuer, subject)
+}
 
-	_, err := s.db.Database.Collection("users").UpdateOne(ctx, bson.M{"_id": userID}, update)
-	return err
+// LinkIdentity lets an external identity sign in to an existing account
+func (s *UserService) LinkIdentity(ctx context.Context, userID primitive.ObjectID, identity models.ExternalIdentity) error {
+	return s.users.LinkIdentity(ctx, userID, identity)
 }
 
-func (s *UserService) GetUserByID(userID primitive.ObjectID) (*models.User, error) {
-	ctx := context.Background()
+// CreateExternalUser creates an account for an external identity. It has
+// no password, and its email is verified as the provider vouched for it.
+func (s *UserService) CreateExternalUser(ctx context.Context, name, email string, identity models.ExternalIdentity) (*models.User, error) {
+	now := time.Now()
+	user := &models.User{
+		Name:          name,
+		Email:         NormalizeEmail(email),
+		EmailVerified: true,
+		Role:          models.RoleReader,
+		Identities:    []models.ExternalIdentity{identity},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Progress:      make(map[string]models.GopherProgress),
+		Bookmarks:     []models.Bookmark{},
+	}
 
-	var user models.User
-	err := s.db.Database.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	err := s.users.Create(ctx, user)
+	if errors.Is(err, ErrUserExists) {
+		return nil, newEmailTakenError()
+	}
 	if err != nil {
 		return nil, err
 	}
+	return user, nil
+}
+
+// SetPassword replaces a user's password
+func (s *UserService) SetPassword(ctx context.Context, userID primitive.ObjectID, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.users.SetPasswordHash(ctx, userID, string(hashedPassword))
+}
 
-	return &user, nil
+// MarkVerificationSent records when a verification email was sent,
+// reporting false if another was sent less than interval before
+func (s *UserService) MarkVerificationSent(ctx context.Context, userID primitive.ObjectID, sentAt time.Time, interval time.Duration) (bool, error) {
+	return s.users.MarkVerificationSent(ctx, userID, sentAt, interval)
+}
+
+// MarkResetSent records when a password reset email was sent, reporting
+// false if another was sent less than interval before
+func (s *UserService) MarkResetSent(ctx context.Context, userID primitive.ObjectID, sentAt time.Time, interval time.Duration) (bool, error) {
+	return s.users.MarkResetSent(ctx, userID, sentAt, interval)
+}
+
+// MarkEmailVerified records that a user has confirmed their email address
+func (s *UserService) MarkEmailVerified(ctx context.Context, userID primitive.ObjectID) error {
+	return s.users.MarkEmailVerified(ctx, userID)
+}
+
+// SetPendingTOTPSecret stores a TOTP secret that is being enrolled. It only
+// takes effect once EnableTOTP is called.
+func (s *UserService) SetPendingTOTPSecret(ctx context.Context, userID primitive.ObjectID, secret string) error {
+	return s.users.SetPendingTOTPSecret(ctx, userID, secret)
+}
+
+// EnableTOTP turns on two-factor authentication with the given secret and
+// recovery code hashes, replacing any earlier ones
+func (s *UserService) EnableTOTP(ctx context.Context, userID primitive.ObjectID, secret string, recoveryCodes []string, step int64) error {
+	return s.users.EnableTOTP(ctx, userID, secret, recoveryCodes, step)
+}
+
+// DisableTOTP turns off two-factor authentication and forgets its secrets
+func (s *UserService) DisableTOTP(ctx context.Context, userID primitive.ObjectID) error {
+	return s.users.DisableTOTP(ctx, userID)
+}
+
+// AdvanceTOTPStep records that a TOTP code for step was used. It reports
+// false if that step or a later one was already used, so each code is
+// accepted once even across replicas.
+func (s *UserService) AdvanceTOTPStep(ctx context.Context, userID primitive.ObjectID, step int64) (bool, error) {
+	return s.users.AdvanceTOTPStep(ctx, userID, step)
+}
+
+// UseRecoveryCode removes a recovery code hash from the user, reporting
+// whether it was there
+func (s *UserService) UseRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeHash string) (bool, error) {
+	return s.users.UseRecoveryCode(ctx, userID, codeHash)
+}
+
+// SetRole changes the role of the account with this email
+func (s *UserService) SetRole(ctx context.Context, email string, role models.Role) (*models.User, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	return s.users.SetRole(ctx, NormalizeEmail(email), role)
+}
+
+// EnsureAdmins makes the accounts with these emails admins, to bootstrap the
+// first admin. Only verified emails are promoted, so nobody can claim the
+// role by registering the address first; the rest are returned as skipped.
+func (s *UserService) EnsureAdmins(ctx context.Context, emails []string) (skipped []string, err error) {
+	for _, email := range emails {
+		email = NormalizeEmail(email)
+		promoted, err := s.users.PromoteVerifiedAdmin(ctx, email)
+		if err != nil {
+			return skipped, err
+		}
+		if !promoted {
+			skipped = append(skipped, email)
+		}
+	}
+	return skipped, nil
 }
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// MongoUserRepository keeps accounts, with their progress and bookmarks, in
+// the "users" collection
+type MongoUserRepository struct {
+	db         *database.MongoDB
+	collection *mongo.Collection
+}
+
+// NewMongoUserRepository creates a user repository on a migrated database,
+// whose unique email index stops concurrent registrations from creating
+// duplicate accounts
+func NewMongoUserRepository(db *database.MongoDB) *MongoUserRepository {
+	return &MongoUserRepository{db: db, collection: db.Database.Collection("users")}
+}
+
+// Create inserts a new account and sets its ID
+func (r *MongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrUserExists
+	}
+	if err != nil {
+		return err
+	}
+
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetByID returns the account with this ID
+func (r *MongoUserRepository) GetByID(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"_id": userID})
+}
+
+// GetByEmail returns the account with this email
+func (r *MongoUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"email": email})
+}
+
+// GetByIdentity returns the account linked to an external identity
+func (r *MongoUserRepository) GetByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"identities": bson.M{"$elemMatch": bson.M{"issuer": issuer, "subject": subject}}})
+}
+
+// LinkIdentity adds an external identity to the account
+func (r *MongoUserRepository) LinkIdentity(ctx context.Context, userID primitive.ObjectID, identity models.ExternalIdentity) error {
+	err := r.updateByID(ctx, userID, bson.M{
+		"$push": bson.M{"identities": identity},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrUserExists
+	}
+	return err
+}
+
+// SetPasswordHash replaces the account's password hash
+func (r *MongoUserRepository) SetPasswordHash(ctx context.Context, userID primitive.ObjectID, hash string) error {
+	return r.updateByID(ctx, userID, bson.M{"$set": bson.M{
+		"password_hash": hash,
+		"updated_at":    time.Now(),
+	}})
+}
+
+// MarkVerificationSent records when a verification email was last sent,
+// unless one was sent within interval
+func (r *MongoUserRepository) MarkVerificationSent(ctx context.Context, userID primitive.ObjectID, sentAt time.Time, interval time.Duration) (bool, error) {
+	filter := bson.M{
+		"_id":                  userID,
+		"verification_sent_at": bson.M{"$not": bson.M{"$gt": sentAt.Add(-interval)}},
+	}
+	update := bson.M{"$set": bson.M{"verification_sent_at": sentAt}}
+
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// MarkResetSent records when a password reset email was last sent, unless
+// one was sent within interval
+func (r *MongoUserRepository) MarkResetSent(ctx context.Context, userID primitive.ObjectID, sentAt time.Time, interval time.Duration) (bool, error) {
+	filter := bson.M{
+		"_id":           userID,
+		"reset_sent_at": bson.M{"$not": bson.M{"$gt": sentAt.Add(-interval)}},
+	}
+	update := bson.M{"$set": bson.M{"reset_sent_at": sentAt}}
+
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// MarkEmailVerified records that the user confirmed their email address
+func (r *MongoUserRepository) MarkEmailVerified(ctx context.Context, userID primitive.ObjectID) error {
+	return r.updateByID(ctx, userID, bson.M{"$set": bson.M{
+		"email_verified": true,
+		"updated_at":     time.Now(),
+	}})
+}
+
+// SetRole changes the role of the account with this email
+func (r *MongoUserRepository) SetRole(ctx context.Context, email string, role models.Role) (*models.User, error) {
+	update := bson.M{"$set": bson.M{
+		"role":       role,
+		"updated_at": time.Now(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	var user models.User
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"email": email}, update, opts).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// PromoteVerifiedAdmin makes the account an admin if its email is verified
+func (r *MongoUserRepository) PromoteVerifiedAdmin(ctx context.Context, email string) (bool, error) {
+	filter := bson.M{"email": email, "email_verified": true}
+	update := bson.M{"$set": bson.M{"role": models.RoleAdmin}}
+
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// SetPendingTOTPSecret stores a TOTP secret that is being enrolled
+func (r *MongoUserRepository) SetPendingTOTPSecret(ctx context.Context, userID primitive.ObjectID, secret string) error {
+	return r.updateByID(ctx, userID, bson.M{"$set": bson.M{"totp_pending_secret": secret}})
+}
+
+// EnableTOTP turns on two-factor authentication
+func (r *MongoUserRepository) EnableTOTP(ctx context.Context, userID primitive.ObjectID, secret string, recoveryCodes []string, step int64) error {
+	return r.updateByID(ctx, userID, bson.M{
+		"$set": bson.M{
+			"totp_enabled":   true,
+			"totp_secret":    secret,
+			"totp_last_step": step,
+			"recovery_codes": recoveryCodes,
+			"updated_at":     time.Now(),
+		},
+		"$unset": bson.M{"totp_pending_secret": ""},
+	})
+}
+
+// DisableTOTP turns off two-factor authentication and forgets its secrets
+func (r *MongoUserRepository) DisableTOTP(ctx context.Context, userID primitive.ObjectID) error {
+	return r.updateByID(ctx, userID, bson.M{
+		"$set": bson.M{
+			"totp_enabled": false,
+			"updated_at":   time.Now(),
+		},
+		"$unset": bson.M{
+			"totp_secret":         "",
+			"totp_pending_secret": "",
+			"totp_last_step":      "",
+			"recovery_codes":      "",
+		},
+	})
+}
+
+// AdvanceTOTPStep records a used TOTP step with a conditional update, so
+// each code is accepted once even across replicas
+func (r *MongoUserRepository) AdvanceTOTPStep(ctx context.Context, userID primitive.ObjectID, step int64) (bool, error) {
+	filter := bson.M{
+		"_id":            userID,
+		"totp_last_step": bson.M{"$not": bson.M{"$gte": step}},
+	}
+	update := bson.M{"$set": bson.M{"totp_last_step": step}}
+
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// UseRecoveryCode removes a recovery code hash from the account
+func (r *MongoUserRepository) UseRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeHash string) (bool, error) {
+	filter := bson.M{"_id": userID, "recovery_codes": codeHash}
+	update := bson.M{
+		"$pull": bson.M{"recovery_codes": codeHash},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// RecordVisit adds an arc to the user's progress with a single update
+func (r *MongoUserRepository) RecordVisit(ctx context.Context, userID primitive.ObjectID, gopher, arcName string, isEnding bool, at time.Time) error {
+	prefix := "reading_progress." + gopher + "."
+
+	addToSet := bson.M{prefix + "visited_arcs": arcName}
+	if isEnding {
+		addToSet[prefix+"endings_reached"] = arcName
+	}
+
+	return r.updateByID(ctx, userID, bson.M{
+		"$addToSet": addToSet,
+		"$push": bson.M{
+			prefix + "path": bson.M{
+				"$each":  []models.PathStep{{Arc: arcName, Timestamp: at}},
+				"$slice": -maxPathLength,
+			},
+		},
+		"$min": bson.M{prefix + "started_at": at},
+		"$set": bson.M{
+			prefix + "updated_at": at,
+			"updated_at":          at,
+		},
+	})
+}
+
+// AddBookmark appends a bookmark to the user's bookmarks
+func (r *MongoUserRepository) AddBookmark(ctx context.Context, userID primitive.ObjectID, bookmark models.Bookmark) error {
+	return r.updateByID(ctx, userID, bson.M{
+		"$push": bson.M{"bookmarks": bookmark},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+}
+
+func (r *MongoUserRepository) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	var user models.User
+	err := r.collection.FindOne(ctx, filter).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) updateByID(ctx context.Context, userID primitive.ObjectID, update bson.M) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, update)
+	return err
+}
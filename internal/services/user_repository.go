@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+// ErrUserExists is returned by UserRepository.Create when the email or an
+// external identity already belongs to another account
+var ErrUserExists = errors.New("user already exists")
+
+// UserRepository stores accounts. Lookups return ErrUserNotFound when no
+// account matches; emails are passed in already normalized.
+type UserRepository interface {
+	// Create inserts a new account and sets its ID
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, userID primitive.ObjectID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByIdentity(ctx context.Context, issuer, subject string) (*models.User, error)
+	LinkIdentity(ctx context.Context, userID primitive.ObjectID, identity models.ExternalIdentity) error
+
+	SetPasswordHash(ctx context.Context, userID primitive.ObjectID, hash string) error
+	// MarkVerificationSent records a verification email sent at sentAt,
+	// reporting false if another was sent less than interval before
+	MarkVerificationSent(ctx context.Context, userID primitive.ObjectID, sentAt time.Time, interval time.Duration) (bool, error)
+	// MarkResetSent records a password reset email sent at sentAt, reporting
+	// false if another was sent less than interval before
+	MarkResetSent(ctx context.Context, userID primitive.ObjectID, sentAt time.Time, interval time.Duration) (bool, error)
+	MarkEmailVerified(ctx context.Context, userID primitive.ObjectID) error
+	// SetRole changes the role of the account with this email and returns it
+	SetRole(ctx context.Context, email string, role models.Role) (*models.User, error)
+	// PromoteVerifiedAdmin makes the account an admin if its email is
+	// verified, reporting whether it was
+	PromoteVerifiedAdmin(ctx context.Context, email string) (bool, error)
+
+	SetPendingTOTPSecret(ctx context.Context, userID primitive.ObjectID, secret string) error
+	EnableTOTP(ctx context.Context, userID primitive.ObjectID, secret string, recoveryCodes []string, step int64) error
+	DisableTOTP(ctx context.Context, userID primitive.ObjectID) error
+	// AdvanceTOTPStep records a used TOTP step, reporting false if that
+	// step or a later one was already used
+	AdvanceTOTPStep(ctx context.Context, userID primitive.ObjectID, step int64) (bool, error)
+	// UseRecoveryCode removes a recovery code hash, reporting whether it was there
+	UseRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeHash string) (bool, error)
+}
+
+// ProgressRepository stores readers' progress through the stories
+type ProgressRepository interface {
+	// RecordVisit adds an arc to a reader's progress through a gopher's
+	// story. Visited arcs and endings are kept as sets; the choice history
+	// keeps every visit, trimmed to the most recent maxPathLength.
+	RecordVisit(ctx context.Context, userID primitive.ObjectID, gopher, arcName string, isEnding bool, at time.Time) error
+}
+
+// BookmarkRepository stores readers' bookmarks
+type BookmarkRepository interface {
+	AddBookmark(ctx context.Context, userID primitive.ObjectID, bookmark models.Bookmark) error
+}
+
+// maxPathLength bounds the stored choice history so user documents stay small
+const maxPathLength = 500
+
+// MemoryUserRepository keeps accounts, progress and bookmarks in process
+// memory, for tests and single-replica deployments without a database
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]*models.User
+}
+
+// NewMemoryUserRepository creates an empty in-memory user repository
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users: make(map[primitive.ObjectID]*models.User),
+	}
+}
+
+// Create stores a copy of the user, giving it a new ID
+func (m *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.users {
+		if existing.Email == user.Email {
+			return ErrUserExists
+		}
+		for _, identity := range user.Identities {
+			if hasIdentity(existing, identity.Issuer, identity.Subject) {
+				return ErrUserExists
+			}
+		}
+	}
+
+	user.ID = primitive.NewObjectID()
+	m.users[user.ID] = copyUser(user)
+	return nil
+}
+
+// GetByID returns a copy of the user with this ID
+func (m *MemoryUserRepository) GetByID(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	return m.find(func(u *models.User) bool { return u.ID == userID })
+}
+
+// GetByEmail returns a copy of the user with this email
+func (m *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return m.find(func(u *models.User) bool { return u.Email == email })
+}
+
+// GetByIdentity returns a copy of the user linked to the external identity
+func (m *MemoryUserRepository) GetByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	return m.find(func(u *models.User) bool { return hasIdentity(u, issuer, subject) })
+}
+
+// LinkIdentity adds an external identity to the user
+func (m *MemoryUserRepository) LinkIdentity(ctx context.Context, userID primitive.ObjectID, identity models.ExternalIdentity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.users {
+		if hasIdentity(existing, identity.Issuer, identity.Subject) {
+			return ErrUserExists
+		}
+	}
+	return m.update(userID, func(u *models.User) {
+		u.Identities = append(u.Identities, identity)
+	})
+}
+
+// SetPasswordHash replaces the user's password hash
+func (m *MemoryUserRepository) SetPasswordHash(ctx context.Context, userID primitive.ObjectID, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(userID, func(u *models.User) { u.PasswordHash = hash })
+}
+
+// MarkVerificationSent records when a verification email was last sent,
+// unless one was sent within interval
+func (m *MemoryUserRepository) MarkVerificationSent(ctx context.Context, userID primitive.ObjectID, sentAt time.Time, interval time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, exists := m.users[userID]
+	if !exists || (!u.VerificationSentAt.IsZero() && sentAt.Sub(u.VerificationSentAt) < interval) {
+		return false, nil
+	}
+	u.VerificationSentAt = sentAt
+	return true, nil
+}
+
+// MarkResetSent records when a password reset email was last sent, unless
+// one was sent within interval
+func (m *MemoryUserRepository) MarkResetSent(ctx context.Context, userID primitive.ObjectID, sentAt time.Time, interval time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, exists := m.users[userID]
+	if !exists || (!u.ResetSentAt.IsZero() && sentAt.Sub(u.ResetSentAt) < interval) {
+		return false, nil
+	}
+	u.ResetSentAt = sentAt
+	return true, nil
+}
+
+// MarkEmailVerified records that the user confirmed their email address
+func (m *MemoryUserRepository) MarkEmailVerified(ctx context.Context, userID primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(userID, func(u *models.User) { u.EmailVerified = true })
+}
+
+// SetRole changes the role of the user with this email
+func (m *MemoryUserRepository) SetRole(ctx context.Context, email string, role models.Role) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			u.Role = role
+			u.UpdatedAt = time.Now()
+			return copyUser(u), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// PromoteVerifiedAdmin makes the user with this email an admin if their
+// email is verified
+func (m *MemoryUserRepository) PromoteVerifiedAdmin(ctx context.Context, email string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == email && u.EmailVerified {
+			u.Role = models.RoleAdmin
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetPendingTOTPSecret stores a TOTP secret that is being enrolled
+func (m *MemoryUserRepository) SetPendingTOTPSecret(ctx context.Context, userID primitive.ObjectID, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(userID, func(u *models.User) { u.TOTPPendingSecret = secret })
+}
+
+// EnableTOTP turns on two-factor authentication
+func (m *MemoryUserRepository) EnableTOTP(ctx context.Context, userID primitive.ObjectID, secret string, recoveryCodes []string, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(userID, func(u *models.User) {
+		u.TOTPEnabled = true
+		u.TOTPSecret = secret
+		u.TOTPPendingSecret = ""
+		u.TOTPLastStep = step
+		u.RecoveryCodes = append([]string(nil), recoveryCodes...)
+	})
+}
+
+// DisableTOTP turns off two-factor authentication and forgets its secrets
+func (m *MemoryUserRepository) DisableTOTP(ctx context.Context, userID primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(userID, func(u *models.User) {
+		u.TOTPEnabled = false
+		u.TOTPSecret = ""
+		u.TOTPPendingSecret = ""
+		u.TOTPLastStep = 0
+		u.RecoveryCodes = nil
+	})
+}
+
+// AdvanceTOTPStep records a used TOTP step if it is newer than the last one
+func (m *MemoryUserRepository) AdvanceTOTPStep(ctx context.Context, userID primitive.ObjectID, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, exists := m.users[userID]
+	if !exists || u.TOTPLastStep >= step {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	return true, nil
+}
+
+// UseRecoveryCode removes a recovery code hash from the user
+func (m *MemoryUserRepository) UseRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, exists := m.users[userID]
+	if !exists {
+		return false, nil
+	}
+	for i, hash := range u.RecoveryCodes {
+		if hash == codeHash {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i:i], u.RecoveryCodes[i+1:]...)
+			u.UpdatedAt = time.Now()
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RecordVisit adds an arc to the user's progress through a gopher's story
+func (m *MemoryUserRepository) RecordVisit(ctx context.Context, userID primitive.ObjectID, gopher, arcName string, isEnding bool, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(userID, func(u *models.User) {
+		if u.Progress == nil {
+			u.Progress = make(map[string]models.GopherProgress)
+		}
+		p := u.Progress[gopher]
+
+		p.VisitedArcs = appendUnique(p.VisitedArcs, arcName)
+		if isEnding {
+			p.EndingsReached = appendUnique(p.EndingsReached, arcName)
+		}
+		p.Path = append(p.Path, models.PathStep{Arc: arcName, Timestamp: at})
+		if len(p.Path) > maxPathLength {
+			p.Path = p.Path[len(p.Path)-maxPathLength:]
+		}
+		if p.StartedAt.IsZero() || at.Before(p.StartedAt) {
+			p.StartedAt = at
+		}
+		p.UpdatedAt = at
+
+		u.Progress[gopher] = p
+	})
+}
+
+// AddBookmark appends a bookmark to the user's bookmarks
+func (m *MemoryUserRepository) AddBookmark(ctx context.Context, userID primitive.ObjectID, bookmark models.Bookmark) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(userID, func(u *models.User) {
+		u.Bookmarks = append(u.Bookmarks, bookmark)
+	})
+}
+
+// find returns a copy of the first user matching the predicate
+func (m *MemoryUserRepository) find(match func(*models.User) bool) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if match(u) {
+			return copyUser(u), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// update applies a change to a stored user and bumps its UpdatedAt. Callers
+// must hold the write lock. Like a database update, an unknown ID is not an
+// error.
+func (m *MemoryUserRepository) update(userID primitive.ObjectID, change func(*models.User)) error {
+	u, exists := m.users[userID]
+	if !exists {
+		return nil
+	}
+	change(u)
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+func hasIdentity(u *models.User, issuer, subject string) bool {
+	for _, identity := range u.Identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// copyUser deep-copies a user so callers cannot change stored state
+func copyUser(u *models.User) *models.User {
+	c := *u
+	c.RecoveryCodes = append([]string(nil), u.RecoveryCodes...)
+	c.Identities = append([]models.ExternalIdentity(nil), u.Identities...)
+	c.Bookmarks = append([]models.Bookmark{}, u.Bookmarks...)
+	c.Progress = make(map[string]models.GopherProgress, len(u.Progress))
+	for gopher, p := range u.Progress {
+		p.VisitedArcs = append([]string(nil), p.VisitedArcs...)
+		p.EndingsReached = append([]string(nil), p.EndingsReached...)
+		p.Path = append([]models.PathStep(nil), p.Path...)
+		c.Progress[gopher] = p
+	}
+	return &c
+}
@@ -0,0 +1,572 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/database"
+	"GopherTales/internal/models"
+)
+
+// SQLiteUserRepository keeps accounts, progress and bookmarks in SQLite
+type SQLiteUserRepository struct {
+	db *database.SQLite
+}
+
+// NewSQLiteUserRepository creates a user repository on a migrated database
+func NewSQLiteUserRepository(db *database.SQLite) *SQLiteUserRepository {
+	return &SQLiteUserRepository{db: db}
+}
+
+// sqliteQuerier is satisfied by both *sql.DB and *sql.Tx
+type sqliteQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+const userColumns = `id, name, email, password_hash, role, email_verified, verification_sent_at, reset_sent_at,
+	totp_enabled, totp_secret, totp_pending_secret, totp_last_step, created_at, updated_at`
+
+// Create inserts a new account and sets its ID
+func (r *SQLiteUserRepository) Create(ctx context.Context, user *models.User) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id := primitive.NewObjectID()
+	_, err = tx.ExecContext(ctx, `INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(), user.Name, user.Email, user.PasswordHash, user.Role, user.EmailVerified,
+		toSQLiteNullTime(user.VerificationSentAt), toSQLiteNullTime(user.ResetSentAt), user.TOTPEnabled, user.TOTPSecret, user.TOTPPendingSecret,
+		user.TOTPLastStep, toSQLiteTime(user.CreatedAt), toSQLiteTime(user.UpdatedAt))
+	if database.IsUniqueViolation(err) {
+		return ErrUserExists
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, identity := range user.Identities {
+		if err := insertIdentity(ctx, tx, id, identity); err != nil {
+			return err
+		}
+	}
+	for _, hash := range user.RecoveryCodes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, id.Hex(), hash); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	user.ID = id
+	return nil
+}
+
+// GetByID returns the account with this ID
+func (r *SQLiteUserRepository) GetByID(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	return r.findOne(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, userID.Hex())
+}
+
+// GetByEmail returns the account with this email
+func (r *SQLiteUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	return r.findOne(ctx, `SELECT `+userColumns+` FROM users WHERE email = ?`, email)
+}
+
+// GetByIdentity returns the account linked to an external identity
+func (r *SQLiteUserRepository) GetByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	return r.findOne(ctx, `SELECT `+userColumns+` FROM users
+		WHERE id = (SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?)`, issuer, subject)
+}
+
+// LinkIdentity adds an external identity to the account
+func (r *SQLiteUserRepository) LinkIdentity(ctx context.Context, userID primitive.ObjectID, identity models.ExternalIdentity) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exists, err := r.touch(ctx, tx, userID)
+	if err != nil || !exists {
+		return err
+	}
+	if err := insertIdentity(ctx, tx, userID, identity); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetPasswordHash replaces the account's password hash
+func (r *SQLiteUserRepository) SetPasswordHash(ctx context.Context, userID primitive.ObjectID, hash string) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.DB.ExecContext(ctx, `UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?`,
+		hash, toSQLiteTime(time.Now()), userID.Hex())
+	return err
+}
+
+// MarkVerificationSent records when a verification email was last sent,
+// unless one was sent within interval
+func (r *SQLiteUserRepository) MarkVerificationSent(ctx context.Context, userID primitive.ObjectID, sentAt time.Time, interval time.Duration) (bool, error) {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.DB.ExecContext(ctx, `UPDATE users SET verification_sent_at = ?
+		WHERE id = ? AND (verification_sent_at IS NULL OR verification_sent_at <= ?)`,
+		toSQLiteTime(sentAt), userID.Hex(), toSQLiteTime(sentAt.Add(-interval)))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// MarkResetSent records when a password reset email was last sent, unless
+// one was sent within interval
+func (r *SQLiteUserRepository) MarkResetSent(ctx context.Context, userID primitive.ObjectID, sentAt time.Time, interval time.Duration) (bool, error) {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.DB.ExecContext(ctx, `UPDATE users SET reset_sent_at = ?
+		WHERE id = ? AND (reset_sent_at IS NULL OR reset_sent_at <= ?)`,
+		toSQLiteTime(sentAt), userID.Hex(), toSQLiteTime(sentAt.Add(-interval)))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// MarkEmailVerified records that the user confirmed their email address
+func (r *SQLiteUserRepository) MarkEmailVerified(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.DB.ExecContext(ctx, `UPDATE users SET email_verified = 1, updated_at = ? WHERE id = ?`,
+		toSQLiteTime(time.Now()), userID.Hex())
+	return err
+}
+
+// SetRole changes the role of the account with this email
+func (r *SQLiteUserRepository) SetRole(ctx context.Context, email string, role models.Role) (*models.User, error) {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.DB.ExecContext(ctx, `UPDATE users SET role = ?, updated_at = ? WHERE email = ?`,
+		role, toSQLiteTime(time.Now()), email)
+	if err != nil {
+		return nil, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrUserNotFound
+	}
+	return r.GetByEmail(ctx, email)
+}
+
+// PromoteVerifiedAdmin makes the account an admin if its email is verified
+func (r *SQLiteUserRepository) PromoteVerifiedAdmin(ctx context.Context, email string) (bool, error) {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.DB.ExecContext(ctx, `UPDATE users SET role = ? WHERE email = ? AND email_verified = 1`, models.RoleAdmin, email)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// SetPendingTOTPSecret stores a TOTP secret that is being enrolled
+func (r *SQLiteUserRepository) SetPendingTOTPSecret(ctx context.Context, userID primitive.ObjectID, secret string) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.DB.ExecContext(ctx, `UPDATE users SET totp_pending_secret = ? WHERE id = ?`, secret, userID.Hex())
+	return err
+}
+
+// EnableTOTP turns on two-factor authentication, replacing any earlier
+// recovery codes
+func (r *SQLiteUserRepository) EnableTOTP(ctx context.Context, userID primitive.ObjectID, secret string, recoveryCodes []string, step int64) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET totp_enabled = 1, totp_secret = ?, totp_pending_secret = '',
+		totp_last_step = ?, updated_at = ? WHERE id = ?`, secret, step, toSQLiteTime(time.Now()), userID.Hex())
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID.Hex()); err != nil {
+		return err
+	}
+	for _, hash := range recoveryCodes {
+		_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID.Hex(), hash)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP turns off two-factor authentication and forgets its secrets
+func (r *SQLiteUserRepository) DisableTOTP(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET totp_enabled = 0, totp_secret = '', totp_pending_secret = '',
+		totp_last_step = 0, updated_at = ? WHERE id = ?`, toSQLiteTime(time.Now()), userID.Hex())
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID.Hex()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AdvanceTOTPStep records a used TOTP step with a conditional update, so
+// each code is accepted once
+func (r *SQLiteUserRepository) AdvanceTOTPStep(ctx context.Context, userID primitive.ObjectID, step int64) (bool, error) {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.DB.ExecContext(ctx, `UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`,
+		step, userID.Hex(), step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// UseRecoveryCode removes a recovery code hash from the account
+func (r *SQLiteUserRepository) UseRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeHash string) (bool, error) {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.DB.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?`, userID.Hex(), codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// RecordVisit adds an arc to the user's progress in one transaction
+func (r *SQLiteUserRepository) RecordVisit(ctx context.Context, userID primitive.ObjectID, gopher, arcName string, isEnding bool, at time.Time) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exists, err := r.touch(ctx, tx, userID)
+	if err != nil || !exists {
+		return err
+	}
+
+	id, ts := userID.Hex(), toSQLiteTime(at)
+	_, err = tx.ExecContext(ctx, `INSERT INTO reading_progress (user_id, gopher, started_at, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, gopher) DO UPDATE SET
+			started_at = min(started_at, excluded.started_at),
+			updated_at = excluded.updated_at`, id, gopher, ts, ts)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO visited_arcs (user_id, gopher, arc, ending) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, gopher, arc) DO UPDATE SET ending = max(ending, excluded.ending)`,
+		id, gopher, arcName, isEnding)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO path_steps (user_id, gopher, arc, visited_at) VALUES (?, ?, ?, ?)`, id, gopher, arcName, ts)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM path_steps WHERE user_id = ? AND gopher = ? AND id NOT IN (
+		SELECT id FROM path_steps WHERE user_id = ? AND gopher = ? ORDER BY id DESC LIMIT ?)`,
+		id, gopher, id, gopher, maxPathLength)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddBookmark appends a bookmark to the user's bookmarks
+func (r *SQLiteUserRepository) AddBookmark(ctx context.Context, userID primitive.ObjectID, bookmark models.Bookmark) error {
+	ctx, cancel := r.db.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exists, err := r.touch(ctx, tx, userID)
+	if err != nil || !exists {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO bookmarks (user_id, gopher, arc, title, created_at) VALUES (?, ?, ?, ?, ?)`,
+		userID.Hex(), bookmark.Gopher, bookmark.Arc, bookmark.Title, toSQLiteTime(bookmark.Timestamp))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// touch bumps a user's updated_at, reporting whether the user exists. Like
+// a Mongo update, changes to a missing user are silently dropped.
+func (r *SQLiteUserRepository) touch(ctx context.Context, q sqliteQuerier, userID primitive.ObjectID) (bool, error) {
+	result, err := q.ExecContext(ctx, `UPDATE users SET updated_at = ? WHERE id = ?`, toSQLiteTime(time.Now()), userID.Hex())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// findOne loads the user matched by query, with everything stored alongside
+// it. It reads in a transaction so the user is a consistent snapshot.
+func (r *SQLiteUserRepository) findOne(ctx context.Context, query string, args ...interface{}) (*models.User, error) {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		user                 models.User
+		hexID                string
+		verificationSentAt   sql.NullInt64
+		resetSentAt          sql.NullInt64
+		createdAt, updatedAt int64
+	)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&hexID, &user.Name, &user.Email, &user.PasswordHash, &user.Role,
+		&user.EmailVerified, &verificationSentAt, &resetSentAt, &user.TOTPEnabled, &user.TOTPSecret, &user.TOTPPendingSecret,
+		&user.TOTPLastStep, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.ID, err = primitive.ObjectIDFromHex(hexID); err != nil {
+		return nil, err
+	}
+	user.VerificationSentAt = fromSQLiteNullTime(verificationSentAt)
+	user.ResetSentAt = fromSQLiteNullTime(resetSentAt)
+	user.CreatedAt = fromSQLiteTime(createdAt)
+	user.UpdatedAt = fromSQLiteTime(updatedAt)
+
+	if err := loadIdentities(ctx, tx, &user); err != nil {
+		return nil, err
+	}
+	if err := loadRecoveryCodes(ctx, tx, &user); err != nil {
+		return nil, err
+	}
+	if err := loadProgress(ctx, tx, &user); err != nil {
+		return nil, err
+	}
+	if err := loadBookmarks(ctx, tx, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func insertIdentity(ctx context.Context, q sqliteQuerier, userID primitive.ObjectID, identity models.ExternalIdentity) error {
+	_, err := q.ExecContext(ctx, `INSERT INTO user_identities (issuer, subject, user_id, linked_at) VALUES (?, ?, ?, ?)`,
+		identity.Issuer, identity.Subject, userID.Hex(), toSQLiteTime(identity.LinkedAt))
+	if database.IsUniqueViolation(err) {
+		return ErrUserExists
+	}
+	return err
+}
+
+func loadIdentities(ctx context.Context, q sqliteQuerier, user *models.User) error {
+	rows, err := q.QueryContext(ctx, `SELECT issuer, subject, linked_at FROM user_identities WHERE user_id = ? ORDER BY linked_at`, user.ID.Hex())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var identity models.ExternalIdentity
+		var linkedAt int64
+		if err := rows.Scan(&identity.Issuer, &identity.Subject, &linkedAt); err != nil {
+			return err
+		}
+		identity.LinkedAt = fromSQLiteTime(linkedAt)
+		user.Identities = append(user.Identities, identity)
+	}
+	return rows.Err()
+}
+
+func loadRecoveryCodes(ctx context.Context, q sqliteQuerier, user *models.User) error {
+	rows, err := q.QueryContext(ctx, `SELECT code_hash FROM recovery_codes WHERE user_id = ?`, user.ID.Hex())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return err
+		}
+		user.RecoveryCodes = append(user.RecoveryCodes, hash)
+	}
+	return rows.Err()
+}
+
+func loadProgress(ctx context.Context, q sqliteQuerier, user *models.User) error {
+	user.Progress = make(map[string]models.GopherProgress)
+
+	rows, err := q.QueryContext(ctx, `SELECT gopher, started_at, updated_at FROM reading_progress WHERE user_id = ?`, user.ID.Hex())
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var gopher string
+		var startedAt, updatedAt int64
+		if err := rows.Scan(&gopher, &startedAt, &updatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		user.Progress[gopher] = models.GopherProgress{
+			StartedAt: fromSQLiteTime(startedAt),
+			UpdatedAt: fromSQLiteTime(updatedAt),
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = q.QueryContext(ctx, `SELECT gopher, arc, ending FROM visited_arcs WHERE user_id = ? ORDER BY rowid`, user.ID.Hex())
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var gopher, arc string
+		var ending bool
+		if err := rows.Scan(&gopher, &arc, &ending); err != nil {
+			rows.Close()
+			return err
+		}
+		p := user.Progress[gopher]
+		p.VisitedArcs = append(p.VisitedArcs, arc)
+		if ending {
+			p.EndingsReached = append(p.EndingsReached, arc)
+		}
+		user.Progress[gopher] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = q.QueryContext(ctx, `SELECT gopher, arc, visited_at FROM path_steps WHERE user_id = ? ORDER BY id`, user.ID.Hex())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var gopher, arc string
+		var visitedAt int64
+		if err := rows.Scan(&gopher, &arc, &visitedAt); err != nil {
+			return err
+		}
+		p := user.Progress[gopher]
+		p.Path = append(p.Path, models.PathStep{Arc: arc, Timestamp: fromSQLiteTime(visitedAt)})
+		user.Progress[gopher] = p
+	}
+	return rows.Err()
+}
+
+func loadBookmarks(ctx context.Context, q sqliteQuerier, user *models.User) error {
+	user.Bookmarks = []models.Bookmark{}
+
+	rows, err := q.QueryContext(ctx, `SELECT gopher, arc, title, created_at FROM bookmarks WHERE user_id = ? ORDER BY id`, user.ID.Hex())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookmark models.Bookmark
+		var createdAt int64
+		if err := rows.Scan(&bookmark.Gopher, &bookmark.Arc, &bookmark.Title, &createdAt); err != nil {
+			return err
+		}
+		bookmark.Timestamp = fromSQLiteTime(createdAt)
+		user.Bookmarks = append(user.Bookmarks, bookmark)
+	}
+	return rows.Err()
+}
+
+// toSQLiteTime converts a time to the Unix milliseconds stored in SQLite
+func toSQLiteTime(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// fromSQLiteTime converts stored Unix milliseconds back to a UTC time, as
+// the Mongo driver decodes them
+func fromSQLiteTime(ms int64) time.Time {
+	return time.UnixMilli(ms).UTC()
+}
+
+// toSQLiteNullTime stores the zero time as NULL, for optional times
+func toSQLiteNullTime(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.UnixMilli(), Valid: !t.IsZero()}
+}
+
+func fromSQLiteNullTime(ms sql.NullInt64) time.Time {
+	if !ms.Valid {
+		return time.Time{}
+	}
+	return fromSQLiteTime(ms.Int64)
+}
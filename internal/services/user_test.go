@@ -1,12 +1,282 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
 )
 
-// Simple test functions without complex mocking
-func TestUserService_Basic(t *testing.T) {
-	// Test basic user service functionality
-	t.Log("User service tests would require database setup")
-	t.Log("These tests should be run with integration test setup")
+// testUserRepository stores accounts, progress and bookmarks together, as
+// every backend does
+type testUserRepository interface {
+	UserRepository
+	ProgressRepository
+	BookmarkRepository
+}
+
+// forEachUserRepository runs a test against a UserService on each storage
+// backend that needs no server
+func forEachUserRepository(t *testing.T, test func(t *testing.T, service *UserService)) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) testUserRepository
+	}{
+		{"memory", func(t *testing.T) testUserRepository { return NewMemoryUserRepository() }},
+		{"sqlite", func(t *testing.T) testUserRepository { return NewSQLiteUserRepository(newTestSQLite(t)) }},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			users := backend.open(t)
+			test(t, NewUserService(users, users, users))
+		})
+	}
+}
+
+func TestUserService_RegisterAndLogin(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, service *UserService) {
+		user, err := service.Register(context.Background(), "  Ada  Lovelace ", " Ada@Example.com", "gopher123")
+		if err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+		if user.ID.IsZero() || user.Name != "Ada Lovelace" || user.Email != "ada@example.com" || user.Role != models.RoleReader {
+			t.Errorf("Unexpected new user %+v", user)
+		}
+
+		var validation *ValidationError
+		if _, err := service.Register(context.Background(), "Ada", "ADA@example.com", "gopher123"); !errors.As(err, &validation) || validation.Fields[0].Code != "taken" {
+			t.Errorf("Expected duplicate email to be taken, got %v", err)
+		}
+		if _, err := service.Register(context.Background(), "A", "a@example.com", "go"); !errors.As(err, &validation) {
+			t.Errorf("Expected invalid input to fail validation, got %v", err)
+		}
+
+		loggedIn, err := service.Login(context.Background(), "ADA@example.com ", "gopher123")
+		if err != nil {
+			t.Fatalf("Failed to log in: %v", err)
+		}
+		if loggedIn.ID != user.ID {
+			t.Errorf("Expected user %s, got %s", user.ID.Hex(), loggedIn.ID.Hex())
+		}
+		if _, err := service.Login(context.Background(), "ada@example.com", "wrong123"); err == nil {
+			t.Errorf("Expected wrong password to fail")
+		}
+		if _, err := service.Login(context.Background(), "nobody@example.com", "gopher123"); err == nil {
+			t.Errorf("Expected unknown email to fail")
+		}
+
+		if err := service.SetPassword(context.Background(), user.ID, "newpass456"); err != nil {
+			t.Fatalf("Failed to set password: %v", err)
+		}
+		if _, err := service.Login(context.Background(), "ada@example.com", "newpass456"); err != nil {
+			t.Errorf("Expected new password to work, got %v", err)
+		}
+	})
+}
+
+func TestUserService_ConcurrentRegistration(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, service *UserService) {
+		var wg sync.WaitGroup
+		results := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := service.Register(context.Background(), "Ada", "ada@example.com", "gopher123")
+				results <- err
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		created := 0
+		for err := range results {
+			if err == nil {
+				created++
+			}
+		}
+		if created != 1 {
+			t.Errorf("Expected exactly one account, got %d", created)
+		}
+	})
+}
+
+func TestUserService_RecordVisit(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, service *UserService) {
+		user, err := service.Register(context.Background(), "Ada", "ada@example.com", "gopher123")
+		if err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+
+		for _, arc := range []string{"intro", "forest", "intro", "home"} {
+			if err := service.RecordVisit(context.Background(), user.ID, "gopher1", arc, arc == "home"); err != nil {
+				t.Fatalf("Failed to record visit to %s: %v", arc, err)
+			}
+		}
+		if err := service.RecordVisit(context.Background(), user.ID, "bad.gopher", "intro", false); err == nil {
+			t.Errorf("Expected gopher with a dot to be rejected")
+		}
+
+		user, err = service.GetUserByID(context.Background(), user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		progress := user.Progress["gopher1"]
+		if fmt.Sprint(progress.VisitedArcs) != "[intro forest home]" {
+			t.Errorf("Expected visited arcs [intro forest home], got %v", progress.VisitedArcs)
+		}
+		if fmt.Sprint(progress.EndingsReached) != "[home]" {
+			t.Errorf("Expected endings [home], got %v", progress.EndingsReached)
+		}
+		if len(progress.Path) != 4 || progress.LastArc() != "home" {
+			t.Errorf("Expected a path of 4 ending at home, got %+v", progress.Path)
+		}
+		if progress.StartedAt.After(progress.UpdatedAt) {
+			t.Errorf("Expected started_at before updated_at")
+		}
+
+		// Only the most recent visits are kept
+		for i := 0; i < maxPathLength; i++ {
+			if err := service.RecordVisit(context.Background(), user.ID, "gopher1", fmt.Sprintf("arc%d", i), false); err != nil {
+				t.Fatalf("Failed to record visit: %v", err)
+			}
+		}
+		user, _ = service.GetUserByID(context.Background(), user.ID)
+		path := user.Progress["gopher1"].Path
+		if len(path) != maxPathLength || path[0].Arc != "arc0" {
+			t.Errorf("Expected path trimmed to %d starting at arc0, got %d starting at %s", maxPathLength, len(path), path[0].Arc)
+		}
+	})
+}
+
+func TestUserService_ReturnsCopies(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, service *UserService) {
+		user, err := service.Register(context.Background(), "Ada", "ada@example.com", "gopher123")
+		if err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+		if err := service.AddBookmark(context.Background(), user.ID, models.Bookmark{Gopher: "gopher1", Arc: "intro"}); err != nil {
+			t.Fatalf("Failed to add bookmark: %v", err)
+		}
+
+		found, _ := service.GetUserByID(context.Background(), user.ID)
+		found.Bookmarks[0].Arc = "changed"
+		found.Role = models.RoleAdmin
+
+		again, _ := service.GetUserByID(context.Background(), user.ID)
+		if len(again.Bookmarks) != 1 || again.Bookmarks[0].Arc != "intro" || again.Role != models.RoleReader {
+			t.Errorf("Expected stored user to be unchanged, got %+v", again)
+		}
+	})
+}
+
+func TestUserService_Roles(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, service *UserService) {
+		verified, _ := service.Register(context.Background(), "Ada", "ada@example.com", "gopher123")
+		if _, err := service.Register(context.Background(), "Grace", "grace@example.com", "gopher123"); err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+		if err := service.MarkEmailVerified(context.Background(), verified.ID); err != nil {
+			t.Fatalf("Failed to verify email: %v", err)
+		}
+
+		skipped, err := service.EnsureAdmins(context.Background(), []string{"ADA@example.com", "grace@example.com", "nobody@example.com"})
+		if err != nil {
+			t.Fatalf("Failed to promote admins: %v", err)
+		}
+		if fmt.Sprint(skipped) != "[grace@example.com nobody@example.com]" {
+			t.Errorf("Expected unverified and unknown emails skipped, got %v", skipped)
+		}
+		if user, _ := service.GetUserByEmail(context.Background(), "ada@example.com"); user.Role != models.RoleAdmin {
+			t.Errorf("Expected verified account promoted, got %s", user.Role)
+		}
+
+		user, err := service.SetRole(context.Background(), "Grace@example.com", models.RoleAuthor)
+		if err != nil || user.Role != models.RoleAuthor {
+			t.Errorf("Expected grace to be an author, got %v, %v", user, err)
+		}
+		if _, err := service.SetRole(context.Background(), "grace@example.com", "owner"); !errors.Is(err, ErrInvalidRole) {
+			t.Errorf("Expected ErrInvalidRole, got %v", err)
+		}
+		if _, err := service.SetRole(context.Background(), "nobody@example.com", models.RoleAuthor); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserService_ExternalIdentities(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, service *UserService) {
+		identity := models.ExternalIdentity{Issuer: "https://idp.example.com", Subject: "user-123"}
+
+		user, err := service.CreateExternalUser(context.Background(), "Ada", "Ada@example.com", identity)
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		if !user.EmailVerified || user.PasswordHash != "" {
+			t.Errorf("Expected a verified account without a password, got %+v", user)
+		}
+
+		found, err := service.GetUserByIdentity(context.Background(), identity.Issuer, identity.Subject)
+		if err != nil || found.ID != user.ID {
+			t.Errorf("Expected to find user by identity, got %v, %v", found, err)
+		}
+		if _, err := service.GetUserByIdentity(context.Background(), identity.Issuer, "someone-else"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+
+		other, _ := service.Register(context.Background(), "Grace", "grace@example.com", "gopher123")
+		if err := service.LinkIdentity(context.Background(), other.ID, identity); !errors.Is(err, ErrUserExists) {
+			t.Errorf("Expected identity already linked to fail, got %v", err)
+		}
+	})
+}
+
+func TestUserService_TwoFactorState(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, service *UserService) {
+		user, _ := service.Register(context.Background(), "Ada", "ada@example.com", "gopher123")
+
+		if err := service.EnableTOTP(context.Background(), user.ID, "secret", []string{"hash1", "hash2"}, 10); err != nil {
+			t.Fatalf("Failed to enable TOTP: %v", err)
+		}
+
+		tests := []struct {
+			step int64
+			want bool
+		}{
+			{step: 10, want: false},
+			{step: 9, want: false},
+			{step: 11, want: true},
+			{step: 11, want: false},
+		}
+		for _, tt := range tests {
+			got, err := service.AdvanceTOTPStep(context.Background(), user.ID, tt.step)
+			if err != nil || got != tt.want {
+				t.Errorf("AdvanceTOTPStep(%d): expected %v, got %v, %v", tt.step, tt.want, got, err)
+			}
+		}
+
+		if used, _ := service.UseRecoveryCode(context.Background(), user.ID, "hash1"); !used {
+			t.Errorf("Expected recovery code to be accepted")
+		}
+		if used, _ := service.UseRecoveryCode(context.Background(), user.ID, "hash1"); used {
+			t.Errorf("Expected recovery code to be accepted only once")
+		}
+		if used, _ := service.UseRecoveryCode(context.Background(), primitive.NewObjectID(), "hash2"); used {
+			t.Errorf("Expected recovery code of another user to be rejected")
+		}
+
+		if err := service.DisableTOTP(context.Background(), user.ID); err != nil {
+			t.Fatalf("Failed to disable TOTP: %v", err)
+		}
+		user, _ = service.GetUserByID(context.Background(), user.ID)
+		if user.TOTPEnabled || user.TOTPSecret != "" || len(user.RecoveryCodes) != 0 {
+			t.Errorf("Expected two-factor state cleared, got %+v", user)
+		}
+	})
 }
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"GopherTales/internal/models"
+)
+
+const (
+	minNameLength     = 2
+	maxNameLength     = 64
+	maxEmailLength    = 254 // RFC 5321 path limit
+	minPasswordLength = 8
+	maxPasswordBytes  = 72 // bcrypt ignores anything longer
+)
+
+// ValidationError is returned when user input is rejected. Fields lists
+// every problem found, not just the first.
+type ValidationError struct {
+	Fields []models.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Fields) == 1 {
+		return e.Fields[0].Message
+	}
+	return fmt.Sprintf("%d fields are invalid, first: %s", len(e.Fields), e.Fields[0].Message)
+}
+
+// fieldErrors collects field errors and returns them as a ValidationError
+type fieldErrors []models.FieldError
+
+func (f *fieldErrors) add(field, code, message string) {
+	*f = append(*f, models.FieldError{Field: field, Code: code, Message: message})
+}
+
+// err returns nil if no errors were added
+func (f fieldErrors) err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: f}
+}
+
+// NormalizeEmail trims and lowercases an email address so lookups and the
+// unique index treat A@x.com and a@x.com as the same account
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizeName trims a display name and collapses runs of whitespace
+func NormalizeName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// ValidateRegistration checks normalized registration input
+func ValidateRegistration(name, email, password string) error {
+	var errs fieldErrors
+	validateName(&errs, name)
+	validateEmail(&errs, email)
+	validatePassword(&errs, "password", password)
+	return errs.err()
+}
+
+// ValidatePassword checks a new password against the password policy
+func ValidatePassword(password string) error {
+	var errs fieldErrors
+	validatePassword(&errs, "password", password)
+	return errs.err()
+}
+
+func validateName(errs *fieldErrors, name string) {
+	length := utf8.RuneCountInString(name)
+	switch {
+	case length == 0:
+		errs.add("name", "required", "Name is required")
+	case length < minNameLength:
+		errs.add("name", "too_short", fmt.Sprintf("Name must be at least %d characters", minNameLength))
+	case length > maxNameLength:
+		errs.add("name", "too_long", fmt.Sprintf("Name must be at most %d characters", maxNameLength))
+	}
+}
+
+func validateEmail(errs *fieldErrors, email string) {
+	if email == "" {
+		errs.add("email", "required", "Email is required")
+		return
+	}
+	if len(email) > maxEmailLength {
+		errs.add("email", "too_long", fmt.Sprintf("Email must be at most %d characters", maxEmailLength))
+		return
+	}
+
+	// ParseAddress also accepts "Name <addr>", so require the bare address
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		errs.add("email", "invalid", "Email address is not valid")
+		return
+	}
+	if _, domain, _ := strings.Cut(email, "@"); !strings.Contains(domain, ".") {
+		errs.add("email", "invalid", "Email address must include a domain such as example.com")
+	}
+}
+
+// validatePassword enforces the password policy: 8 to 72 bytes with at
+// least one letter and one digit or symbol
+func validatePassword(errs *fieldErrors, field, password string) {
+	if password == "" {
+		errs.add(field, "required", "Password is required")
+		return
+	}
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		errs.add(field, "too_short", fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
+		return
+	}
+	if len(password) > maxPasswordBytes {
+		errs.add(field, "too_long", fmt.Sprintf("Password must be at most %d bytes", maxPasswordBytes))
+		return
+	}
+
+	var hasLetter, hasOther bool
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		} else if !unicode.IsSpace(r) {
+			hasOther = true
+		}
+	}
+	if !hasLetter || !hasOther {
+		errs.add(field, "weak", "Password must contain a letter and a number or symbol")
+	}
+}
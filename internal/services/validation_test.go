@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	if got := NormalizeEmail("  Ada.Lovelace@Example.COM \n"); got != "ada.lovelace@example.com" {
+		t.Errorf("Expected 'ada.lovelace@example.com', got '%s'", got)
+	}
+	if got := NormalizeName("  Ada   \t Lovelace "); got != "Ada Lovelace" {
+		t.Errorf("Expected 'Ada Lovelace', got '%s'", got)
+	}
+}
+
+func TestValidateRegistration(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		email    string
+		password string
+		want     []string // "field:code" for each expected error
+	}{
+		{name: "valid", user: "Ada", email: "ada@example.com", password: "gopher123"},
+		{name: "valid unicode name", user: "Zoë", email: "zoe+tales@mail.example.org", password: "pässwörd!"},
+		{name: "everything empty", want: []string{"name:required", "email:required", "password:required"}},
+		{name: "short name", user: "A", email: "a@example.com", password: "gopher123", want: []string{"name:too_short"}},
+		{name: "long name", user: strings.Repeat("a", 65), email: "a@example.com", password: "gopher123", want: []string{"name:too_long"}},
+		{name: "no at sign", user: "Ada", email: "ada.example.com", password: "gopher123", want: []string{"email:invalid"}},
+		{name: "display name", user: "Ada", email: "Ada <ada@example.com>", password: "gopher123", want: []string{"email:invalid"}},
+		{name: "no domain dot", user: "Ada", email: "ada@localhost", password: "gopher123", want: []string{"email:invalid"}},
+		{name: "long email", user: "Ada", email: strings.Repeat("a", 250) + "@x.io", password: "gopher123", want: []string{"email:too_long"}},
+		{name: "short password", user: "Ada", email: "ada@example.com", password: "go1", want: []string{"password:too_short"}},
+		{name: "letters only", user: "Ada", email: "ada@example.com", password: "gophergopher", want: []string{"password:weak"}},
+		{name: "digits only", user: "Ada", email: "ada@example.com", password: "1234567890", want: []string{"password:weak"}},
+		{name: "past bcrypt limit", user: "Ada", email: "ada@example.com", password: strings.Repeat("ab1", 25), want: []string{"password:too_long"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRegistration(tt.user, tt.email, tt.password)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected a ValidationError, got %v", err)
+			}
+
+			var got []string
+			for _, f := range validationErr.Fields {
+				if f.Message == "" {
+					t.Errorf("Expected a message for %s:%s", f.Field, f.Code)
+				}
+				got = append(got, f.Field+":"+f.Code)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"GopherTales/internal/models"
+)
+
+const (
+	// emailVerificationTTL is how long a verification link stays valid
+	emailVerificationTTL = 48 * time.Hour
+	// verificationResendInterval is the minimum time between verification emails
+	verificationResendInterval = 2 * time.Minute
+)
+
+var (
+	// ErrAlreadyVerified is returned when a verified user asks for another link
+	ErrAlreadyVerified = errors.New("email is already verified")
+	// ErrVerificationThrottled is returned when a verification email was sent too recently
+	ErrVerificationThrottled = errors.New("a verification email was sent recently, please wait before asking again")
+)
+
+// EmailVerificationService confirms that users own their email address
+type EmailVerificationService struct {
+	users   *UserService
+	tokens  TokenStore
+	mailer  Mailer
+	baseURL string
+	now     func() time.Time
+}
+
+// NewEmailVerificationService creates an email verification service.
+// Verification links point at baseURL + "/verify-email".
+func NewEmailVerificationService(users *UserService, tokens TokenStore, mailer Mailer, baseURL string) *EmailVerificationService {
+	return &EmailVerificationService{
+		users:   users,
+		tokens:  tokens,
+		mailer:  mailer,
+		baseURL: baseURL,
+		now:     time.Now,
+	}
+}
+
+// SendVerification emails a verification link to the user, replacing any
+// earlier link. Requests within verificationResendInterval of the last email
+// return ErrVerificationThrottled; the stored send time is checked and set in
+// one update, so concurrent requests send a single email.
+func (s *EmailVerificationService) SendVerification(ctx context.Context, user *models.User) error {
+	if user.EmailVerified {
+		return ErrAlreadyVerified
+	}
+
+	now := s.now()
+	if !user.VerificationSentAt.IsZero() && now.Sub(user.VerificationSentAt) < verificationResendInterval {
+		return ErrVerificationThrottled
+	}
+
+	marked, err := s.users.MarkVerificationSent(ctx, user.ID, now, verificationResendInterval)
+	if err != nil {
+		return err
+	}
+	if !marked {
+		return ErrVerificationThrottled
+	}
+	user.VerificationSentAt = now
+
+	if err := s.tokens.DeleteByUser(ctx, user.ID, models.TokenEmailVerification); err != nil {
+		return err
+	}
+
+	token, err := issueToken(ctx, s.tokens, user.ID, models.TokenEmailVerification, now, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.baseURL + "/verify-email?token=" + url.QueryEscape(token)
+	return s.mailer.Send(Email{
+		To:      user.Email,
+		Subject: "Confirm your GopherTales email",
+		Body: fmt.Sprintf("Hi %s,\n\nWelcome to GopherTales! Please confirm your email address by opening this link:\n\n%s\n\n"+
+			"The link is valid for 48 hours. If you didn't sign up, you can ignore this email.\n", user.Name, link),
+	})
+}
+
+// Verify marks the email of the token's user as verified. Each token can
+// only be used once.
+func (s *EmailVerificationService) Verify(ctx context.Context, token string) error {
+	consumed, err := s.tokens.Consume(ctx, hashToken(token), models.TokenEmailVerification, s.now())
+	if err != nil {
+		return err
+	}
+	return s.users.MarkEmailVerified(ctx, consumed.UserID)
+}
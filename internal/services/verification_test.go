@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"GopherTales/internal/models"
+)
+
+func TestEmailVerificationService_SendVerificationGuards(t *testing.T) {
+	now := time.Now()
+	service := NewEmailVerificationService(nil, NewMemoryTokenStore(), NewFileMailer("", ""), "http://localhost:8000")
+	service.now = func() time.Time { return now }
+
+	tests := []struct {
+		name string
+		user *models.User
+		want error
+	}{
+		{
+			name: "already verified",
+			user: &models.User{ID: primitive.NewObjectID(), EmailVerified: true},
+			want: ErrAlreadyVerified,
+		},
+		{
+			name: "sent moments ago",
+			user: &models.User{ID: primitive.NewObjectID(), VerificationSentAt: now.Add(-30 * time.Second)},
+			want: ErrVerificationThrottled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := service.SendVerification(context.Background(), tt.user); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestEmailVerificationService_VerifyRejectsOtherTokens(t *testing.T) {
+	tokens := NewMemoryTokenStore()
+	service := NewEmailVerificationService(nil, tokens, NewFileMailer("", ""), "http://localhost:8000")
+
+	// A password reset token must not verify an email
+	plain, err := issueToken(context.Background(), tokens, primitive.NewObjectID(), models.TokenPasswordReset, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+
+	if err := service.Verify(context.Background(), plain); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+	if err := service.Verify(context.Background(), "not-a-token"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestEmailVerificationService_SendVerificationStaleUser(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, users *UserService) {
+		user, err := users.Register(context.Background(), "Ada", "ada@example.com", "gopher123")
+		if err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+
+		mailer := &recordingMailer{}
+		service := NewEmailVerificationService(users, NewMemoryTokenStore(), mailer, "http://localhost:8000")
+
+		// Two requests that loaded the user before either sent an email
+		stale := *user
+		if err := service.SendVerification(context.Background(), user); err != nil {
+			t.Fatalf("Failed to send verification: %v", err)
+		}
+		if err := service.SendVerification(context.Background(), &stale); !errors.Is(err, ErrVerificationThrottled) {
+			t.Errorf("Expected ErrVerificationThrottled, got %v", err)
+		}
+		if len(mailer.sent) != 1 {
+			t.Errorf("Expected one email, got %d", len(mailer.sent))
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// StoryWatcher polls the story file or directory and reloads the story when
+// it changes. Polling keeps it portable and works on mounted volumes where
+// file system notifications are unreliable.
+type StoryWatcher struct {
+	service  *StoryService
+	interval time.Duration
+	last     string
+}
+
+// NewStoryWatcher creates a watcher that checks for changes every interval
+func NewStoryWatcher(service *StoryService, interval time.Duration) *StoryWatcher {
+	return &StoryWatcher{
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Run polls until ctx is cancelled
+func (w *StoryWatcher) Run(ctx context.Context) {
+	w.last, _ = storyFingerprint(w.service.DataFile())
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Check()
+		}
+	}
+}
+
+// Check reloads the story if its files changed since the last check and
+// reports whether a reload was attempted
+func (w *StoryWatcher) Check() bool {
+	fingerprint, err := storyFingerprint(w.service.DataFile())
+	if err != nil {
+		log.Printf("Story watcher: %v", err)
+		return false
+	}
+	if fingerprint == w.last {
+		return false
+	}
+
+	// Remember the fingerprint even when the reload fails, so a broken file
+	// is reported once rather than on every tick until it is fixed
+	w.last = fingerprint
+
+	if err := w.service.Reload(); err != nil {
+		log.Printf("Story reload failed, keeping the current story: %v", err)
+		return true
+	}
+	log.Printf("Story reloaded from %s", w.service.DataFile())
+	return true
+}
+
+// storyFingerprint summarises the name, size and modification time of every
+// file under path
+func storyFingerprint(path string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", p, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read story files %s: %w", path, err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
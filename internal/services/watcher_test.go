@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func reloadTestStory(title string) string {
+	return fmt.Sprintf(`{"blue": {
+		"intro": {"title": %q, "story": ["Hello"], "options": [{"text": "Go", "arc": "end"}]},
+		"end": {"title": "The End", "story": ["Bye"], "options": []}
+	}}`, title)
+}
+
+// writeStoryFile writes content and moves the modification time forward, so
+// changes are visible even on file systems with coarse timestamps
+func writeStoryFile(t *testing.T, path, content string, age time.Duration) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write story: %v", err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+}
+
+func introTitle(t *testing.T, service *StoryService) string {
+	t.Helper()
+
+	arc, _, err := service.GetGopherArc("blue", "intro", nil)
+	if err != nil {
+		t.Fatalf("Failed to get intro: %v", err)
+	}
+	return arc.Title
+}
+
+func TestStoryService_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "story.json")
+	writeStoryFile(t, path, reloadTestStory("First"), time.Hour)
+
+	service := NewStoryService(path)
+	if err := service.LoadStory(); err != nil {
+		t.Fatalf("Failed to load story: %v", err)
+	}
+
+	writeStoryFile(t, path, reloadTestStory("Second"), 0)
+	if err := service.Reload(); err != nil {
+		t.Fatalf("Expected reload to succeed: %v", err)
+	}
+	if title := introTitle(t, service); title != "Second" {
+		t.Errorf("Expected reloaded title 'Second', got '%s'", title)
+	}
+
+	// Invalid JSON keeps the current story
+	writeStoryFile(t, path, `{"blue": {`, 0)
+	if err := service.Reload(); err == nil {
+		t.Error("Expected reload of invalid JSON to fail")
+	}
+	if title := introTitle(t, service); title != "Second" {
+		t.Errorf("Expected story to be kept after failed reload, got '%s'", title)
+	}
+
+	// Lint errors keep the current story and are reported
+	writeStoryFile(t, path, `{"blue": {
+		"intro": {"title": "Broken", "story": ["Hello"], "options": [{"text": "Go", "arc": "nowhere"}]}
+	}}`, 0)
+	err := service.Reload()
+	var validationErr *StoryValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected StoryValidationError, got %v", err)
+	}
+	if codes := issueCodes(validationErr.Issues); codes["broken-link"] != 1 {
+		t.Errorf("Expected a broken-link issue, got %v", codes)
+	}
+	if title := introTitle(t, service); title != "Second" {
+		t.Errorf("Expected story to be kept after failed validation, got '%s'", title)
+	}
+}
+
+func TestStoryService_ReloadConcurrentReads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "story.json")
+	writeStoryFile(t, path, reloadTestStory("First"), 0)
+
+	service := NewStoryService(path)
+	if err := service.LoadStory(); err != nil {
+		t.Fatalf("Failed to load story: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, _, err := service.GetGopherArc("blue", "intro", nil); err != nil {
+					t.Errorf("Read during reload failed: %v", err)
+					return
+				}
+				service.GetStoryStats()
+				service.Lint()
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := service.Reload(); err != nil {
+			t.Errorf("Reload failed: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestStoryWatcher_Check(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blue", "intro.json")
+	writeFile(t, path, "")
+	writeStoryFile(t, path, `{"title": "First", "story": [], "options": []}`, time.Hour)
+
+	service := NewStoryService(dir)
+	if err := service.LoadStory(); err != nil {
+		t.Fatalf("Failed to load story: %v", err)
+	}
+
+	watcher := NewStoryWatcher(service, time.Second)
+	watcher.Check()
+	if watcher.Check() {
+		t.Error("Expected no reload when nothing changed")
+	}
+
+	writeStoryFile(t, path, `{"title": "Second", "story": [], "options": []}`, 0)
+	if !watcher.Check() {
+		t.Fatal("Expected reload after the arc file changed")
+	}
+	if title := introTitle(t, service); title != "Second" {
+		t.Errorf("Expected reloaded title 'Second', got '%s'", title)
+	}
+
+	// New files in the directory are picked up too
+	writeFile(t, filepath.Join(dir, "pink", "intro.json"), `{"title": "Pink", "story": [], "options": []}`)
+	if !watcher.Check() {
+		t.Fatal("Expected reload after a gopher was added")
+	}
+	if _, _, err := service.GetGopherArc("pink", "intro", nil); err != nil {
+		t.Errorf("Expected new gopher after reload: %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+// Package version reports which build of GopherTales is running. Release
+// builds set both variables at link time:
+//
+//	go build -ldflags "-X GopherTales/internal/version.Version=v1.4.0 -X GopherTales/internal/version.Commit=$(git rev-parse --short HEAD)" ./cmd/server
+package version
+
+import "runtime/debug"
+
+var (
+	Version = "dev"
+	Commit  = "unknown"
+)
+
+func init() {
+	if Commit != "unknown" {
+		return
+	}
+	// Builds of a package from a git checkout record the revision
+	// themselves, builds of single files such as main.go do not
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && len(setting.Value) >= 7 {
+				Commit = setting.Value[:7]
+			}
+		}
+	}
+}